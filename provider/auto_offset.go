@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// autoOffsetSchema returns the schema of the auto_offset block shared by QEMU and Docker nodes.
+func autoOffsetSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Fan out nodes sharing the same x/y (e.g. created with count or for_each) in a grid: each node takes the first free cell starting at x/y.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"dx": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Default:     100,
+					Description: "Horizontal distance between grid cells.",
+				},
+				"dy": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Default:     100,
+					Description: "Vertical distance between grid rows.",
+				},
+				"per_row": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      5,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "Number of cells per row.",
+				},
+				"x": {
+					Type:        schema.TypeInt,
+					Computed:    true,
+					Description: "X position the node was placed at.",
+				},
+				"y": {
+					Type:        schema.TypeInt,
+					Computed:    true,
+					Description: "Y position the node was placed at.",
+				},
+			},
+		},
+	}
+}
+
+// autoOffset returns the auto_offset block of a resource, or nil when it is not set.
+func autoOffset(d *schema.ResourceData) map[string]interface{} {
+	blocks := d.Get("auto_offset").([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+	return blocks[0].(map[string]interface{})
+}
+
+// autoOffsetPosition picks the first grid cell from (x, y) that no other node of the
+// project occupies and that no concurrent create in this run has claimed. Nodes are
+// identified by name, which GNS3 keeps unique within a project.
+func autoOffsetPosition(config *ProviderConfig, projectID, name string, x, y int, offset map[string]interface{}) (int, int, error) {
+	config.placementMu.Lock()
+	defer config.placementMu.Unlock()
+
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return 0, 0, err
+	}
+	occupied := map[string]bool{}
+	for _, node := range nodes {
+		if node["name"] != name {
+			occupied[fmt.Sprintf("%d,%d", jsonInt(node["x"]), jsonInt(node["y"]))] = true
+		}
+	}
+
+	dx, dy, perRow := offset["dx"].(int), offset["dy"].(int), offset["per_row"].(int)
+	for i := 0; ; i++ {
+		cx, cy := x+(i%perRow)*dx, y+(i/perRow)*dy
+		key := fmt.Sprintf("%d,%d", cx, cy)
+		owner, claimed := config.placed[projectID+"/"+key]
+		if occupied[key] || (claimed && owner != name) {
+			continue
+		}
+		config.placed[projectID+"/"+key] = name
+		return cx, cy, nil
+	}
+}
+
+// setAutoOffsetPosition records the placement in the auto_offset block.
+func setAutoOffsetPosition(d *schema.ResourceData, node map[string]interface{}) error {
+	offset := autoOffset(d)
+	if offset == nil {
+		return nil
+	}
+	offset["x"] = jsonInt(node["x"])
+	offset["y"] = jsonInt(node["y"])
+	return d.Set("auto_offset", []interface{}{offset})
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Scene size GNS3 gives projects that do not set one.
+const (
+	defaultSceneWidth  = 2000
+	defaultSceneHeight = 1000
+)
+
+// sceneSize is the canvas size of a project.
+type sceneSize struct {
+	Width  int `json:"scene_width"`
+	Height int `json:"scene_height"`
+}
+
+// projectSceneSize returns the canvas size of a project. It is fetched once per
+// run and shared by every node placed in the project.
+func projectSceneSize(config *ProviderConfig, projectID string) (sceneSize, error) {
+	key := "scene/" + projectID
+	if v, ok := config.Cache.Get(key); ok {
+		return v.(sceneSize), nil
+	}
+
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s", config.Host, projectID))
+	if err != nil {
+		return sceneSize{}, fmt.Errorf("failed to read project: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return sceneSize{}, fmt.Errorf("failed to read project, status code: %d", resp.StatusCode)
+	}
+
+	var size sceneSize
+	if err := json.NewDecoder(resp.Body).Decode(&size); err != nil {
+		return sceneSize{}, fmt.Errorf("failed to decode project: %s", err)
+	}
+	if size.Width <= 0 {
+		size.Width = defaultSceneWidth
+	}
+	if size.Height <= 0 {
+		size.Height = defaultSceneHeight
+	}
+	config.Cache.Set(key, size)
+	return size, nil
+}
+
+// canvasWarnings warns when an object lies outside its project's scene, which
+// the GNS3 GUI centers on the origin. Such objects exist but are easily mistaken
+// for ones that were never created.
+func canvasWarnings(d *schema.ResourceData, config *ProviderConfig) diag.Diagnostics {
+	projectID, _ := d.Get("project_id").(string)
+	if projectID == "" {
+		return nil
+	}
+
+	x, _ := d.Get("x").(int)
+	y, _ := d.Get("y").(int)
+	if blocks, ok := d.Get("auto_offset").([]interface{}); ok && len(blocks) > 0 {
+		x, _ = d.Get("auto_offset.0.x").(int)
+		y, _ = d.Get("auto_offset.0.y").(int)
+	}
+
+	size, err := projectSceneSize(config, projectID)
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Could not check the node position against the canvas",
+			Detail:   err.Error(),
+		}}
+	}
+
+	halfW, halfH := size.Width/2, size.Height/2
+	if x >= -halfW && x <= halfW && y >= -halfH && y <= halfH {
+		return nil
+	}
+
+	label := d.Id()
+	if name, _ := d.Get("name").(string); name != "" {
+		label = name
+	}
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("%q is placed outside the visible canvas", label),
+		Detail: fmt.Sprintf("Position (%d, %d) lies outside the %dx%d scene of project %s, which spans x %d..%d and y %d..%d. "+
+			"The object exists but the GNS3 GUI will not show it without scrolling away from the scene.",
+			x, y, size.Width, size.Height, projectID, -halfW, halfW, -halfH, halfH),
+		AttributePath: cty.GetAttrPath("x"),
+	}}
+}
+
+// withCanvasCheck wraps a create or update function so the resulting position
+// is checked against the project canvas. Updates are only checked when the
+// position changed.
+func withCanvasCheck(f func(*schema.ResourceData, interface{}) error) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		if err := f(d, meta); err != nil {
+			return diag.FromErr(err)
+		}
+		if d.Id() == "" || !positionChanged(d) {
+			return nil
+		}
+		return canvasWarnings(d, meta.(*ProviderConfig))
+	}
+}
+
+// positionChanged reports whether a create or update sets the position of an object.
+func positionChanged(d *schema.ResourceData) bool {
+	if d.IsNewResource() || d.HasChanges("x", "y") {
+		return true
+	}
+	_, hasOffset := d.Get("auto_offset").([]interface{})
+	return hasOffset && d.HasChange("auto_offset")
+}
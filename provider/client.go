@@ -0,0 +1,468 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+// Client is the HTTP client shared by every resource and data source. Its methods
+// mirror the net/http helpers so request code reads the same as before, while
+// provider-wide concerns (authentication, timeouts) are applied in one place.
+type Client struct {
+	httpClient *http.Client
+	token      string
+	headers    map[string]string
+	// dryRun, when set, turns every mutating request into a logged no-op.
+	dryRun *dryRunStore
+	// restartTimeout is how long requests wait for an unreachable controller to come
+	// back, e.g. while it restarts during a long apply. Zero fails immediately.
+	restartTimeout time.Duration
+	// tracer, when set, records a span for every request, under span if the
+	// client belongs to a traced resource operation.
+	tracer *tracer
+	span   *span
+	// failover, when set, sends requests to standby controllers when the active
+	// one cannot be reached.
+	failover *hostFailover
+	// limiter, when set, holds requests back to the configured rates.
+	limiter *rateLimiter
+	// locks, when set, locks the projects the run changes and reports the
+	// changes other writers make to them meanwhile.
+	locks *projectLocks
+}
+
+// hostFailover tracks the controllers of an active/standby deployment. Requests
+// go to the controller that answered last, and move on to the next one when it
+// cannot be reached.
+type hostFailover struct {
+	hosts []string
+
+	mu     sync.Mutex
+	active int
+}
+
+// newHostFailover returns a failover over hosts, the first one being active.
+func newHostFailover(hosts []string) *hostFailover {
+	trimmed := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		trimmed = append(trimmed, trimHost(h))
+	}
+	return &hostFailover{hosts: trimmed}
+}
+
+// do sends req to the active controller, then to the following ones in order
+// while they cannot be reached.
+func (f *hostFailover) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	start := f.active
+	f.mu.Unlock()
+
+	var err error
+	for i := 0; i < len(f.hosts); i++ {
+		idx := (start + i) % len(f.hosts)
+		if i > 0 {
+			if rerr := rewindBody(req); rerr != nil {
+				return nil, fmt.Errorf("cannot fail over %s %s to controller %s: %s", req.Method, req.URL.Path, f.hosts[idx], rerr)
+			}
+		}
+		f.route(req, f.hosts[idx])
+
+		var resp *http.Response
+		if resp, err = client.Do(req); err == nil {
+			if idx != start {
+				log.Printf("[WARN] GNS3 request %s: failed over to controller %s", requestID(req), f.hosts[idx])
+				f.mu.Lock()
+				f.active = idx
+				f.mu.Unlock()
+			}
+			return resp, nil
+		}
+		if !retryableConnectionError(req, err) && !dialError(err) {
+			return nil, err
+		}
+		log.Printf("[WARN] GNS3 request %s: controller %s unreachable (%s)", requestID(req), f.hosts[idx], err)
+	}
+	return nil, err
+}
+
+// route points a request built against any of the hosts at host.
+func (f *hostFailover) route(req *http.Request, host string) {
+	raw := req.URL.String()
+	for _, h := range f.hosts {
+		if raw != h && !strings.HasPrefix(raw, h+"/") && !strings.HasPrefix(raw, h+"?") {
+			continue
+		}
+		if u, err := url.Parse(host + strings.TrimPrefix(raw, h)); err == nil {
+			req.URL = u
+			req.Host = ""
+		}
+		return
+	}
+}
+
+// dialError reports whether err happened while connecting, so the request never
+// reached a controller and may be sent elsewhere.
+func dialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// projectPathPattern extracts the project ID from URLs scoped to a project.
+var projectPathPattern = regexp.MustCompile(`/v2/projects/([^/]+)/(.+)$`)
+
+// newClient builds the shared client. An empty token disables authentication;
+// headers are added to every request, e.g. for SSO proxies in front of the controller.
+func newClient(token string, headers map[string]string) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		token:      token,
+		headers:    headers,
+	}
+}
+
+// ipProtocolNetworks maps the ip_protocol provider option to the network the
+// controller connections are dialed on.
+var ipProtocolNetworks = map[string]string{
+	"auto": "tcp",
+	"ipv4": "tcp4",
+	"ipv6": "tcp6",
+}
+
+// setIPProtocol restricts controller connections to IPv4 or IPv6, e.g. when a
+// dual-stack host name resolves to an IPv6 address the controller is not
+// reachable on. "auto" keeps the default of trying every resolved address.
+func (c *Client) setIPProtocol(protocol string) {
+	network, ok := ipProtocolNetworks[protocol]
+	if !ok || network == "tcp" {
+		return
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	c.httpClient.Transport = transport
+}
+
+// requestIDHeader carries the correlation ID of a request, so provider logs can be
+// matched with the controller's and those of proxies in front of it.
+const requestIDHeader = "X-Request-ID"
+
+// RequestError is returned when a request gets no response from the controller.
+// It records which request failed and how long it ran.
+type RequestError struct {
+	ID       string
+	Method   string
+	Path     string
+	Duration time.Duration
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s failed after %s (request %s): %s", e.Method, e.Path, e.Duration, e.ID, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// newRequestID returns a short random ID correlating the log lines and error of a request.
+func newRequestID() string {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "unknown"
+	}
+	return id[:8]
+}
+
+// requestID returns the correlation ID of a request sent by Do.
+func requestID(req *http.Request) string {
+	return req.Header.Get(requestIDHeader)
+}
+
+// withoutTimeout returns a copy of the client whose requests are not cut off
+// after the usual total timeout, for transfers that take as long as their size
+// requires, such as image uploads.
+func (c *Client) withoutTimeout() *Client {
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	unbounded := *c
+	unbounded.httpClient = &httpClient
+	return &unbounded
+}
+
+// withSpan returns a copy of the client recording its requests under parent.
+func (c *Client) withSpan(parent *span) *Client {
+	traced := *c
+	traced.span = parent
+	return &traced
+}
+
+// Do sends an HTTP request to the controller. Every request is tagged with a
+// correlation ID and logged with its duration; failures carry both.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	id := newRequestID()
+	req.Header.Set(requestIDHeader, id)
+	start := time.Now()
+
+	if c.tracer != nil {
+		s := c.tracer.startSpan(req.Method+" "+req.URL.Path, spanKindClient, c.span, map[string]interface{}{
+			"http.request.method": req.Method,
+			"url.path":            req.URL.Path,
+			"gns3.request_id":     id,
+		})
+		resp, err := c.do(req, id, start)
+		if resp != nil {
+			s.attrs["http.response.status_code"] = resp.StatusCode
+		}
+		s.finish(err)
+		return resp, err
+	}
+	return c.do(req, id, start)
+}
+
+// do sends a request tagged with id and logs its outcome.
+func (c *Client) do(req *http.Request, id string, start time.Time) (*http.Response, error) {
+	if c.locks != nil {
+		if err := c.locks.before(c, req); err != nil {
+			log.Printf("[ERROR] GNS3 request %s: %s %s not sent: %s", id, req.Method, req.URL.Path, err)
+			return nil, err
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	if c.dryRun != nil {
+		resp, err = c.dryRun.do(req, c.send)
+	} else {
+		resp, err = c.send(req)
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		log.Printf("[ERROR] GNS3 request %s: %s %s failed after %s: %s", id, req.Method, req.URL.Path, elapsed, err)
+		return nil, &RequestError{ID: id, Method: req.Method, Path: req.URL.Path, Duration: elapsed, Err: err}
+	}
+	level := "[DEBUG]"
+	if resp.StatusCode >= http.StatusBadRequest {
+		level = "[WARN]"
+	}
+	log.Printf("%s GNS3 request %s: %s %s returned %d in %s", level, id, req.Method, req.URL.Path, resp.StatusCode, elapsed)
+	if c.locks != nil {
+		c.locks.after(req, resp)
+	}
+	return resp, nil
+}
+
+// send performs the request against the controller. A change rejected because its
+// project is closed is retried once after opening the project; reads are not, so
+// refreshing a deliberately closed project leaves it closed.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.sendReachable(req)
+	if err != nil || req.Method == "GET" || !projectNotOpened(req, resp) {
+		return resp, err
+	}
+
+	projectID := projectPathPattern.FindStringSubmatch(req.URL.Path)[1]
+	log.Printf("[INFO] GNS3 request %s: project %s is not opened, opening it and retrying %s %s", requestID(req), projectID, req.Method, req.URL.Path)
+	if err := c.reopenProject(req); err != nil {
+		return nil, fmt.Errorf("project %s is not opened and could not be opened automatically (%s); open it in GNS3 or set state = \"opened\" on its gns3_project", projectID, err)
+	}
+	if err := rewindBody(req); err != nil {
+		return nil, fmt.Errorf("project %s was not opened and %s %s cannot be replayed: %s", projectID, req.Method, req.URL.Path, err)
+	}
+	if resp, err = c.sendReachable(req); err == nil && projectNotOpened(req, resp) {
+		return nil, fmt.Errorf("project %s is still not opened after opening it; open it in GNS3 and retry", projectID)
+	}
+	return resp, err
+}
+
+// sendReachable performs the request. When the controller cannot be reached the
+// request is retried until restartTimeout elapses; once the controller answers
+// again the project the request belongs to is re-opened first, since a restarted
+// controller loads its projects closed.
+func (c *Client) sendReachable(req *http.Request) (*http.Response, error) {
+	resp, err := c.attempt(req)
+	if err == nil || c.restartTimeout == 0 || !retryableConnectionError(req, err) {
+		return resp, err
+	}
+
+	deadline := time.Now().Add(c.restartTimeout)
+	backoff := 100 * time.Millisecond
+	for {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("controller unreachable for %s: %s", c.restartTimeout, err)
+		}
+		log.Printf("[WARN] GNS3 request %s: controller unreachable (%s), retrying %s %s in %s", requestID(req), err, req.Method, req.URL.Path, backoff)
+		time.Sleep(backoff)
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+
+		if err = c.reopenProject(req); err != nil {
+			if !retryableConnectionError(req, err) {
+				return nil, err
+			}
+			continue
+		}
+		if rerr := rewindBody(req); rerr != nil {
+			return nil, fmt.Errorf("controller connection lost and %s %s cannot be replayed: %s", req.Method, req.URL.Path, rerr)
+		}
+		if resp, err = c.attempt(req); err == nil || !retryableConnectionError(req, err) {
+			return resp, err
+		}
+	}
+}
+
+// reopenProject opens the project a request is scoped to. Opening an opened
+// project is a no-op for the controller.
+func (c *Client) reopenProject(req *http.Request) error {
+	m := projectPathPattern.FindStringSubmatch(req.URL.Path)
+	if m == nil || m[2] == "open" || m[2] == "close" {
+		return nil
+	}
+	u := *req.URL
+	u.Path = fmt.Sprintf("/v2/projects/%s/open", m[1])
+	u.RawQuery = ""
+	open, err := http.NewRequest("POST", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	open.Header.Set(requestIDHeader, requestID(req))
+	resp, err := c.attempt(open)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to re-open project %s, status code: %d", m[1], resp.StatusCode)
+	}
+	return nil
+}
+
+// rewindBody resets the body of a request so it can be sent again.
+func rewindBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return errors.New("request body cannot be rewound")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// projectNotOpened reports whether the controller rejected a project-scoped
+// request because the project is closed. The response body stays readable.
+func projectNotOpened(req *http.Request, resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusConflict {
+		return false
+	}
+	if m := projectPathPattern.FindStringSubmatch(req.URL.Path); m == nil || m[2] == "open" || m[2] == "close" {
+		return false
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return strings.Contains(strings.ToLower(string(body)), "not opened")
+}
+
+// retryableConnectionError reports whether err means the controller went away.
+// A refused connection never reached the controller, so any request may be
+// retried; a dropped connection may have been processed, so only idempotent
+// requests are.
+func retryableConnectionError(req *http.Request, err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if req.Method == "POST" {
+		return false
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// attempt performs a single request against the controller.
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.limiter != nil {
+		c.limiter.wait(req)
+	}
+	if c.failover != nil {
+		return c.failover.do(c.httpClient, req)
+	}
+	return c.httpClient.Do(req)
+}
+
+// Get issues a GET to the specified URL.
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post issues a POST to the specified URL.
+func (c *Client) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// Cache holds values that only need to be looked up once per provider run,
+// such as controller metadata shared by many resources.
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]interface{}
+}
+
+func newCache() *Cache {
+	return &Cache{items: map[string]interface{}{}}
+}
+
+// Get returns the cached value for key, if any.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.items[key]
+	return v, ok
+}
+
+// Set stores a value under key.
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+}
+
+// trimHost removes a trailing slash so URLs can be joined with fmt.Sprintf.
+func trimHost(host string) string {
+	return strings.TrimRight(host, "/")
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// listComputes returns the computes registered with the controller.
+func listComputes(config *ProviderConfig) ([]map[string]interface{}, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/computes", config.Host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list computes: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list computes, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var computes []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&computes); err != nil {
+		return nil, fmt.Errorf("failed to decode computes: %s", err)
+	}
+	return computes, nil
+}
+
+// checkCompute returns an error when computeID is not registered with the
+// controller or is not connected to it. The local compute is always accepted.
+func checkCompute(config *ProviderConfig, computeID string) error {
+	if computeID == "" || computeID == "local" {
+		return nil
+	}
+	computes, err := listComputes(config)
+	if err != nil {
+		return err
+	}
+
+	known := make([]string, 0, len(computes))
+	for _, c := range computes {
+		id, _ := c["compute_id"].(string)
+		if id != computeID {
+			known = append(known, id)
+			continue
+		}
+		if connected, _ := c["connected"].(bool); !connected {
+			name, _ := c["name"].(string)
+			return fmt.Errorf("compute %q (%s) is registered but not connected to the controller", computeID, name)
+		}
+		return nil
+	}
+	sort.Strings(known)
+	return fmt.Errorf("compute %q is not registered with the controller, known computes: %s", computeID, strings.Join(known, ", "))
+}
+
+// computeCustomizeDiff checks at plan time that a new or changed compute_id
+// names a connected compute, instead of failing the create with a 404.
+func computeCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	config, ok := meta.(*ProviderConfig)
+	if !ok || !d.NewValueKnown("compute_id") || (d.Id() != "" && !d.HasChange("compute_id")) {
+		return nil
+	}
+	return checkCompute(config, d.Get("compute_id").(string))
+}
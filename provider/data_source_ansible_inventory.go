@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceGns3AnsibleInventory renders an Ansible inventory from a project's nodes.
+func dataSourceGns3AnsibleInventory() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3AnsibleInventoryRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The UUID of the project to build the inventory from.",
+			},
+			"format": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "ini",
+				ValidateFunc: validation.StringInSlice([]string{"ini", "yaml"}, false),
+				Description:  "Inventory format: ini or yaml.",
+			},
+			"group": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "gns3",
+				Description: "Name of the Ansible group that contains every node.",
+			},
+			"include_without_console": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Include nodes that have no console (e.g. switches and clouds).",
+			},
+			"group_vars": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Variables applied to the whole group.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"host_vars": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Extra variables for individual nodes, matched by node name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"node": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Name of the node.",
+						},
+						"vars": {
+							Type:        schema.TypeMap,
+							Required:    true,
+							Description: "Variables for the node.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			"rendered": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The rendered inventory.",
+			},
+		},
+	}
+}
+
+// ansibleHost is a single inventory host with its variables.
+type ansibleHost struct {
+	Name string
+	Vars map[string]string
+}
+
+func dataSourceGns3AnsibleInventoryRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	entries, err := getConsoleInventory(config, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to build Ansible inventory: %s", err)
+	}
+
+	extra := map[string]map[string]string{}
+	for _, hv := range d.Get("host_vars").([]interface{}) {
+		block := hv.(map[string]interface{})
+		vars := map[string]string{}
+		for k, v := range block["vars"].(map[string]interface{}) {
+			vars[k] = v.(string)
+		}
+		extra[block["node"].(string)] = vars
+	}
+
+	var hosts []ansibleHost
+	for _, e := range entries {
+		hasConsole := e.Console > 0 && e.ConsoleType != "none"
+		if !hasConsole && !d.Get("include_without_console").(bool) {
+			continue
+		}
+		vars := map[string]string{
+			"gns3_node_id":   e.NodeID,
+			"gns3_node_type": e.NodeType,
+		}
+		if hasConsole {
+			vars["ansible_host"] = e.ConsoleHost
+			vars["ansible_port"] = strconv.Itoa(e.Console)
+			vars["gns3_console_type"] = e.ConsoleType
+		}
+		for k, v := range extra[e.Name] {
+			vars[k] = v
+		}
+		hosts = append(hosts, ansibleHost{Name: e.Name, Vars: vars})
+	}
+
+	groupVars := map[string]string{}
+	for k, v := range d.Get("group_vars").(map[string]interface{}) {
+		groupVars[k] = v.(string)
+	}
+
+	group := d.Get("group").(string)
+	var rendered string
+	if d.Get("format").(string) == "yaml" {
+		rendered = renderAnsibleYAML(group, hosts, groupVars)
+	} else {
+		rendered = renderAnsibleINI(group, hosts, groupVars)
+	}
+
+	d.Set("rendered", rendered)
+	d.SetId(projectID)
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderAnsibleINI renders hosts in the INI inventory format.
+func renderAnsibleINI(group string, hosts []ansibleHost, groupVars map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", group)
+	for _, h := range hosts {
+		b.WriteString(h.Name)
+		for _, k := range sortedKeys(h.Vars) {
+			fmt.Fprintf(&b, " %s=%s", k, iniValue(h.Vars[k]))
+		}
+		b.WriteString("\n")
+	}
+	if len(groupVars) > 0 {
+		fmt.Fprintf(&b, "\n[%s:vars]\n", group)
+		for _, k := range sortedKeys(groupVars) {
+			fmt.Fprintf(&b, "%s=%s\n", k, iniValue(groupVars[k]))
+		}
+	}
+	return b.String()
+}
+
+func iniValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\"'=#;") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// renderAnsibleYAML renders hosts in the YAML inventory format. Strings are emitted
+// double-quoted, which is valid YAML for any value.
+func renderAnsibleYAML(group string, hosts []ansibleHost, groupVars map[string]string) string {
+	var b strings.Builder
+	b.WriteString("all:\n  children:\n")
+	fmt.Fprintf(&b, "    %s:\n", strconv.Quote(group))
+	if len(hosts) == 0 {
+		b.WriteString("      hosts: {}\n")
+	} else {
+		b.WriteString("      hosts:\n")
+		for _, h := range hosts {
+			fmt.Fprintf(&b, "        %s:\n", strconv.Quote(h.Name))
+			for _, k := range sortedKeys(h.Vars) {
+				fmt.Fprintf(&b, "          %s: %s\n", strconv.Quote(k), strconv.Quote(h.Vars[k]))
+			}
+		}
+	}
+	if len(groupVars) > 0 {
+		b.WriteString("      vars:\n")
+		for _, k := range sortedKeys(groupVars) {
+			fmt.Fprintf(&b, "        %s: %s\n", strconv.Quote(k), strconv.Quote(groupVars[k]))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceGns3Compute reports what a compute can run, so modules can only
+// create the node types the target compute supports.
+func dataSourceGns3Compute() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ComputeRead,
+		Schema: map[string]*schema.Schema{
+			"compute_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "local",
+				Description: "The compute to probe.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the compute.",
+			},
+			"connected": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the controller is connected to the compute. The capabilities are empty when it is not.",
+			},
+			"version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "GNS3 version of the compute.",
+			},
+			"platform": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Operating system of the compute, e.g. linux, darwin or win32.",
+			},
+			"cpus": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of logical CPUs of the compute.",
+			},
+			"memory_mb": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Total memory of the compute in MB.",
+			},
+			"node_types": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Node types the compute can run, sorted.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"docker_available": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the compute can run Docker nodes.",
+			},
+			"dynamips_available": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the compute can run Dynamips nodes.",
+			},
+			"iou_available": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the compute can run IOU nodes.",
+			},
+			"qemu_available": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the compute can run QEMU nodes.",
+			},
+			"kvm_available": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether QEMU can use KVM acceleration on the compute.",
+			},
+			"kvm_architectures": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Architectures KVM accelerates, e.g. x86_64.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"qemu_binaries": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "QEMU binaries installed on the compute.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"version": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getComputeData fetches a compute, or with a path such as local/qemu/binaries
+// an endpoint of the compute the controller forwards, into out.
+func getComputeData(config *ProviderConfig, path string, out interface{}) error {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/computes/%s", config.Host, path))
+	if err != nil {
+		return fmt.Errorf("failed to read compute %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to read compute %s, status code: %d, response: %s", path, resp.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode compute %s: %s", path, err)
+	}
+	return nil
+}
+
+func dataSourceGns3ComputeRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	computeID := d.Get("compute_id").(string)
+
+	var compute map[string]interface{}
+	if err := getComputeData(config, computeID, &compute); err != nil {
+		return err
+	}
+
+	capabilities, _ := compute["capabilities"].(map[string]interface{})
+	nodeTypes := []string{}
+	if raw, ok := capabilities["node_types"].([]interface{}); ok {
+		for _, t := range raw {
+			if s, ok := t.(string); ok {
+				nodeTypes = append(nodeTypes, s)
+			}
+		}
+	}
+	sort.Strings(nodeTypes)
+	connected, _ := compute["connected"].(bool)
+
+	// QEMU details come from the compute itself, so only ask a connected one
+	kvm := []string{}
+	binaries := []map[string]interface{}{}
+	if connected && containsString(nodeTypes, "qemu") {
+		var qemuCapabilities struct {
+			KVM []string `json:"kvm"`
+		}
+		if err := getComputeData(config, computeID+"/qemu/capabilities", &qemuCapabilities); err != nil {
+			log.Printf("[WARN] Cannot tell whether compute %s supports KVM: %s", computeID, err)
+		} else if qemuCapabilities.KVM != nil {
+			kvm = qemuCapabilities.KVM
+		}
+
+		var qemuBinaries []map[string]interface{}
+		if err := getComputeData(config, computeID+"/qemu/binaries", &qemuBinaries); err != nil {
+			log.Printf("[WARN] Cannot list the QEMU binaries of compute %s: %s", computeID, err)
+		}
+		for _, b := range qemuBinaries {
+			binaries = append(binaries, map[string]interface{}{
+				"path":    b["path"],
+				"version": b["version"],
+			})
+		}
+	}
+
+	d.SetId(computeID)
+	d.Set("name", compute["name"])
+	d.Set("connected", connected)
+	d.Set("version", capabilities["version"])
+	d.Set("platform", capabilities["platform"])
+	d.Set("cpus", jsonInt(capabilities["cpus"]))
+	if memory, ok := capabilities["memory"].(float64); ok {
+		d.Set("memory_mb", int(memory/(1024*1024)))
+	}
+	if err := d.Set("node_types", nodeTypes); err != nil {
+		return fmt.Errorf("failed to set node_types: %s", err)
+	}
+	d.Set("docker_available", containsString(nodeTypes, "docker"))
+	d.Set("dynamips_available", containsString(nodeTypes, "dynamips"))
+	d.Set("iou_available", containsString(nodeTypes, "iou"))
+	d.Set("qemu_available", containsString(nodeTypes, "qemu"))
+	d.Set("kvm_available", len(kvm) > 0)
+	if err := d.Set("kvm_architectures", kvm); err != nil {
+		return fmt.Errorf("failed to set kvm_architectures: %s", err)
+	}
+	if err := d.Set("qemu_binaries", binaries); err != nil {
+		return fmt.Errorf("failed to set qemu_binaries: %s", err)
+	}
+	return nil
+}
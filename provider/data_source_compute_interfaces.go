@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceGns3ComputeInterfaces lists the network interfaces of a compute, the
+// names cloud nodes can bridge to.
+func dataSourceGns3ComputeInterfaces() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ComputeInterfacesRead,
+		Schema: map[string]*schema.Schema{
+			"compute_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "local",
+				Description: "The compute to list interfaces of.",
+			},
+			"include_special": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Also list interfaces GNS3 marks as special, such as loopback, docker and virbr bridges.",
+			},
+			"interfaces": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Interfaces of the compute, sorted by name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ip_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"mac_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"special": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"names": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Names of the listed interfaces, usable as cloud port mapping interfaces.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceGns3ComputeInterfacesRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	computeID := d.Get("compute_id").(string)
+
+	url := fmt.Sprintf("%s/v2/computes/%s/network/interfaces", config.Host, computeID)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to list compute interfaces: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to list compute interfaces, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var raw []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("failed to decode compute interfaces: %s", err)
+	}
+
+	includeSpecial := d.Get("include_special").(bool)
+	interfaces := make([]map[string]interface{}, 0, len(raw))
+	for _, iface := range raw {
+		special, _ := iface["special"].(bool)
+		if special && !includeSpecial {
+			continue
+		}
+		name, _ := iface["name"].(string)
+		ifaceType, _ := iface["type"].(string)
+		ip, _ := iface["ip_address"].(string)
+		mac, _ := iface["mac_address"].(string)
+		interfaces = append(interfaces, map[string]interface{}{
+			"name":        name,
+			"type":        ifaceType,
+			"ip_address":  ip,
+			"mac_address": mac,
+			"special":     special,
+		})
+	}
+	sort.Slice(interfaces, func(i, j int) bool {
+		return interfaces[i]["name"].(string) < interfaces[j]["name"].(string)
+	})
+
+	names := make([]string, 0, len(interfaces))
+	for _, iface := range interfaces {
+		names = append(names, iface["name"].(string))
+	}
+
+	d.SetId(computeID)
+	if err := d.Set("interfaces", interfaces); err != nil {
+		return fmt.Errorf("failed to set interfaces: %s", err)
+	}
+	d.Set("names", names)
+	return nil
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceGns3ConsoleInventory lists the console endpoints of every node in a project.
+func dataSourceGns3ConsoleInventory() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ConsoleInventoryRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The UUID of the project to inventory.",
+			},
+			"nodes": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Console details for each node, sorted by name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"console_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"console_host": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"console": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"consoles": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Map of node name to console address (host:port) for nodes that have a console.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// consoleInventoryEntry is the console information of a single node.
+type consoleInventoryEntry struct {
+	Name        string
+	NodeID      string
+	NodeType    string
+	ConsoleType string
+	ConsoleHost string
+	Console     int
+}
+
+// getConsoleInventory returns console details for every node of a project, sorted by name.
+func getConsoleInventory(config *ProviderConfig, projectID string) ([]consoleInventoryEntry, error) {
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]consoleInventoryEntry, 0, len(nodes))
+	for _, node := range nodes {
+		e := consoleInventoryEntry{
+			Console:     jsonInt(node["console"]),
+			ConsoleHost: consoleHost(config, node),
+		}
+		e.Name, _ = node["name"].(string)
+		e.NodeID, _ = node["node_id"].(string)
+		e.NodeType, _ = node["node_type"].(string)
+		e.ConsoleType, _ = node["console_type"].(string)
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func dataSourceGns3ConsoleInventoryRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	entries, err := getConsoleInventory(config, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to build console inventory: %s", err)
+	}
+
+	nodes := make([]map[string]interface{}, 0, len(entries))
+	consoles := map[string]interface{}{}
+	for _, e := range entries {
+		nodes = append(nodes, map[string]interface{}{
+			"name":         e.Name,
+			"node_id":      e.NodeID,
+			"node_type":    e.NodeType,
+			"console_type": e.ConsoleType,
+			"console_host": e.ConsoleHost,
+			"console":      e.Console,
+		})
+		if e.Console > 0 && e.ConsoleType != "none" {
+			consoles[e.Name] = net.JoinHostPort(e.ConsoleHost, strconv.Itoa(e.Console))
+		}
+	}
+
+	if err := d.Set("nodes", nodes); err != nil {
+		return fmt.Errorf("failed to set nodes: %s", err)
+	}
+	if err := d.Set("consoles", consoles); err != nil {
+		return fmt.Errorf("failed to set consoles: %s", err)
+	}
+
+	d.SetId(projectID)
+	return nil
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceGns3ExpiredSnapshots finds the snapshots of a project that exceed
+// the keep_last retention recorded in their names.
+func dataSourceGns3ExpiredSnapshots() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ExpiredSnapshotsRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The project whose snapshots are checked.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only check snapshots with this name (without retention metadata).",
+			},
+			"snapshot_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the snapshots exceeding retention, oldest first.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"snapshots": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The snapshots exceeding retention, oldest first.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"snapshot_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"keep_last": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"created_by": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"created_at": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// expiredSnapshots returns the snapshots beyond the newest keep_last of their
+// series, a series being the snapshots sharing a name and created_by. Snapshots
+// without keep_last never expire.
+func expiredSnapshots(snapshots []Snapshot, only string) []Snapshot {
+	series := map[string][]Snapshot{}
+	keep := map[string]int{}
+	for _, s := range snapshots {
+		name, keepLast, createdBy := parseSnapshotName(s.Name)
+		if keepLast == 0 || (only != "" && name != only) {
+			continue
+		}
+		key := name + "\x00" + createdBy
+		series[key] = append(series[key], s)
+		keep[key] = keepLast
+	}
+
+	var expired []Snapshot
+	for key, list := range series {
+		sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt > list[j].CreatedAt })
+		if len(list) > keep[key] {
+			expired = append(expired, list[keep[key]:]...)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].CreatedAt != expired[j].CreatedAt {
+			return expired[i].CreatedAt < expired[j].CreatedAt
+		}
+		return expired[i].SnapshotID < expired[j].SnapshotID
+	})
+	return expired
+}
+
+func dataSourceGns3ExpiredSnapshotsRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	snapshots, err := listSnapshots(config, projectID)
+	if err != nil {
+		return err
+	}
+
+	expired := expiredSnapshots(snapshots, d.Get("name").(string))
+	ids := make([]string, 0, len(expired))
+	flattened := make([]map[string]interface{}, 0, len(expired))
+	for _, s := range expired {
+		name, keepLast, createdBy := parseSnapshotName(s.Name)
+		ids = append(ids, s.SnapshotID)
+		flattened = append(flattened, map[string]interface{}{
+			"snapshot_id": s.SnapshotID,
+			"name":        name,
+			"keep_last":   keepLast,
+			"created_by":  createdBy,
+			"created_at":  int(s.CreatedAt),
+		})
+	}
+
+	if err := d.Set("snapshots", flattened); err != nil {
+		return fmt.Errorf("failed to set snapshots: %s", err)
+	}
+	if err := d.Set("snapshot_ids", ids); err != nil {
+		return fmt.Errorf("failed to set snapshot_ids: %s", err)
+	}
+	d.SetId(fmt.Sprintf("%s/expired-snapshots:%s", projectID, strings.Join(ids, ",")))
+	return nil
+}
@@ -42,7 +42,7 @@ func dataSourceGns3LinkIDRead(d *schema.ResourceData, meta interface{}) error {
 
 	// Construct the API URL using the controller endpoint.
 	apiURL := fmt.Sprintf("%s/v2/controller/link/projects/%s/links", config.APIURL, projectID)
-	resp, err := http.Get(apiURL)
+	resp, err := config.Client.Get(apiURL)
 	if err != nil {
 		return fmt.Errorf("failed to query links: %s", err)
 	}
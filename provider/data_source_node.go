@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceGns3Node looks up a node and reports which of its ports are free.
+func dataSourceGns3Node() *schema.Resource {
+	freePorts := nodePortsSchema()
+	freePorts.Description = "Ports with no link attached, in adapter/port order."
+
+	return &schema.Resource{
+		Read: dataSourceGns3NodeRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The UUID of the project the node belongs to.",
+			},
+			"node_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"node_id", "name"},
+				Description:  "ID of the node to look up.",
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"node_id", "name"},
+				Description:  "Name of the node to look up.",
+			},
+			"link_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only consider ports of this link type (e.g. ethernet or serial) as free.",
+			},
+			"node_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The node type.",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The node status (started, stopped or suspended).",
+			},
+			"ports":      nodePortsSchema(),
+			"free_ports": freePorts,
+			"gns3_url":   webURLSchema("Web UI link to the node's console."),
+			"next_free_adapter": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Adapter number of the first free port, or -1 if every port is linked.",
+			},
+			"next_free_port": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Port number of the first free port, or -1 if every port is linked.",
+			},
+		},
+	}
+}
+
+// nodeFreePorts returns the ports of a node that no link is attached to, in the
+// order the controller lists them.
+func nodeFreePorts(config *ProviderConfig, projectID string, node map[string]interface{}) ([]map[string]interface{}, error) {
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return freeNodePorts(node, links), nil
+}
+
+// freeNodePorts returns the ports of a node that none of links is attached to.
+func freeNodePorts(node map[string]interface{}, links []Link) []map[string]interface{} {
+	nodeID, _ := node["node_id"].(string)
+	used := map[[2]int]bool{}
+	for _, link := range links {
+		for _, end := range link.Nodes {
+			if end.NodeID == nodeID {
+				used[[2]int{end.AdapterNumber, end.PortNumber}] = true
+			}
+		}
+	}
+
+	ports := flattenNodePorts(node)
+	free := make([]map[string]interface{}, 0, len(ports))
+	for _, port := range ports {
+		if !used[[2]int{port["adapter_number"].(int), port["port_number"].(int)}] {
+			free = append(free, port)
+		}
+	}
+	return free
+}
+
+// listProjectLinks returns every link of a project.
+func listProjectLinks(config *ProviderConfig, projectID string) ([]Link, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s/links", config.Host, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list links, status code: %d", resp.StatusCode)
+	}
+
+	var links []Link
+	if err := json.NewDecoder(resp.Body).Decode(&links); err != nil {
+		return nil, fmt.Errorf("failed to decode links: %s", err)
+	}
+	return links, nil
+}
+
+func dataSourceGns3NodeRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	var node map[string]interface{}
+	var err error
+	if nodeID := d.Get("node_id").(string); nodeID != "" {
+		if node, err = getNode(config, projectID, nodeID); err == nil && node == nil {
+			err = fmt.Errorf("node '%s' not found in project '%s'", nodeID, projectID)
+		}
+	} else {
+		name := d.Get("name").(string)
+		if node, err = findNodeByName(config, projectID, name); err == nil && node == nil {
+			err = fmt.Errorf("node with name '%s' not found in project '%s'", name, projectID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	nodeID, _ := node["node_id"].(string)
+
+	ports := flattenNodePorts(node)
+	free, err := nodeFreePorts(config, projectID, node)
+	if err != nil {
+		return err
+	}
+	if linkType := d.Get("link_type").(string); linkType != "" {
+		matching := free[:0]
+		for _, port := range free {
+			if port["link_type"] == linkType {
+				matching = append(matching, port)
+			}
+		}
+		free = matching
+	}
+
+	nextAdapter, nextPort := -1, -1
+	if len(free) > 0 {
+		nextAdapter = free[0]["adapter_number"].(int)
+		nextPort = free[0]["port_number"].(int)
+	}
+
+	d.SetId(nodeID)
+	d.Set("node_id", nodeID)
+	d.Set("name", node["name"])
+	d.Set("node_type", node["node_type"])
+	d.Set("status", node["status"])
+	if err := d.Set("ports", ports); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	if err := d.Set("free_ports", free); err != nil {
+		return fmt.Errorf("failed to set free_ports: %s", err)
+	}
+	d.Set("next_free_adapter", nextAdapter)
+	d.Set("next_free_port", nextPort)
+	d.Set("gns3_url", nodeWebURL(config, projectID, nodeID))
+	return nil
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// nodeEvents are the node status changes gns3_node_event can wait for.
+var nodeEvents = []string{"started", "stopped", "suspended"}
+
+// dataSourceGns3NodeEvent blocks until a node reaches a status, following the
+// project's notification stream, so later stages can depend on real lab events.
+func dataSourceGns3NodeEvent() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3NodeEventRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The project the node belongs to.",
+			},
+			"node_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The node to watch.",
+			},
+			"event": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice(nodeEvents, false),
+				Description:  "The status to wait for: started, stopped or suspended.",
+			},
+			"wait_for_change": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Wait for the node to change to the status even if it is already in it, e.g. for the next start after a reload.",
+			},
+			"timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      300,
+				ValidateFunc: validation.IntBetween(1, 300),
+				Description:  "Seconds to wait for the event before failing (at most 300).",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The status the node was seen in.",
+			},
+			"observed_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "When the event was observed, in RFC 3339 format.",
+			},
+		},
+	}
+}
+
+// nodeNotification is a message of the project notification stream.
+type nodeNotification struct {
+	Action string                 `json:"action"`
+	Event  map[string]interface{} `json:"event"`
+}
+
+func dataSourceGns3NodeEventRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+	nodeID := d.Get("node_id").(string)
+	event := d.Get("event").(string)
+	timeout := time.Duration(d.Get("timeout").(int)) * time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Subscribe before looking at the node so no change is missed in between
+	url := fmt.Sprintf("%s/v2/projects/%s/notifications", config.Host, projectID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create notifications request: %s", err)
+	}
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to follow project notifications: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to follow project notifications, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	observed := func(status string) error {
+		d.SetId(fmt.Sprintf("%s/%s/%s", projectID, nodeID, event))
+		d.Set("status", status)
+		d.Set("observed_at", time.Now().UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	if !d.Get("wait_for_change").(bool) {
+		node, err := getNode(config, projectID, nodeID)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return fmt.Errorf("node %s not found in project %s", nodeID, projectID)
+		}
+		if status, _ := node["status"].(string); status == event {
+			return observed(status)
+		}
+	}
+
+	log.Printf("[INFO] Waiting up to %s for GNS3 node %s to be %s", timeout, nodeID, event)
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var n nodeNotification
+		if err := decoder.Decode(&n); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("node %s was not %s within %s", nodeID, event, timeout)
+			}
+			return fmt.Errorf("notification stream of project %s ended: %s", projectID, err)
+		}
+		if n.Action != "node.updated" || n.Event["node_id"] != nodeID {
+			continue
+		}
+		if status, _ := n.Event["status"].(string); status == event {
+			return observed(status)
+		}
+	}
+}
@@ -38,7 +38,7 @@ func dataSourceGns3NodeIDRead(d *schema.ResourceData, meta interface{}) error {
 	nodeName := d.Get("name").(string)
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes", config.Host, projectID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to fetch nodes from project: %s", err)
 	}
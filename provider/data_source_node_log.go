@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceGns3NodeLog reads the log a node's emulator writes, e.g. to attach
+// boot logs to a failed CI run.
+func dataSourceGns3NodeLog() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3NodeLogRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The UUID of the project the node belongs to.",
+			},
+			"node_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "ID of the node.",
+			},
+			"file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "File to read, relative to the node directory. Defaults to the log of the node type: qemu.log, iou.log or vpcs.log. Docker containers keep no log file; read their console instead.",
+			},
+			"lines": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Only return the last lines of the log. 0 returns all of it.",
+			},
+			"available": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the log exists. It does not until the node was started once, nor for node types that keep no log.",
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The log, empty when it is not available.",
+			},
+		},
+	}
+}
+
+func dataSourceGns3NodeLogRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+	nodeID := d.Get("node_id").(string)
+
+	file := d.Get("file").(string)
+	if file == "" {
+		node, err := getNode(config, projectID, nodeID)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return fmt.Errorf("node %s not found in project %s", nodeID, projectID)
+		}
+		nodeType, _ := node["node_type"].(string)
+		file = nodeLogFiles[nodeType]
+	}
+
+	content, found := "", false
+	if file != "" {
+		var err error
+		if content, found, err = readNodeFile(config, projectID, nodeID, file); err != nil {
+			return err
+		}
+	}
+	if lines := d.Get("lines").(int); lines > 0 && content != "" {
+		content = logTail(content, lines)
+	}
+
+	d.SetId(nodeID)
+	d.Set("file", file)
+	d.Set("available", found)
+	d.Set("content", content)
+	return nil
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceGns3PortsUsage reports how many ports of each node of a project are
+// linked, so modules can pick where to attach new links or when to grow a switch.
+func dataSourceGns3PortsUsage() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3PortsUsageRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The UUID of the project to report on.",
+			},
+			"nodes": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Port usage of each node, sorted by name. Nodes that report no ports have a total of 0.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"total": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of ports of the node.",
+						},
+						"used": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of ports with a link attached.",
+						},
+						"free": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of ports with no link attached.",
+						},
+						"usage_percent": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Share of the ports in use, rounded down, or 0 for a node without ports.",
+						},
+					},
+				},
+			},
+			"free": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Map of node name to its number of free ports.",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceGns3PortsUsageRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return err
+	}
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return fmt.Sprint(nodes[i]["name"]) < fmt.Sprint(nodes[j]["name"]) })
+
+	usage := make([]map[string]interface{}, 0, len(nodes))
+	free := map[string]interface{}{}
+	for _, node := range nodes {
+		total := len(flattenNodePorts(node))
+		available := len(freeNodePorts(node, links))
+		percent := 0
+		if total > 0 {
+			percent = (total - available) * 100 / total
+		}
+		name, _ := node["name"].(string)
+		usage = append(usage, map[string]interface{}{
+			"name":          name,
+			"node_id":       node["node_id"],
+			"node_type":     node["node_type"],
+			"total":         total,
+			"used":          total - available,
+			"free":          available,
+			"usage_percent": percent,
+		})
+		free[name] = available
+	}
+
+	d.SetId(projectID)
+	if err := d.Set("nodes", usage); err != nil {
+		return fmt.Errorf("failed to set nodes: %s", err)
+	}
+	if err := d.Set("free", free); err != nil {
+		return fmt.Errorf("failed to set free: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// projectFileRevision is the .gns3 file format revision written by GNS3 2.2.
+const projectFileRevision = 9
+
+// projectFileSettings are the project settings saved in a .gns3 file.
+var projectFileSettings = []string{
+	"auto_close", "auto_open", "auto_start", "drawing_grid_size", "grid_size", "name", "project_id",
+	"scene_height", "scene_width", "show_grid", "show_interface_labels", "show_layers", "snap_to_grid",
+	"supplier", "variables", "zoom",
+}
+
+// projectFileNodeFields are the node fields saved in a .gns3 file. The others
+// reported by the API, such as status and ports, are runtime state.
+var projectFileNodeFields = []string{
+	"compute_id", "console", "console_auto_start", "console_type", "custom_adapters", "first_port_name",
+	"height", "label", "locked", "name", "node_id", "node_type", "port_name_format", "port_segment_size",
+	"properties", "symbol", "width", "x", "y", "z",
+}
+
+// dataSourceGns3ProjectFile renders a project as a standalone .gns3 file that
+// the GNS3 GUI can open without Terraform.
+func dataSourceGns3ProjectFile() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ProjectFileRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The project to render.",
+			},
+			"node_ids": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "Only include these nodes, e.g. the ones Terraform manages, and the links between them. Defaults to every node.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"include_drawings": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Include the drawings of the project, such as text annotations and node groups.",
+			},
+			"filename": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Suggested file name: the project name with a .gns3 extension.",
+			},
+			"content": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The .gns3 project file.",
+			},
+		},
+	}
+}
+
+// getProject returns a project as reported by the controller.
+func getProject(config *ProviderConfig, projectID string) (map[string]interface{}, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s", config.Host, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to read project, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var project map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
+		return nil, fmt.Errorf("failed to decode project: %s", err)
+	}
+	return project, nil
+}
+
+// listDrawings returns the drawings of a project.
+func listDrawings(config *ProviderConfig, projectID string) ([]Drawing, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s/drawings", config.Host, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drawings: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list drawings, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var drawings []Drawing
+	if err := json.NewDecoder(resp.Body).Decode(&drawings); err != nil {
+		return nil, fmt.Errorf("failed to decode drawings: %s", err)
+	}
+	return drawings, nil
+}
+
+func dataSourceGns3ProjectFileRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	project, err := getProject(config, projectID)
+	if err != nil {
+		return err
+	}
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return err
+	}
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return err
+	}
+
+	var only map[string]bool
+	if set, ok := d.GetOk("node_ids"); ok {
+		only = map[string]bool{}
+		for _, id := range setToStrings(set.(*schema.Set)) {
+			only[id] = true
+		}
+	}
+
+	fileNodes := []map[string]interface{}{}
+	for _, node := range nodes {
+		id, _ := node["node_id"].(string)
+		if only != nil && !only[id] {
+			continue
+		}
+		fileNode := map[string]interface{}{}
+		for _, key := range projectFileNodeFields {
+			if v, ok := node[key]; ok {
+				fileNode[key] = v
+			}
+		}
+		fileNodes = append(fileNodes, fileNode)
+	}
+	sort.Slice(fileNodes, func(i, j int) bool {
+		return fmt.Sprint(fileNodes[i]["node_id"]) < fmt.Sprint(fileNodes[j]["node_id"])
+	})
+
+	fileLinks := []map[string]interface{}{}
+	for _, link := range links {
+		included := true
+		for _, end := range link.Nodes {
+			if only != nil && !only[end.NodeID] {
+				included = false
+			}
+		}
+		if !included {
+			continue
+		}
+		filters := link.Filters
+		if filters == nil {
+			filters = map[string][]int{}
+		}
+		fileLinks = append(fileLinks, map[string]interface{}{
+			"link_id": link.LinkID,
+			"nodes":   link.Nodes,
+			"filters": filters,
+			"suspend": link.Suspend,
+		})
+	}
+	sort.Slice(fileLinks, func(i, j int) bool {
+		return fileLinks[i]["link_id"].(string) < fileLinks[j]["link_id"].(string)
+	})
+
+	drawings := []Drawing{}
+	if d.Get("include_drawings").(bool) {
+		if drawings, err = listDrawings(config, projectID); err != nil {
+			return err
+		}
+		sort.Slice(drawings, func(i, j int) bool { return drawings[i].DrawingID < drawings[j].DrawingID })
+	}
+
+	version := config.APIVersion
+	if version == "" {
+		version = minServerVersion
+	}
+	file := map[string]interface{}{
+		"revision": projectFileRevision,
+		"type":     "topology",
+		"version":  version,
+		"topology": map[string]interface{}{
+			"computes": []interface{}{},
+			"nodes":    fileNodes,
+			"links":    fileLinks,
+			"drawings": drawings,
+		},
+	}
+	for _, key := range projectFileSettings {
+		if v, ok := project[key]; ok {
+			file[key] = v
+		}
+	}
+
+	rendered, err := json.MarshalIndent(file, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode project file: %s", err)
+	}
+
+	name, _ := project["name"].(string)
+	d.SetId(projectID)
+	d.Set("filename", name+".gns3")
+	d.Set("content", string(rendered))
+	return nil
+}
@@ -0,0 +1,244 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// hclLabelInvalid matches the characters not allowed in a resource name.
+var hclLabelInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// hclAttribute is an attribute of a generated block. Value is already
+// rendered as an HCL expression.
+type hclAttribute struct {
+	Key   string
+	Value string
+}
+
+// hclNodeResources are the node types generated as a dedicated resource; the
+// others are generated as gns3_template when they came from a template.
+var hclNodeResources = map[string]string{
+	"qemu":            "gns3_qemu_node",
+	"docker":          "gns3_docker",
+	"cloud":           "gns3_cloud",
+	"ethernet_switch": "gns3_switch",
+}
+
+// dataSourceGns3ProjectHCL suggests configuration and import blocks for an
+// existing project, to bring labs built in the GUI under Terraform.
+func dataSourceGns3ProjectHCL() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ProjectHCLRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The project to generate configuration for.",
+			},
+			"resources": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Suggested resource blocks for the project, its nodes and its links. Review them before use: only the main settings are included.",
+			},
+			"imports": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Import blocks (Terraform 1.5 and later) adopting the existing objects into the suggested resources.",
+			},
+			"unsupported_nodes": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Names of the nodes that no importable resource can manage. They are left out of the suggestions.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// hclString renders s as an HCL string literal.
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+// hclLabel returns a unique resource name derived from name.
+func hclLabel(name string, used map[string]bool) string {
+	label := strings.Trim(hclLabelInvalid.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if label == "" || (label[0] >= '0' && label[0] <= '9') {
+		label = "n_" + label
+	}
+	unique := label
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", label, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// writeHCLBlock appends a block with aligned attributes, as terraform fmt does.
+func writeHCLBlock(b *strings.Builder, header string, attrs []hclAttribute) {
+	width := 0
+	for _, attr := range attrs {
+		if len(attr.Key) > width {
+			width = len(attr.Key)
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(header + " {\n")
+	for _, attr := range attrs {
+		fmt.Fprintf(b, "  %-*s = %s\n", width, attr.Key, attr.Value)
+	}
+	b.WriteString("}\n")
+}
+
+// hclNodeAttributes returns the resource type and the main settings of a node,
+// or an empty type when no importable resource manages it.
+func hclNodeAttributes(node map[string]interface{}, projectRef string) (string, []hclAttribute) {
+	nodeType, _ := node["node_type"].(string)
+	resourceType, ok := hclNodeResources[nodeType]
+	templateID, _ := node["template_id"].(string)
+	if !ok {
+		if templateID == "" {
+			return "", nil
+		}
+		resourceType = "gns3_template"
+	}
+
+	name, _ := node["name"].(string)
+	attrs := []hclAttribute{
+		{"project_id", projectRef},
+		{"name", hclString(name)},
+	}
+	props, _ := node["properties"].(map[string]interface{})
+	switch resourceType {
+	case "gns3_template":
+		attrs = append(attrs, hclAttribute{"template_id", hclString(templateID)})
+	case "gns3_docker":
+		if image, ok := props["image"].(string); ok {
+			attrs = append(attrs, hclAttribute{"image", hclString(image)})
+		}
+		if consoleType, ok := node["console_type"].(string); ok && consoleType != "none" {
+			attrs = append(attrs, hclAttribute{"console_type", hclString(consoleType)})
+		}
+	case "gns3_qemu_node":
+		for _, key := range []string{"platform", "adapter_type", "hda_disk_image"} {
+			if v, ok := props[key].(string); ok && v != "" {
+				attrs = append(attrs, hclAttribute{key, hclString(v)})
+			}
+		}
+		for _, key := range []string{"adapters", "cpus", "ram"} {
+			if _, ok := props[key]; ok {
+				attrs = append(attrs, hclAttribute{key, strconv.Itoa(jsonInt(props[key]))})
+			}
+		}
+	}
+	if computeID, ok := node["compute_id"].(string); ok && computeID != "" && computeID != "local" {
+		attrs = append(attrs, hclAttribute{"compute_id", hclString(computeID)})
+	}
+	attrs = append(attrs,
+		hclAttribute{"x", strconv.Itoa(jsonInt(node["x"]))},
+		hclAttribute{"y", strconv.Itoa(jsonInt(node["y"]))},
+	)
+	return resourceType, attrs
+}
+
+func dataSourceGns3ProjectHCLRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	project, err := getProject(config, projectID)
+	if err != nil {
+		return err
+	}
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return err
+	}
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return fmt.Sprint(nodes[i]["name"]) < fmt.Sprint(nodes[j]["name"])
+	})
+	sort.Slice(links, func(i, j int) bool { return links[i].LinkID < links[j].LinkID })
+
+	var resources, imports strings.Builder
+	used := map[string]bool{}
+
+	projectName, _ := project["name"].(string)
+	projectLabel := hclLabel(projectName, used)
+	projectAddr := "gns3_project." + projectLabel
+	projectRef := projectAddr + ".id"
+	writeHCLBlock(&resources, fmt.Sprintf("resource %q %q", "gns3_project", projectLabel),
+		[]hclAttribute{{"name", hclString(projectName)}})
+	writeHCLBlock(&imports, "import", []hclAttribute{{"to", projectAddr}, {"id", hclString(projectID)}})
+
+	// nodeRefs maps node IDs to the address of their suggested resource
+	nodeRefs := map[string]string{}
+	nodeNames := map[string]string{}
+	unsupported := []string{}
+	for _, node := range nodes {
+		nodeID, _ := node["node_id"].(string)
+		name, _ := node["name"].(string)
+		nodeNames[nodeID] = name
+
+		resourceType, attrs := hclNodeAttributes(node, projectRef)
+		if resourceType == "" {
+			unsupported = append(unsupported, name)
+			continue
+		}
+		label := hclLabel(name, used)
+		addr := resourceType + "." + label
+		nodeRefs[nodeID] = addr
+		writeHCLBlock(&resources, fmt.Sprintf("resource %q %q", resourceType, label), attrs)
+		writeHCLBlock(&imports, "import", []hclAttribute{
+			{"to", addr},
+			{"id", hclString(projectID + "/" + nodeID)},
+		})
+	}
+
+	for _, link := range links {
+		if len(link.Nodes) != 2 {
+			continue
+		}
+		a, b := link.Nodes[0], link.Nodes[1]
+		refA, okA := nodeRefs[a.NodeID]
+		refB, okB := nodeRefs[b.NodeID]
+		if !okA || !okB {
+			continue
+		}
+		label := hclLabel(nodeNames[a.NodeID]+"_to_"+nodeNames[b.NodeID], used)
+		writeHCLBlock(&resources, fmt.Sprintf("resource %q %q", "gns3_link", label), []hclAttribute{
+			{"project_id", projectRef},
+			{"node_a_id", refA + ".id"},
+			{"node_a_adapter", strconv.Itoa(a.AdapterNumber)},
+			{"node_a_port", strconv.Itoa(a.PortNumber)},
+			{"node_b_id", refB + ".id"},
+			{"node_b_adapter", strconv.Itoa(b.AdapterNumber)},
+			{"node_b_port", strconv.Itoa(b.PortNumber)},
+		})
+		writeHCLBlock(&imports, "import", []hclAttribute{
+			{"to", "gns3_link." + label},
+			{"id", hclString(projectID + "/" + link.LinkID)},
+		})
+	}
+
+	d.SetId(projectID)
+	d.Set("resources", resources.String())
+	d.Set("imports", imports.String())
+	if err := d.Set("unsupported_nodes", unsupported); err != nil {
+		return fmt.Errorf("failed to set unsupported_nodes: %s", err)
+	}
+	return nil
+}
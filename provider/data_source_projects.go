@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceGns3Projects lists the projects of the controller matching optional filters.
+func dataSourceGns3Projects() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3ProjectsRead,
+		Schema: map[string]*schema.Schema{
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+				Description:  "Only return projects whose name matches this regular expression.",
+			},
+			"status": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"opened", "closed"}, false),
+				Description:  "Only return projects in this status (opened or closed).",
+			},
+			"ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the matching projects, sorted by name.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"names": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Names of the matching projects, sorted.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"projects": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The matching projects, sorted by name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"project_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"path": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// listProjects returns every project known to the controller.
+func listProjects(config *ProviderConfig) ([]map[string]interface{}, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects", config.Host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list projects, status code: %d", resp.StatusCode)
+	}
+
+	var projects []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&projects); err != nil {
+		return nil, fmt.Errorf("failed to decode projects: %s", err)
+	}
+	return projects, nil
+}
+
+func dataSourceGns3ProjectsRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	projects, err := listProjects(config)
+	if err != nil {
+		return err
+	}
+
+	var nameRe *regexp.Regexp
+	if v, ok := d.GetOk("name_regex"); ok {
+		nameRe = regexp.MustCompile(v.(string))
+	}
+	status := d.Get("status").(string)
+
+	matches := make([]map[string]interface{}, 0, len(projects))
+	for _, p := range projects {
+		name, _ := p["name"].(string)
+		if status != "" && p["status"] != status {
+			continue
+		}
+		if nameRe != nil && !nameRe.MatchString(name) {
+			continue
+		}
+
+		id, _ := p["project_id"].(string)
+		projectStatus, _ := p["status"].(string)
+		path, _ := p["path"].(string)
+		matches = append(matches, map[string]interface{}{
+			"project_id": id,
+			"name":       name,
+			"status":     projectStatus,
+			"path":       path,
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i]["name"].(string) < matches[j]["name"].(string)
+	})
+
+	ids := make([]string, 0, len(matches))
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m["project_id"].(string))
+		names = append(names, m["name"].(string))
+	}
+
+	if err := d.Set("projects", matches); err != nil {
+		return fmt.Errorf("failed to set projects: %s", err)
+	}
+	if err := d.Set("ids", ids); err != nil {
+		return fmt.Errorf("failed to set ids: %s", err)
+	}
+	d.Set("names", names)
+
+	d.SetId(fmt.Sprintf("projects:%s", strings.Join(ids, ",")))
+	return nil
+}
@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// ComputeStatistics is an entry of the controller's /v2/statistics response.
+type ComputeStatistics struct {
+	ComputeID   string `json:"compute_id"`
+	ComputeName string `json:"compute_name"`
+	Statistics  struct {
+		CPUUsagePercent    float64 `json:"cpu_usage_percent"`
+		MemoryUsagePercent float64 `json:"memory_usage_percent"`
+		DiskUsagePercent   float64 `json:"disk_usage_percent"`
+		MemoryTotal        int64   `json:"memory_total"`
+		MemoryUsed         int64   `json:"memory_used"`
+	} `json:"statistics"`
+}
+
+// dataSourceGns3Statistics reports compute usage and the resources allocated to lab nodes.
+func dataSourceGns3Statistics() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3StatisticsRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Limit node aggregates to this project. Defaults to every opened project.",
+			},
+			"computes": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Usage reported by each compute.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"compute_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"compute_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"cpu_usage_percent": {
+							Type:     schema.TypeFloat,
+							Computed: true,
+						},
+						"memory_usage_percent": {
+							Type:     schema.TypeFloat,
+							Computed: true,
+						},
+						"disk_usage_percent": {
+							Type:     schema.TypeFloat,
+							Computed: true,
+						},
+						"memory_total": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"memory_used": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"total_nodes": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of nodes.",
+			},
+			"started_nodes": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of running nodes.",
+			},
+			"nodes_by_type": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Number of nodes per node type.",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"memory_allocated": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "RAM in MB allocated to nodes that declare it (QEMU, VirtualBox, VMware, IOU).",
+			},
+			"cpus_allocated": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "vCPUs allocated to nodes that declare them.",
+			},
+		},
+	}
+}
+
+// getComputeStatistics returns the controller's per-compute statistics. Controllers
+// without the endpoint yield an empty list.
+func getComputeStatistics(config *ProviderConfig) ([]ComputeStatistics, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/statistics", config.Host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statistics: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get statistics, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var stats []ComputeStatistics
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode statistics: %s", err)
+	}
+	return stats, nil
+}
+
+// openedProjectIDs returns the IDs of every opened project.
+func openedProjectIDs(config *ProviderConfig) ([]string, error) {
+	projects, err := listProjects(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, p := range projects {
+		if id, ok := p["project_id"].(string); ok && p["status"] == "opened" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func dataSourceGns3StatisticsRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	stats, err := getComputeStatistics(config)
+	if err != nil {
+		return err
+	}
+	computes := make([]map[string]interface{}, 0, len(stats))
+	for _, s := range stats {
+		computes = append(computes, map[string]interface{}{
+			"compute_id":           s.ComputeID,
+			"compute_name":         s.ComputeName,
+			"cpu_usage_percent":    s.Statistics.CPUUsagePercent,
+			"memory_usage_percent": s.Statistics.MemoryUsagePercent,
+			"disk_usage_percent":   s.Statistics.DiskUsagePercent,
+			"memory_total":         int(s.Statistics.MemoryTotal),
+			"memory_used":          int(s.Statistics.MemoryUsed),
+		})
+	}
+
+	projectIDs := []string{d.Get("project_id").(string)}
+	if projectIDs[0] == "" {
+		if projectIDs, err = openedProjectIDs(config); err != nil {
+			return err
+		}
+	}
+
+	total, started, memory, cpus := 0, 0, 0, 0
+	byType := map[string]interface{}{}
+	for _, projectID := range projectIDs {
+		nodes, err := listProjectNodes(config, projectID)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			total++
+			if node["status"] == "started" {
+				started++
+			}
+			nodeType, _ := node["node_type"].(string)
+			count, _ := byType[nodeType].(int)
+			byType[nodeType] = count + 1
+			if props, ok := node["properties"].(map[string]interface{}); ok {
+				memory += jsonInt(props["ram"])
+				cpus += jsonInt(props["cpus"])
+			}
+		}
+	}
+
+	if err := d.Set("computes", computes); err != nil {
+		return fmt.Errorf("failed to set computes: %s", err)
+	}
+	if err := d.Set("nodes_by_type", byType); err != nil {
+		return fmt.Errorf("failed to set nodes_by_type: %s", err)
+	}
+	d.Set("total_nodes", total)
+	d.Set("started_nodes", started)
+	d.Set("memory_allocated", memory)
+	d.Set("cpus_allocated", cpus)
+
+	if id := d.Get("project_id").(string); id != "" {
+		d.SetId(id)
+	} else {
+		d.SetId("statistics")
+	}
+	return nil
+}
@@ -30,7 +30,7 @@ func dataSourceGns3TemplateIDRead(d *schema.ResourceData, meta interface{}) erro
     templateName := d.Get("name").(string)
 
     // Fetch the list of templates from the GNS3 server
-    resp, err := http.Get(fmt.Sprintf("%s/v2/templates", config.Host))
+    resp, err := config.Client.Get(fmt.Sprintf("%s/v2/templates", config.Host))
     if err != nil {
         return fmt.Errorf("error fetching templates from GNS3 server: %s", err)
     }
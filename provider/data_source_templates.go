@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceGns3Templates lists templates matching optional filters.
+func dataSourceGns3Templates() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3TemplatesRead,
+		Schema: map[string]*schema.Schema{
+			"category": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"router", "switch", "guest", "firewall"}, false),
+				Description:  "Only return templates of this category (router, switch, guest or firewall).",
+			},
+			"template_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return templates of this type (e.g. qemu, docker, dynamips, vpcs).",
+			},
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+				Description:  "Only return templates whose name matches this regular expression.",
+			},
+			"builtin": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "If set, only return builtin (true) or user-defined (false) templates.",
+			},
+			"ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the matching templates, sorted by name.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"templates": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The matching templates, sorted by name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"template_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"category": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"template_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"compute_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"symbol": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"builtin": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceGns3TemplatesRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	templates, err := listTemplates(config)
+	if err != nil {
+		return err
+	}
+
+	var nameRe *regexp.Regexp
+	if v, ok := d.GetOk("name_regex"); ok {
+		nameRe = regexp.MustCompile(v.(string))
+	}
+	category := d.Get("category").(string)
+	templateType := d.Get("template_type").(string)
+	builtin, builtinSet := d.GetOkExists("builtin")
+
+	var matches []map[string]interface{}
+	for _, t := range templates {
+		name, _ := t["name"].(string)
+		if category != "" && t["category"] != category {
+			continue
+		}
+		if templateType != "" && t["template_type"] != templateType {
+			continue
+		}
+		if nameRe != nil && !nameRe.MatchString(name) {
+			continue
+		}
+		if b, _ := t["builtin"].(bool); builtinSet && b != builtin.(bool) {
+			continue
+		}
+
+		id, _ := t["template_id"].(string)
+		b, _ := t["builtin"].(bool)
+		matches = append(matches, map[string]interface{}{
+			"template_id":   id,
+			"name":          name,
+			"category":      t["category"],
+			"template_type": t["template_type"],
+			"compute_id":    t["compute_id"],
+			"symbol":        t["symbol"],
+			"builtin":       b,
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i]["name"].(string) < matches[j]["name"].(string)
+	})
+
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m["template_id"].(string))
+	}
+
+	if err := d.Set("templates", matches); err != nil {
+		return fmt.Errorf("failed to set templates: %s", err)
+	}
+	if err := d.Set("ids", ids); err != nil {
+		return fmt.Errorf("failed to set ids: %s", err)
+	}
+
+	d.SetId(fmt.Sprintf("templates:%s", strings.Join(ids, ",")))
+	return nil
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// topologyNode is a node entry of the exported topology.
+type topologyNode struct {
+	NodeID   string `json:"node_id"`
+	Name     string `json:"name"`
+	NodeType string `json:"node_type"`
+	Status   string `json:"status"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Z        int    `json:"z"`
+}
+
+// topologyEndpoint is one end of an exported link.
+type topologyEndpoint struct {
+	NodeID        string `json:"node_id"`
+	Name          string `json:"name"`
+	AdapterNumber int    `json:"adapter_number"`
+	PortNumber    int    `json:"port_number"`
+}
+
+// topologyLink is a link entry of the exported topology.
+type topologyLink struct {
+	LinkID string             `json:"link_id"`
+	Nodes  []topologyEndpoint `json:"nodes"`
+}
+
+// topologyExport is the document rendered by gns3_topology_export.
+type topologyExport struct {
+	ProjectID string         `json:"project_id"`
+	Nodes     []topologyNode `json:"nodes"`
+	Links     []topologyLink `json:"links"`
+}
+
+// dataSourceGns3TopologyExport renders a project's nodes and links as JSON.
+func dataSourceGns3TopologyExport() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceGns3TopologyExportRead,
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The UUID of the project to export.",
+			},
+			"json": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The topology as JSON: nodes sorted by name with their coordinates, and links sorted by ID with both endpoints.",
+			},
+			"node_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of nodes in the topology.",
+			},
+			"link_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of links in the topology.",
+			},
+		},
+	}
+}
+
+func dataSourceGns3TopologyExportRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return err
+	}
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return err
+	}
+
+	export := topologyExport{
+		ProjectID: projectID,
+		Nodes:     make([]topologyNode, 0, len(nodes)),
+		Links:     make([]topologyLink, 0, len(links)),
+	}
+	names := map[string]string{}
+	for _, node := range nodes {
+		n := topologyNode{
+			X: jsonInt(node["x"]),
+			Y: jsonInt(node["y"]),
+			Z: jsonInt(node["z"]),
+		}
+		n.NodeID, _ = node["node_id"].(string)
+		n.Name, _ = node["name"].(string)
+		n.NodeType, _ = node["node_type"].(string)
+		n.Status, _ = node["status"].(string)
+		names[n.NodeID] = n.Name
+		export.Nodes = append(export.Nodes, n)
+	}
+	sort.Slice(export.Nodes, func(i, j int) bool { return export.Nodes[i].Name < export.Nodes[j].Name })
+
+	for _, link := range links {
+		l := topologyLink{LinkID: link.LinkID, Nodes: make([]topologyEndpoint, 0, len(link.Nodes))}
+		for _, end := range link.Nodes {
+			l.Nodes = append(l.Nodes, topologyEndpoint{
+				NodeID:        end.NodeID,
+				Name:          names[end.NodeID],
+				AdapterNumber: end.AdapterNumber,
+				PortNumber:    end.PortNumber,
+			})
+		}
+		export.Links = append(export.Links, l)
+	}
+	sort.Slice(export.Links, func(i, j int) bool { return export.Links[i].LinkID < export.Links[j].LinkID })
+
+	rendered, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode topology: %s", err)
+	}
+
+	d.SetId(projectID)
+	d.Set("json", string(rendered))
+	d.Set("node_count", len(export.Nodes))
+	d.Set("link_count", len(export.Links))
+	return nil
+}
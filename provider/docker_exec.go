@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dockerExitStatus matches the exit status echoed after each post-start command.
+// The console echoes the command line with a literal $?, so only the shell's
+// output matches.
+var dockerExitStatus = regexp.MustCompile(`__gns3_rc_(\d+)`)
+
+// dockerInterfaceIP matches the IPv4 address in the output of ip addr show.
+var dockerInterfaceIP = regexp.MustCompile(`inet (\d+\.\d+\.\d+\.\d+)/`)
+
+// dockerPostStartCustomizeDiff rejects post_start_commands and discover_ip on
+// containers without a telnet console: GNS3 has no container exec endpoint, so
+// commands are typed into the console shell.
+func dockerPostStartCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("console_type") {
+		return nil
+	}
+	consoleType := d.Get("console_type").(string)
+	if consoleType == "telnet" {
+		return nil
+	}
+	if len(d.Get("post_start_commands").([]interface{})) > 0 {
+		return fmt.Errorf("post_start_commands are run through the container console and need console_type = \"telnet\", got %q", consoleType)
+	}
+	if d.Get("discover_ip").(bool) {
+		return fmt.Errorf("discover_ip reads the address through the container console and needs console_type = \"telnet\", got %q", consoleType)
+	}
+	return nil
+}
+
+// runDockerPostStartCommands types post_start_commands into the console shell of
+// a started container, one at a time, and fails on the first non-zero exit status.
+func runDockerPostStartCommands(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string) error {
+	commands := d.Get("post_start_commands").([]interface{})
+	if len(commands) == 0 {
+		return nil
+	}
+	timeout := time.Duration(d.Get("post_start_timeout").(int)) * time.Second
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+	if status, _ := node["status"].(string); status != "started" {
+		log.Printf("[INFO] Docker node %s is %s, not running post_start_commands", nodeID, status)
+		return nil
+	}
+
+	addr := net.JoinHostPort(consoleHost(config, node), strconv.Itoa(jsonInt(node["console"])))
+	session, err := dialConsole(addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer session.conn.Close()
+
+	for _, raw := range commands {
+		command := raw.(string)
+		log.Printf("[DEBUG] Running post-start command on Docker node %s: %s", nodeID, command)
+		if err := session.send(command + "; echo __gns3_rc_$?"); err != nil {
+			return fmt.Errorf("failed to send post-start command %q: %s", command, err)
+		}
+		out, err := session.expect(dockerExitStatus, timeout)
+		if err != nil {
+			return fmt.Errorf("post-start command %q did not finish: %s\nconsole output:\n%s", command, err, out)
+		}
+		if rc := dockerExitStatus.FindStringSubmatch(out)[1]; rc != "0" {
+			return fmt.Errorf("post-start command %q exited with status %s\nconsole output:\n%s", command, rc, out)
+		}
+	}
+	return nil
+}
+
+// discoverDockerIP reads the IPv4 address of eth0 through the console shell of a
+// started container into first_interface_ip. It polls until DHCP has assigned an
+// address, for at most discover_ip_timeout.
+func discoverDockerIP(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string) error {
+	if !d.Get("discover_ip").(bool) {
+		return d.Set("first_interface_ip", "")
+	}
+	timeout := time.Duration(d.Get("discover_ip_timeout").(int)) * time.Second
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+	if status, _ := node["status"].(string); status != "started" {
+		log.Printf("[INFO] Docker node %s is %s, not discovering its IP address", nodeID, status)
+		return d.Set("first_interface_ip", "")
+	}
+
+	addr := net.JoinHostPort(consoleHost(config, node), strconv.Itoa(jsonInt(node["console"])))
+	session, err := dialConsole(addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer session.conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := session.send("ip -4 addr show dev eth0; echo __gns3_rc_$?"); err != nil {
+			return fmt.Errorf("failed to query the address of eth0: %s", err)
+		}
+		out, err := session.expect(dockerExitStatus, time.Until(deadline))
+		if err != nil {
+			return fmt.Errorf("failed to query the address of eth0: %s\nconsole output:\n%s", err, out)
+		}
+		if match := dockerInterfaceIP.FindStringSubmatch(out); match != nil {
+			return d.Set("first_interface_ip", match[1])
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("eth0 of Docker node %s got no IPv4 address within %s\nconsole output:\n%s", nodeID, timeout, out)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+// dryRunIDPrefix marks IDs invented by dry_run mode so they are easy to spot in state.
+const dryRunIDPrefix = "dryrun-"
+
+// dryRunStore keeps the objects "created" during a dry run so later reads of them succeed.
+type dryRunStore struct {
+	mu      sync.Mutex
+	objects map[string]map[string]interface{}
+}
+
+func newDryRunStore() *dryRunStore {
+	return &dryRunStore{objects: map[string]map[string]interface{}{}}
+}
+
+// dryRunCollections maps a collection path segment to the ID field the controller returns.
+var dryRunCollections = map[string]string{
+	"projects":  "project_id",
+	"nodes":     "node_id",
+	"links":     "link_id",
+	"drawings":  "drawing_id",
+	"snapshots": "snapshot_id",
+}
+
+// dryRunActions are POST endpoints that trigger an action instead of creating an object.
+var dryRunActions = map[string]bool{
+	"start":   true,
+	"stop":    true,
+	"suspend": true,
+	"reload":  true,
+	"open":    true,
+	"close":   true,
+	"restore": true,
+}
+
+// do answers a request without contacting the controller for anything that would
+// change it. GETs are passed through to next, merged with the synthetic objects.
+func (s *dryRunStore) do(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	path := strings.TrimRight(req.URL.Path, "/")
+
+	// Binary bodies such as image uploads are left unread: they can be gigabytes
+	var body map[string]interface{}
+	if req.Body != nil && req.Header.Get("Content-Type") != "application/octet-stream" {
+		raw, _ := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		_ = json.Unmarshal(raw, &body)
+	}
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+
+	if req.Method == "GET" {
+		return s.get(req, path, next)
+	}
+
+	log.Printf("[INFO] dry_run: skipping %s %s %v (request %s)", req.Method, req.URL.String(), body, requestID(req))
+
+	var current map[string]interface{}
+	if req.Method == "PUT" {
+		current = s.current(req, path, next)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Method {
+	case "DELETE":
+		delete(s.objects, path)
+		return dryRunResponse(req, http.StatusNoContent, nil), nil
+	case "PUT":
+		// Like the controller, merge properties and replace other fields
+		for k, v := range body {
+			props, isProps := v.(map[string]interface{})
+			old, hasProps := current[k].(map[string]interface{})
+			if k == "properties" && isProps && hasProps {
+				for pk, pv := range props {
+					old[pk] = pv
+				}
+				continue
+			}
+			current[k] = v
+		}
+		s.objects[path] = current
+		return dryRunResponse(req, http.StatusOK, current), nil
+	}
+
+	segments := strings.Split(strings.TrimPrefix(path, "/v2/"), "/")
+	last := segments[len(segments)-1]
+	if dryRunActions[last] {
+		return dryRunResponse(req, http.StatusOK, map[string]interface{}{}), nil
+	}
+
+	// Work out which collection the new object lands in
+	collection := path
+	idKey := dryRunCollections[last]
+	switch {
+	case len(segments) == 4 && segments[0] == "projects" && segments[2] == "templates":
+		collection = fmt.Sprintf("/v2/projects/%s/nodes", segments[1])
+		idKey = "node_id"
+	case last == "duplicate" && len(segments) == 5:
+		collection = fmt.Sprintf("/v2/projects/%s/nodes", segments[1])
+		idKey = "node_id"
+	}
+	if idKey == "" || segments[0] != "projects" {
+		return dryRunResponse(req, http.StatusCreated, body), nil
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	body[idKey] = dryRunIDPrefix + id
+	if len(segments) > 1 && idKey != "project_id" {
+		body["project_id"] = segments[1]
+	}
+	s.objects[collection+"/"+body[idKey].(string)] = body
+	return dryRunResponse(req, http.StatusCreated, body), nil
+}
+
+// current returns the object a PUT changes: the synthetic one, else the one the
+// controller holds, so the reply and later reads show the whole updated object.
+func (s *dryRunStore) current(req *http.Request, path string, next func(*http.Request) (*http.Response, error)) map[string]interface{} {
+	s.mu.Lock()
+	obj, ok := s.objects[path]
+	s.mu.Unlock()
+	if ok || strings.Contains(path, dryRunIDPrefix) {
+		if obj == nil {
+			obj = map[string]interface{}{}
+		}
+		return obj
+	}
+
+	obj = map[string]interface{}{}
+	get, err := http.NewRequest("GET", req.URL.String(), nil)
+	if err != nil {
+		return obj
+	}
+	get.Header = req.Header.Clone()
+	resp, err := next(get)
+	if err != nil {
+		log.Printf("[WARN] dry_run: cannot read %s to apply %s to it: %s", path, req.Method, err)
+		return obj
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+			obj = map[string]interface{}{}
+		}
+	}
+	return obj
+}
+
+// get serves synthetic objects and adds them to listings returned by the controller.
+func (s *dryRunStore) get(req *http.Request, path string, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	s.mu.Lock()
+	obj, ok := s.objects[path]
+	var children []interface{}
+	for p, o := range s.objects {
+		if strings.HasPrefix(p, path+"/") && !strings.Contains(strings.TrimPrefix(p, path+"/"), "/") {
+			children = append(children, o)
+		}
+	}
+	s.mu.Unlock()
+
+	if ok {
+		return dryRunResponse(req, http.StatusOK, obj), nil
+	}
+	if strings.Contains(path, dryRunIDPrefix) {
+		// Anything below a synthetic object only exists in this dry run
+		if len(children) > 0 || dryRunCollections[path[strings.LastIndex(path, "/")+1:]] != "" {
+			return dryRunResponse(req, http.StatusOK, append([]interface{}{}, children...)), nil
+		}
+		return dryRunResponse(req, http.StatusNotFound, map[string]interface{}{"message": "not found (dry run)"}), nil
+	}
+
+	resp, err := next(req)
+	if err != nil || len(children) == 0 || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var list []interface{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		return resp, nil
+	}
+	// Objects changed by a PUT replace their listed version, new ones are added
+	idKey := dryRunCollections[path[strings.LastIndex(path, "/")+1:]]
+	index := map[interface{}]int{}
+	for i, item := range list {
+		if obj, ok := item.(map[string]interface{}); ok && obj[idKey] != nil {
+			index[obj[idKey]] = i
+		}
+	}
+	for _, child := range children {
+		if i, ok := index[child.(map[string]interface{})[idKey]]; ok {
+			list[i] = child
+		} else {
+			list = append(list, child)
+		}
+	}
+	return dryRunResponse(req, http.StatusOK, list), nil
+}
+
+func dryRunResponse(req *http.Request, status int, payload interface{}) *http.Response {
+	var data []byte
+	if payload != nil {
+		data, _ = json.Marshal(payload)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// qemuAdapterCount returns the number of adapters of a QEMU node, including the
+// management adapter appended after the configured ones.
+func qemuAdapterCount(d *schema.ResourceData) int {
+	adapters := d.Get("adapters").(int)
+	if d.Get("management_network").(bool) {
+		adapters++
+	}
+	return adapters
+}
+
+// dropManagementNetwork removes the NAT node of the management network when it
+// is disabled or its adapter is renumbered; GNS3 drops its link with it. It runs
+// before the adapters of the node are updated.
+func dropManagementNetwork(d *schema.ResourceData, config *ProviderConfig, projectID string) error {
+	if !d.HasChanges("management_network", "adapters") {
+		return nil
+	}
+	natID := d.Get("management_nat_node_id").(string)
+	if natID == "" {
+		return nil
+	}
+	if err := deleteNode(config, projectID, natID); err != nil {
+		return fmt.Errorf("failed to remove management NAT node: %s", err)
+	}
+	d.Set("management_nat_node_id", "")
+	d.Set("management_link_id", "")
+	return nil
+}
+
+// attachManagementNetwork creates a NAT node and links it to the management
+// adapter, the one after the configured adapters.
+func attachManagementNetwork(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string) error {
+	if !d.Get("management_network").(bool) || d.Get("management_nat_node_id").(string) != "" {
+		return nil
+	}
+
+	name := d.Get("name").(string)
+	natID, err := createNode(config, projectID, map[string]interface{}{
+		"name":       name + "-mgmt",
+		"node_type":  "nat",
+		"compute_id": "local",
+		"x":          d.Get("x").(int),
+		"y":          d.Get("y").(int) - 100,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create management NAT node for %s: %s", name, err)
+	}
+
+	linkID, err := createLink(config, projectID,
+		LinkNode{NodeID: nodeID, AdapterNumber: d.Get("adapters").(int), PortNumber: 0},
+		LinkNode{NodeID: natID, AdapterNumber: 0, PortNumber: 0},
+	)
+	if err != nil {
+		_ = deleteNode(config, projectID, natID)
+		return fmt.Errorf("failed to link node to management NAT node: %s", err)
+	}
+
+	d.Set("management_nat_node_id", natID)
+	d.Set("management_link_id", linkID)
+	return nil
+}
+
+// managementMACAddress returns the MAC address of the management adapter of a
+// node, or an empty string without a management network.
+func managementMACAddress(d *schema.ResourceData, node map[string]interface{}) string {
+	if !d.Get("management_network").(bool) {
+		return ""
+	}
+	macs := portMACAddresses(node)
+	if adapter := d.Get("adapters").(int); adapter < len(macs) {
+		return macs[adapter]
+	}
+	return ""
+}
@@ -0,0 +1,632 @@
+package provider
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockRequest is a request received by the mock controller.
+type mockRequest struct {
+	Method    string
+	Path      string
+	Body      map[string]interface{}
+	RequestID string
+}
+
+// mockController is an in-memory GNS3 v2 controller serving canned responses
+// shaped like the real API, so resources can be exercised without a server.
+type mockController struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	objects   map[string]map[string]interface{}
+	order     []string
+	templates []map[string]interface{}
+	// appliances are served as-is at GET /v2/appliances.
+	appliances []map[string]interface{}
+	computes   []map[string]interface{}
+	// computeData holds the replies of compute endpoints the controller forwards, by path
+	computeData map[string]interface{}
+	images      map[string][]map[string]interface{}
+	files       map[string][]byte
+	// crashes is how many more starts of a node, by node ID, leave it stopped
+	crashes map[string]int
+	// deleteErrors fails the next DELETE of a path with a status code. The
+	// object is deleted all the same, like a delete racing with another one,
+	// unless the code is 409, which the controller returns when it refuses.
+	deleteErrors map[string]int
+	requests     []mockRequest
+	nextID       int
+	// listeners receive the notifications of a project, keyed by project ID.
+	listeners map[string][]chan map[string]interface{}
+}
+
+// mockCollections maps a project sub-collection to the ID field of its objects.
+var mockCollections = map[string]string{
+	"nodes":     "node_id",
+	"links":     "link_id",
+	"drawings":  "drawing_id",
+	"snapshots": "snapshot_id",
+}
+
+// newMockController starts a mock controller that is shut down with the test.
+func newMockController(t *testing.T) *mockController {
+	m := &mockController{
+		objects:      map[string]map[string]interface{}{},
+		listeners:    map[string][]chan map[string]interface{}{},
+		files:        map[string][]byte{},
+		crashes:      map[string]int{},
+		deleteErrors: map[string]int{},
+		images:       map[string][]map[string]interface{}{},
+		templates: []map[string]interface{}{
+			{"template_id": "tmpl-router", "name": "VyOS", "category": "router", "template_type": "qemu", "builtin": false},
+			{"template_id": "tmpl-switch", "name": "Ethernet switch", "category": "switch", "template_type": "ethernet_switch", "builtin": true},
+		},
+		computes: []map[string]interface{}{
+			{"compute_id": "local", "name": "local", "connected": true},
+		},
+		computeData: map[string]interface{}{},
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// config returns a provider configuration pointing at the mock controller.
+func (m *mockController) config() *ProviderConfig {
+	return newProviderConfig(m.server.URL, newClient("", nil))
+}
+
+// addProject seeds an opened project and returns its ID.
+func (m *mockController) addProject(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj := m.newProject(map[string]interface{}{"name": name})
+	return obj["project_id"].(string)
+}
+
+// addNode seeds a node in a project and returns its ID.
+func (m *mockController) addNode(projectID, name, nodeType string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj := m.newNode(projectID, map[string]interface{}{"name": name, "node_type": nodeType, "compute_id": "local"})
+	return obj["node_id"].(string)
+}
+
+// object returns a stored object, or nil.
+func (m *mockController) object(path string) map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.objects[path]
+}
+
+// lastRequest returns the most recent request matching method and path.
+func (m *mockController) lastRequest(method, path string) *mockRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.requests) - 1; i >= 0; i-- {
+		if m.requests[i].Method == method && m.requests[i].Path == path {
+			return &m.requests[i]
+		}
+	}
+	return nil
+}
+
+// restart takes the controller down for downFor, then serves again on the same
+// address with every project closed, like a restarted GNS3 controller.
+func (m *mockController) restart(t *testing.T, downFor time.Duration) {
+	addr := m.server.Listener.Addr().String()
+	m.server.Close()
+
+	m.mu.Lock()
+	for path, obj := range m.objects {
+		if _, ok := obj["project_id"]; ok && strings.Count(path, "/") == 3 {
+			obj["status"] = "closed"
+		}
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(downFor)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("failed to listen on %s again: %s", addr, err)
+			return
+		}
+		m.server = &httptest.Server{Listener: l, Config: &http.Server{Handler: http.HandlerFunc(m.serve)}}
+		m.server.Start()
+	}()
+	t.Cleanup(func() {
+		<-done
+		m.server.Close()
+	})
+}
+
+func (m *mockController) id(prefix string) string {
+	m.nextID++
+	return fmt.Sprintf("%s-%04d", prefix, m.nextID)
+}
+
+func (m *mockController) store(path string, obj map[string]interface{}) {
+	if _, ok := m.objects[path]; !ok {
+		m.order = append(m.order, path)
+	}
+	m.objects[path] = obj
+}
+
+func (m *mockController) remove(path string) {
+	for _, p := range m.order {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(m.objects, p)
+		}
+	}
+}
+
+// children lists the stored objects directly below a collection path, in creation order.
+func (m *mockController) children(path string) []interface{} {
+	list := []interface{}{}
+	for _, p := range m.order {
+		obj, ok := m.objects[p]
+		if ok && strings.HasPrefix(p, path+"/") && !strings.Contains(strings.TrimPrefix(p, path+"/"), "/") {
+			list = append(list, obj)
+		}
+	}
+	return list
+}
+
+func (m *mockController) newProject(body map[string]interface{}) map[string]interface{} {
+	obj := map[string]interface{}{
+		"project_id": m.id("project"),
+		"name":       body["name"],
+		"status":     "opened",
+		"path":       "/opt/gns3/projects/" + fmt.Sprint(body["name"]),
+
+		"scene_width":       2000,
+		"scene_height":      1000,
+		"grid_size":         75,
+		"drawing_grid_size": 25,
+		"zoom":              100,
+		"show_grid":         false,
+		"snap_to_grid":      false,
+	}
+	for k, v := range body {
+		if _, known := obj[k]; known && k != "project_id" && k != "status" {
+			obj[k] = v
+		}
+	}
+	if p, ok := body["path"].(string); !ok || p == "" {
+		obj["path"] = "/opt/gns3/projects/" + fmt.Sprint(body["name"])
+	}
+	m.store("/v2/projects/"+obj["project_id"].(string), obj)
+	return obj
+}
+
+func (m *mockController) newNode(projectID string, body map[string]interface{}) map[string]interface{} {
+	obj := map[string]interface{}{}
+	for k, v := range body {
+		obj[k] = v
+	}
+	// The controller keeps a node_id the client pre-allocated
+	if _, ok := obj["node_id"].(string); !ok {
+		obj["node_id"] = m.id("node")
+	}
+	obj["project_id"] = projectID
+	obj["status"] = "stopped"
+	// Builtin nodes have nothing to boot and are always started
+	switch obj["node_type"] {
+	case "ethernet_switch", "ethernet_hub", "cloud", "nat":
+		obj["status"] = "started"
+	}
+	if _, ok := obj["symbol"]; !ok {
+		obj["symbol"] = fmt.Sprintf(":/symbols/%v.svg", obj["node_type"])
+	}
+	if _, ok := obj["label"]; !ok {
+		obj["label"] = map[string]interface{}{"text": obj["name"], "style": "font-size: 10.0;", "x": 5, "y": -25, "rotation": 0}
+	}
+	obj["console"] = 5000 + m.nextID
+	if props, _ := obj["properties"].(map[string]interface{}); jsonInt(props["console"]) > 0 {
+		obj["console"] = jsonInt(props["console"])
+	}
+	obj["console_host"] = "0.0.0.0"
+	if _, ok := obj["console_type"]; !ok {
+		obj["console_type"] = "telnet"
+	}
+	// Copy properties so later updates do not rewrite the recorded request
+	props := map[string]interface{}{}
+	if p, ok := obj["properties"].(map[string]interface{}); ok {
+		for k, v := range p {
+			props[k] = v
+		}
+	}
+	obj["properties"] = props
+	adapters := 1
+	if props, _ := obj["properties"].(map[string]interface{}); jsonInt(props["adapters"]) > 0 {
+		adapters = jsonInt(props["adapters"])
+	}
+	var ports []interface{}
+	for i := 0; i < adapters; i++ {
+		ports = append(ports, map[string]interface{}{
+			"name": fmt.Sprintf("eth%d", i), "short_name": fmt.Sprintf("e%d", i), "adapter_number": i, "port_number": 0, "link_type": "ethernet",
+			"mac_address": fmt.Sprintf("02:42:00:00:%02x:%02x", m.nextID, i),
+		})
+	}
+	// Switches and hubs have their ports on adapter 0, like GNS3 reports them
+	if obj["node_type"] == "ethernet_switch" || obj["node_type"] == "ethernet_hub" {
+		ports = nil
+		for i := 0; i < 8; i++ {
+			ports = append(ports, map[string]interface{}{
+				"name": fmt.Sprintf("Ethernet%d", i), "short_name": fmt.Sprintf("e%d", i), "adapter_number": 0, "port_number": i, "link_type": "ethernet",
+			})
+		}
+	}
+	obj["ports"] = ports
+	m.store(fmt.Sprintf("/v2/projects/%s/nodes/%s", projectID, obj["node_id"]), obj)
+	return obj
+}
+
+func (m *mockController) reply(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if payload != nil {
+		_ = json.NewEncoder(w).Encode(payload)
+	}
+}
+
+// notify sends a notification to the streams following a project. Callers hold m.mu.
+func (m *mockController) notify(projectID, action string, event map[string]interface{}) {
+	copied := map[string]interface{}{}
+	for k, v := range event {
+		copied[k] = v
+	}
+	for _, l := range m.listeners[projectID] {
+		select {
+		case l <- map[string]interface{}{"action": action, "event": copied}:
+		default:
+		}
+	}
+}
+
+// streamNotifications serves a project notification stream, one JSON message per
+// line, until the client goes away.
+func (m *mockController) streamNotifications(w http.ResponseWriter, r *http.Request, path string) {
+	projectID := strings.Split(strings.TrimPrefix(path, "/v2/"), "/")[1]
+	l := make(chan map[string]interface{}, 16)
+
+	m.mu.Lock()
+	m.requests = append(m.requests, mockRequest{Method: r.Method, Path: path, RequestID: r.Header.Get(requestIDHeader)})
+	if _, ok := m.objects["/v2/projects/"+projectID]; !ok {
+		m.mu.Unlock()
+		m.notFound(w)
+		return
+	}
+	m.listeners[projectID] = append(m.listeners[projectID], l)
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case n := <-l:
+			_ = enc.Encode(n)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (m *mockController) notFound(w http.ResponseWriter) {
+	m.reply(w, http.StatusNotFound, map[string]interface{}{"message": "not found", "status": 404})
+}
+
+func (m *mockController) serve(w http.ResponseWriter, r *http.Request) {
+	raw, _ := ioutil.ReadAll(r.Body)
+	var body map[string]interface{}
+	_ = json.Unmarshal(raw, &body)
+
+	path := strings.TrimRight(r.URL.Path, "/")
+	if strings.HasSuffix(path, "/notifications") && r.Method == "GET" {
+		m.streamNotifications(w, r, path)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, mockRequest{Method: r.Method, Path: path, Body: body, RequestID: r.Header.Get(requestIDHeader)})
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+	seg := strings.Split(strings.TrimPrefix(path, "/v2/"), "/")
+
+	switch {
+	case path == "/v2/version":
+		m.reply(w, http.StatusOK, map[string]interface{}{"version": "2.2.44", "local": true})
+	case path == "/v2/templates" && r.Method == "GET":
+		m.reply(w, http.StatusOK, m.templates)
+	case path == "/v2/appliances" && r.Method == "GET":
+		appliances := m.appliances
+		if appliances == nil {
+			appliances = []map[string]interface{}{}
+		}
+		m.reply(w, http.StatusOK, appliances)
+	case path == "/v2/iou_license" && r.Method == "GET":
+		license, ok := m.objects[path]
+		if !ok {
+			license = map[string]interface{}{"iourc_content": "", "license_check": true}
+		}
+		m.reply(w, http.StatusOK, license)
+	case path == "/v2/iou_license" && r.Method == "PUT":
+		m.objects[path] = body
+		m.reply(w, http.StatusOK, body)
+	case path == "/v2/computes" && r.Method == "GET":
+		m.reply(w, http.StatusOK, m.computes)
+	case seg[0] == "computes" && len(seg) == 2 && r.Method == "GET":
+		for _, c := range m.computes {
+			if c["compute_id"] == seg[1] {
+				m.reply(w, http.StatusOK, c)
+				return
+			}
+		}
+		m.notFound(w)
+	case seg[0] == "computes" && m.computeData[path] != nil && r.Method == "GET":
+		m.reply(w, http.StatusOK, m.computeData[path])
+	case seg[0] == "computes" && len(seg) == 4 && seg[2] == "network" && seg[3] == "interfaces" && r.Method == "GET":
+		m.reply(w, http.StatusOK, []map[string]interface{}{
+			{"id": "eth1", "name": "eth1", "type": "ethernet", "ip_address": "10.0.0.2", "mac_address": "00:50:56:00:00:02", "special": false},
+			{"id": "lo", "name": "lo", "type": "ethernet", "ip_address": "127.0.0.1", "mac_address": "", "special": true},
+			{"id": "eth0", "name": "eth0", "type": "ethernet", "ip_address": "10.0.0.1", "mac_address": "00:50:56:00:00:01", "special": false},
+		})
+	case seg[0] == "compute" && len(seg) == 7 && seg[4] == "nodes" && seg[6] == "resize_disk" && r.Method == "POST":
+		// Like GNS3, only resize the disks of a stopped VM
+		node, ok := m.objects[fmt.Sprintf("/v2/projects/%s/nodes/%s", seg[2], seg[5])]
+		if !ok {
+			m.notFound(w)
+			return
+		}
+		if node["status"] == "started" {
+			m.reply(w, http.StatusConflict, map[string]interface{}{"message": "Cannot resize hda while the VM is running"})
+			return
+		}
+		m.reply(w, http.StatusCreated, nil)
+	case path == "/v2/compute/projects" && r.Method == "POST":
+		m.reply(w, http.StatusCreated, body)
+	case seg[0] == "computes" && len(seg) == 4 && seg[3] == "images" && r.Method == "GET":
+		images := m.images[seg[1]+"/"+seg[2]]
+		if images == nil {
+			images = []map[string]interface{}{}
+		}
+		m.reply(w, http.StatusOK, images)
+	case seg[0] == "computes" && len(seg) == 5 && seg[3] == "images" && r.Method == "POST":
+		sum := md5.Sum(raw)
+		key := seg[1] + "/" + seg[2]
+		m.images[key] = append(m.images[key], map[string]interface{}{
+			"filename": seg[4],
+			"path":     seg[4],
+			"md5sum":   hex.EncodeToString(sum[:]),
+			"filesize": len(raw),
+		})
+		m.reply(w, http.StatusNoContent, nil)
+	case path == "/v2/projects" && r.Method == "GET":
+		m.reply(w, http.StatusOK, m.children(path))
+	case path == "/v2/projects" && r.Method == "POST":
+		m.reply(w, http.StatusCreated, m.newProject(body))
+	case seg[0] == "projects" && len(seg) >= 4 && seg[2] == "files" && r.Method == "GET":
+		content, ok := m.files[path]
+		if !ok {
+			m.notFound(w)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	case seg[0] == "projects" && len(seg) >= 4 && seg[2] == "files" && r.Method == "POST":
+		m.files[path] = raw
+		m.reply(w, http.StatusOK, nil)
+	case seg[0] == "projects" && len(seg) >= 2:
+		m.serveProject(w, r, path, seg, body)
+	default:
+		m.notFound(w)
+	}
+}
+
+func (m *mockController) serveProject(w http.ResponseWriter, r *http.Request, path string, seg []string, body map[string]interface{}) {
+	projectID := seg[1]
+	projectPath := "/v2/projects/" + projectID
+	project, ok := m.objects[projectPath]
+	if !ok {
+		m.notFound(w)
+		return
+	}
+
+	// Like GNS3, refuse to change the contents of a closed project or to look up
+	// one of its nodes
+	readsNode := r.Method == "GET" && len(seg) >= 4 && seg[2] == "nodes"
+	if project["status"] == "closed" && (r.Method != "GET" || readsNode) && len(seg) > 2 && seg[2] != "open" && seg[2] != "close" {
+		m.reply(w, http.StatusForbidden, map[string]interface{}{"message": "The project is not opened", "status": http.StatusForbidden})
+		return
+	}
+
+	switch {
+	case len(seg) == 3 && (seg[2] == "open" || seg[2] == "close") && r.Method == "POST":
+		if seg[2] == "close" {
+			project["status"] = "closed"
+			m.reply(w, http.StatusNoContent, nil)
+			return
+		}
+		project["status"] = "opened"
+		m.reply(w, http.StatusCreated, project)
+	case len(seg) == 4 && seg[2] == "templates" && r.Method == "POST":
+		for _, tmpl := range m.templates {
+			if tmpl["template_id"] == seg[3] {
+				node := m.newNode(projectID, map[string]interface{}{
+					"name":        body["name"],
+					"template_id": seg[3],
+					"node_type":   tmpl["template_type"],
+					"compute_id":  body["compute_id"],
+					"x":           body["x"],
+					"y":           body["y"],
+				})
+				m.reply(w, http.StatusCreated, node)
+				return
+			}
+		}
+		m.notFound(w)
+	case len(seg) == 4 && seg[2] == "nodes" && r.Method == "POST":
+		// Bulk actions such as /nodes/start
+		for _, n := range m.children(projectPath + "/nodes") {
+			if seg[3] == "start" {
+				n.(map[string]interface{})["status"] = "started"
+			} else if seg[3] == "stop" {
+				n.(map[string]interface{})["status"] = "stopped"
+			}
+		}
+		m.reply(w, http.StatusNoContent, nil)
+	case len(seg) >= 6 && seg[2] == "nodes" && seg[4] == "files" && r.Method == "GET":
+		content, ok := m.files[path]
+		if !ok {
+			m.notFound(w)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	case len(seg) == 5 && seg[2] == "nodes" && r.Method == "POST":
+		node, ok := m.objects[strings.Join(append([]string{"/v2"}, seg[:4]...), "/")]
+		if !ok {
+			m.notFound(w)
+			return
+		}
+		switch seg[4] {
+		case "start", "reload":
+			node["status"] = "started"
+			if m.crashes[seg[3]] > 0 {
+				m.crashes[seg[3]]--
+				node["status"] = "stopped"
+			}
+			m.notify(projectID, "node.updated", node)
+		case "stop":
+			node["status"] = "stopped"
+			m.notify(projectID, "node.updated", node)
+		case "suspend":
+			node["status"] = "suspended"
+			m.notify(projectID, "node.updated", node)
+		case "duplicate":
+			source := map[string]interface{}{}
+			for k, v := range node {
+				source[k] = v
+			}
+			delete(source, "node_id")
+			clone := m.newNode(projectID, source)
+			clone["x"], clone["y"], clone["z"] = body["x"], body["y"], body["z"]
+			m.reply(w, http.StatusCreated, clone)
+			return
+		default:
+			m.notFound(w)
+			return
+		}
+		m.reply(w, http.StatusOK, node)
+	case len(seg) == 5 && seg[2] == "links" && seg[4] == "available_filters" && r.Method == "GET":
+		// The filters offered by GNS3 2.2; there is no bandwidth filter
+		m.reply(w, http.StatusOK, []map[string]interface{}{
+			{"type": "frequency_drop", "name": "Frequency drop"},
+			{"type": "packet_loss", "name": "Packet loss"},
+			{"type": "delay", "name": "Delay"},
+			{"type": "corrupt", "name": "Corrupt"},
+			{"type": "bpf", "name": "Berkeley Packet Filter (BPF)"},
+		})
+	case len(seg) == 5 && seg[2] == "snapshots" && seg[4] == "restore" && r.Method == "POST":
+		m.reply(w, http.StatusCreated, project)
+	case len(seg) == 2:
+		m.serveObject(w, r, path, body)
+	case len(seg) == 3 && mockCollections[seg[2]] != "":
+		switch r.Method {
+		case "GET":
+			m.reply(w, http.StatusOK, m.children(path))
+		case "POST":
+			if seg[2] == "nodes" {
+				m.reply(w, http.StatusCreated, m.newNode(projectID, body))
+				return
+			}
+			idKey := mockCollections[seg[2]]
+			body[idKey] = m.id(strings.TrimSuffix(seg[2], "s"))
+			body["project_id"] = projectID
+			if seg[2] == "snapshots" {
+				body["created_at"] = 1700000000
+			}
+			m.store(path+"/"+body[idKey].(string), body)
+			m.reply(w, http.StatusCreated, body)
+		default:
+			m.notFound(w)
+		}
+	case len(seg) == 4 && mockCollections[seg[2]] != "":
+		m.serveObject(w, r, path, body)
+	default:
+		m.notFound(w)
+	}
+}
+
+// serveObject handles GET, PUT and DELETE on a single stored object.
+func (m *mockController) serveObject(w http.ResponseWriter, r *http.Request, path string, body map[string]interface{}) {
+	obj, ok := m.objects[path]
+	if !ok {
+		m.notFound(w)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		m.reply(w, http.StatusOK, obj)
+	case "PUT":
+		for k, v := range body {
+			props, isProps := v.(map[string]interface{})
+			current, hasProps := obj[k].(map[string]interface{})
+			if k == "properties" && isProps && hasProps {
+				for pk, pv := range props {
+					current[pk] = pv
+				}
+				continue
+			}
+			obj[k] = v
+		}
+		m.reply(w, http.StatusOK, obj)
+	case "DELETE":
+		status, failing := m.deleteErrors[path]
+		delete(m.deleteErrors, path)
+		if failing && status == http.StatusConflict {
+			m.reply(w, status, map[string]interface{}{"message": "delete refused"})
+			return
+		}
+		m.remove(path)
+		// Deleting a node deletes its links
+		if nodeID, ok := obj["node_id"]; ok {
+			for _, link := range m.children(strings.Replace(path, "/nodes/"+nodeID.(string), "/links", 1)) {
+				for _, end := range link.(map[string]interface{})["nodes"].([]interface{}) {
+					if end.(map[string]interface{})["node_id"] == nodeID {
+						m.remove(fmt.Sprintf("/v2/projects/%s/links/%s", obj["project_id"], link.(map[string]interface{})["link_id"]))
+						break
+					}
+				}
+			}
+		}
+		if failing {
+			m.reply(w, status, map[string]interface{}{"message": "concurrent delete"})
+			return
+		}
+		m.reply(w, http.StatusNoContent, nil)
+	default:
+		m.notFound(w)
+	}
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// nodeLogFiles maps a node type to the file its emulator writes its output to,
+// relative to the node directory. Other node types keep no log of their own.
+var nodeLogFiles = map[string]string{
+	"qemu": "qemu.log",
+	"iou":  "iou.log",
+	"vpcs": "vpcs.log",
+}
+
+// readNodeLog returns the log of a node through the node files endpoint, or an
+// empty string when its node type keeps no log.
+func readNodeLog(config *ProviderConfig, projectID string, node map[string]interface{}) (string, error) {
+	nodeType, _ := node["node_type"].(string)
+	file, ok := nodeLogFiles[nodeType]
+	if !ok {
+		return "", nil
+	}
+	nodeID, _ := node["node_id"].(string)
+	content, _, err := readNodeFile(config, projectID, nodeID, file)
+	return content, err
+}
+
+// readNodeFile returns a file of the node directory. found is false when the
+// file does not exist, e.g. a log of a node that was never started.
+func readNodeFile(config *ProviderConfig, projectID, nodeID, file string) (content string, found bool, err error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s/nodes/%s/files/%s", config.Host, projectID, nodeID, file))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read node file %s: %s", file, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("failed to read node file %s, status code: %d, response: %s", file, resp.StatusCode, string(body))
+	}
+	return string(body), true, nil
+}
+
+// logTail returns the last n lines of a log.
+func logTail(content string, n int) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,329 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// projectLockFile is written to the directory of a project while a run changes it.
+const projectLockFile = "terraform-apply.lock"
+
+// projectLockTTL is how long a lock holds without being refreshed, so the lock of
+// an interrupted run expires on its own.
+var projectLockTTL = 2 * time.Minute
+
+// projectLockGrace is how long a node no request of this run claimed may exist
+// before it is reported as added by another writer. A node the run creates is
+// only claimed once the controller answers the create.
+var projectLockGrace = 10 * time.Second
+
+// projectLockModes are the values of the project_lock provider option.
+var projectLockModes = []string{"", "warn", "error"}
+
+// projectLockNodePattern extracts the node ID from URLs scoped to a node.
+var projectLockNodePattern = regexp.MustCompile(`^/v2/projects/[^/]+/nodes/([^/]+)`)
+
+// projectLockFields are the node fields another writer changes, e.g. by moving or
+// editing a node in the GUI. Runtime state such as status and ports is left out.
+var projectLockFields = []string{"name", "x", "y", "z", "symbol", "locked", "label", "properties"}
+
+// projectLockInfo is the content of the lock file. Host and PID identify the
+// provider process of the run, which exits when the run ends.
+type projectLockInfo struct {
+	Owner   string    `json:"owner"`
+	Host    string    `json:"host"`
+	PID     int       `json:"pid"`
+	Expires time.Time `json:"expires"`
+}
+
+// projectLocks marks the projects a run changes as managed by Terraform and
+// reports changes other writers make to their nodes meanwhile.
+type projectLocks struct {
+	// mode is warn, to log the changes of other writers, or error, to fail the
+	// requests that follow them.
+	mode  string
+	owner string
+	host  string
+	pid   int
+
+	mu       sync.Mutex
+	projects map[string]*projectLock
+}
+
+// projectLock follows the nodes of a locked project.
+type projectLock struct {
+	mu sync.Mutex
+	// nodes holds the name and fingerprint of each node as last seen.
+	nodes map[string][2]string
+	// claimed are the nodes changed by requests of this run.
+	claimed map[string]bool
+	// unclaimed holds when new nodes nobody claimed yet were first seen.
+	unclaimed map[string]time.Time
+	// foreign lists the changes made by other writers.
+	foreign []string
+}
+
+func newProjectLocks(mode string) *projectLocks {
+	host, _ := os.Hostname()
+	return &projectLocks{
+		mode:     mode,
+		owner:    fmt.Sprintf("terraform on %s (run %s)", host, newRequestID()),
+		host:     host,
+		pid:      os.Getpid(),
+		projects: map[string]*projectLock{},
+	}
+}
+
+// stale reports whether a lock was left by a run on this host that has ended,
+// such as the previous apply: the lock outlives its run until it expires.
+func (l *projectLocks) stale(holder projectLockInfo) bool {
+	if holder.Host != l.host || holder.PID <= 0 || holder.PID == l.pid {
+		return false
+	}
+	p, err := os.FindProcess(holder.PID)
+	if err != nil {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+}
+
+// lockedProject returns the project a request changes, or an empty string for
+// requests the lock does not apply to.
+func lockedProject(req *http.Request) string {
+	if req.Method == "GET" {
+		return ""
+	}
+	m := projectPathPattern.FindStringSubmatch(req.URL.Path)
+	if m == nil || m[2] == "open" || m[2] == "close" || m[2] == "files/"+projectLockFile {
+		return ""
+	}
+	return m[1]
+}
+
+// before locks the project a request changes on its first change of the run,
+// then checks that no other writer changed its nodes since the last request.
+func (l *projectLocks) before(c *Client, req *http.Request) error {
+	projectID := lockedProject(req)
+	if projectID == "" {
+		return nil
+	}
+	lock, err := l.acquire(c, req, projectID)
+	if err != nil {
+		return err
+	}
+	if err := l.check(c, req, projectID, lock); err != nil {
+		return err
+	}
+	if m := projectLockNodePattern.FindStringSubmatch(req.URL.Path); m != nil {
+		lock.claim(m[1])
+	}
+	return nil
+}
+
+// after claims the node a request created, e.g. by adding or duplicating a node.
+func (l *projectLocks) after(req *http.Request, resp *http.Response) {
+	projectID := lockedProject(req)
+	if projectID == "" || req.Method != "POST" || resp.StatusCode >= 300 {
+		return
+	}
+	l.mu.Lock()
+	lock := l.projects[projectID]
+	l.mu.Unlock()
+	if lock == nil {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var created map[string]interface{}
+	if json.Unmarshal(body, &created) == nil {
+		if nodeID, ok := created["node_id"].(string); ok {
+			lock.claim(nodeID)
+		}
+	}
+}
+
+func (lock *projectLock) claim(nodeID string) {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+	lock.claimed[nodeID] = true
+}
+
+// acquire returns the lock of a project, taking it on first use: an existing
+// lock of another run is reported, the nodes are recorded and the lock file is
+// written and kept fresh until the provider exits.
+func (l *projectLocks) acquire(c *Client, req *http.Request, projectID string) (*projectLock, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.projects[projectID]; ok {
+		return lock, nil
+	}
+
+	resp, err := l.request(c.send, req, "GET", fmt.Sprintf("/v2/projects/%s/files/%s", projectID, projectLockFile), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the lock of project %s: %s", projectID, err)
+	}
+	var holder projectLockInfo
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	held := resp.StatusCode == http.StatusOK && json.Unmarshal(body, &holder) == nil &&
+		holder.Owner != l.owner && time.Now().Before(holder.Expires)
+	switch {
+	case held && l.stale(holder):
+		log.Printf("[INFO] Taking over the lock of project %s left by %s, which has ended", projectID, holder.Owner)
+	case held:
+		msg := fmt.Sprintf("project %s is being changed by %s, whose lock holds until %s", projectID, holder.Owner, holder.Expires.Format(time.RFC3339))
+		if l.mode == "error" {
+			return nil, fmt.Errorf("%s; wait for that run to finish, or delete %s from the project directory if it was interrupted", msg, projectLockFile)
+		}
+		log.Printf("[WARN] %s", msg)
+	}
+
+	nodes, err := l.nodes(c, req, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.write(c.send, req, projectID); err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Locked project %s for %s", projectID, l.owner)
+
+	lock := &projectLock{nodes: nodes, claimed: map[string]bool{}, unclaimed: map[string]time.Time{}}
+	l.projects[projectID] = lock
+	go l.refresh(c, req, projectID)
+	return lock, nil
+}
+
+// refresh rewrites the lock file before it expires. It runs until the provider
+// exits; it does not reopen a project closed meanwhile.
+func (l *projectLocks) refresh(c *Client, req *http.Request, projectID string) {
+	for range time.Tick(projectLockTTL / 2) {
+		if err := l.write(c.attempt, req, projectID); err != nil {
+			log.Printf("[WARN] Cannot refresh the lock of project %s: %s", projectID, err)
+		}
+	}
+}
+
+// write writes the lock file of a project, held by this run for projectLockTTL.
+func (l *projectLocks) write(send func(*http.Request) (*http.Response, error), req *http.Request, projectID string) error {
+	data, err := json.Marshal(projectLockInfo{Owner: l.owner, Host: l.host, PID: l.pid, Expires: time.Now().Add(projectLockTTL).UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal project lock: %s", err)
+	}
+	resp, err := l.request(send, req, "POST", fmt.Sprintf("/v2/projects/%s/files/%s", projectID, projectLockFile), data)
+	if err != nil {
+		return fmt.Errorf("failed to lock project %s: %s", projectID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to lock project %s, status code: %d, response: %s", projectID, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// check compares the nodes of a project with the ones last seen and records
+// the changes of nodes this run did not claim. In error mode, any such change
+// fails every later request to the project.
+func (l *projectLocks) check(c *Client, req *http.Request, projectID string, lock *projectLock) error {
+	current, err := l.nodes(c, req, projectID)
+	if err != nil {
+		return err
+	}
+
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+	var changes []string
+	for id, node := range current {
+		last, known := lock.nodes[id]
+		switch {
+		case lock.claimed[id]:
+		case !known:
+			first, seen := lock.unclaimed[id]
+			if !seen {
+				lock.unclaimed[id] = time.Now()
+			}
+			if !seen || time.Since(first) < projectLockGrace {
+				delete(current, id)
+				continue
+			}
+			changes = append(changes, fmt.Sprintf("node %s was added", node[0]))
+		case last[1] != node[1]:
+			changes = append(changes, fmt.Sprintf("node %s was changed", node[0]))
+		}
+	}
+	for id, last := range lock.nodes {
+		if _, ok := current[id]; !ok && !lock.claimed[id] {
+			changes = append(changes, fmt.Sprintf("node %s was deleted", last[0]))
+		}
+	}
+	lock.nodes = current
+
+	sort.Strings(changes)
+	for _, change := range changes {
+		log.Printf("[WARN] Another writer changed project %s during this run: %s", projectID, change)
+	}
+	lock.foreign = append(lock.foreign, changes...)
+	if l.mode == "error" && len(lock.foreign) > 0 {
+		return fmt.Errorf("project %s was changed by another writer during this run (%s); re-run the plan once the other changes are done", projectID, strings.Join(lock.foreign, ", "))
+	}
+	return nil
+}
+
+// nodes returns the name and fingerprint of every node of a project.
+func (l *projectLocks) nodes(c *Client, req *http.Request, projectID string) (map[string][2]string, error) {
+	resp, err := l.request(c.send, req, "GET", fmt.Sprintf("/v2/projects/%s/nodes", projectID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes of locked project %s: %s", projectID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list nodes of locked project %s, status code: %d, response: %s", projectID, resp.StatusCode, string(body))
+	}
+	var list []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode nodes of locked project %s: %s", projectID, err)
+	}
+
+	nodes := map[string][2]string{}
+	for _, node := range list {
+		id, _ := node["node_id"].(string)
+		fields := map[string]interface{}{}
+		for _, key := range projectLockFields {
+			fields[key] = node[key]
+		}
+		fingerprint, _ := json.Marshal(fields)
+		nodes[id] = [2]string{fmt.Sprint(node["name"]), string(fingerprint)}
+	}
+	return nodes, nil
+}
+
+// request sends a request of the lock itself, to the controller req is sent to.
+func (l *projectLocks) request(send func(*http.Request) (*http.Response, error), req *http.Request, method, path string, body []byte) (*http.Response, error) {
+	u := *req.URL
+	u.Path = path
+	u.RawQuery = ""
+	r, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set(requestIDHeader, requestID(req))
+	return send(r)
+}
@@ -1,21 +1,74 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
-// ProviderConfig holds configuration for the provider.
+// ProviderConfig holds configuration for the provider and the state shared by
+// all resources during a single Terraform run.
 type ProviderConfig struct {
 	Host   string
 	APIURL string
+
+	// Client is the preconfigured (and, when a token is set, authenticated) HTTP client.
+	Client *Client
+	// APIVersion is the controller version reported by /v2/version, when reachable.
+	APIVersion string
+	// SkipStart suppresses the start flags of every resource (maintenance mode).
+	SkipStart bool
+	// AutoStartNodes is the start flag of resources that do not set one, or nil
+	// when auto_start_nodes is not set and resources keep their own default.
+	AutoStartNodes *bool
+	// StartStagger is the minimum delay between two node starts issued during this run.
+	StartStagger time.Duration
+	// StartCheck is how long a started node is watched for an early crash; zero disables the check.
+	StartCheck time.Duration
+	// StartRetries is how many times a node that crashed right after its start is started again.
+	StartRetries int
+	// DefaultLabels are written into the usage notes of every node created.
+	DefaultLabels map[string]string
+	// Cache stores lookups shared between resources for the duration of the run.
+	Cache *Cache
+
+	// placementMu guards placed, the canvas cells claimed by auto_offset during this run.
+	// The locks are shared with the per-operation copies made when tracing.
+	placementMu *sync.Mutex
+	placed      map[string]string
+	// portMu serializes links created with auto_port, so concurrent creates do not pick the same free port.
+	portMu *sync.Mutex
+	// startMu guards lastStart, the time of the last node start, to stagger starts.
+	startMu   *sync.Mutex
+	lastStart *time.Time
+}
+
+// newProviderConfig returns a configuration with the run-wide state initialized.
+func newProviderConfig(host string, client *Client) *ProviderConfig {
+	return &ProviderConfig{
+		Host:        host,
+		APIURL:      host,
+		Client:      client,
+		Cache:       newCache(),
+		placementMu: &sync.Mutex{},
+		placed:      map[string]string{},
+		portMu:      &sync.Mutex{},
+		startMu:     &sync.Mutex{},
+		lastStart:   &time.Time{},
+	}
 }
 
 // Provider returns the Terraform provider for GNS3.
 func Provider() *schema.Provider {
-	return &schema.Provider{
+	p := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"host": {
 				Type:        schema.TypeString,
@@ -23,35 +76,298 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("GNS3_HOST", "http://localhost:3080"),
 				Description: "The GNS3 server host URL. Default: http://localhost:3080",
 			},
+			"token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("GNS3_TOKEN", nil),
+				Description: "Bearer token sent to the controller, for servers behind an authenticating proxy.",
+			},
+			"extra_headers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Additional HTTP headers sent with every request, e.g. for SSO proxies in front of the controller (CF-Access-Client-Id, X-Auth-Token).",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"dry_run": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("GNS3_DRY_RUN", false),
+				Description: "If true, no changes are sent to the controller. Mutating calls are logged and resources receive synthetic IDs prefixed with \"dryrun-\".",
+			},
+			"default_labels": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Labels (e.g. owner, cost-center) written into the usage notes of every Docker, QEMU and template node created, so GUI users can tell which nodes Terraform manages and for whom.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"skip_start": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("GNS3_SKIP_START", false),
+				Description: "If true, nodes are never started by their start or start_vm flags, e.g. to apply a large lab on a capacity-constrained controller. Start them later with gns3_start_all.",
+			},
+			"auto_start_nodes": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("GNS3_AUTO_START_NODES", nil),
+				Description: "Start flag of the nodes whose resource does not set its start or start_vm flag. When unset, each resource keeps its own default: gns3_docker starts its container, other nodes are not started. skip_start still takes precedence.",
+			},
+			"start_stagger_seconds": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Minimum delay in seconds between two node starts issued by the provider, so a large lab does not boot every VM at once. gns3_start_all then starts the nodes one by one.",
+			},
+			"start_check_seconds": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Watch every node the provider starts for this many seconds and fail, with the last lines of its log, when it stops on its own in the meantime. GNS3 accepts a start before a crashing image exits, so without the check such a node is reported as started.",
+			},
+			"start_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How many times a node that stopped within start_check_seconds of its start is started again before failing.",
+			},
+			"project_lock": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				ValidateFunc: validation.StringInSlice(projectLockModes, false),
+				Description:  "Mark the projects a run changes as managed by Terraform with a terraform-apply.lock file, and watch their nodes for changes made meanwhile by another writer such as the GUI. With warn, such changes and projects locked by another run are logged as warnings; with error, they fail the provider's next change to the project. Unset by default.",
+			},
+			"fallback_hosts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Standby controller URLs for active/standby deployments. When the controller in use cannot be reached, requests are sent to the next one in order, starting from host.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+				},
+			},
+			"ip_protocol": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("GNS3_IP_PROTOCOL", "auto"),
+				ValidateFunc: validation.StringInSlice(sortedKeys(ipProtocolNetworks), false),
+				Description:  "IP version used to connect to the controller: auto, ipv4 or ipv6. Force ipv4 when a dual-stack host name resolves to an unreachable IPv6 address.",
+			},
+			"max_reads_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("GNS3_MAX_READS_PER_SECOND", 0),
+				ValidateFunc: validation.FloatAtLeast(0),
+				Description:  "Maximum GET requests per second sent to the controller, shared by every resource, e.g. to keep the refresh of a large workspace from overwhelming it. 0 means unlimited.",
+			},
+			"max_mutations_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("GNS3_MAX_MUTATIONS_PER_SECOND", 0),
+				ValidateFunc: validation.FloatAtLeast(0),
+				Description:  "Maximum requests per second that change something (creates, updates, deletes, node starts) sent to the controller. Budgeted separately from reads. 0 means unlimited.",
+			},
+			"restart_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("GNS3_RESTART_TIMEOUT", 300),
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Seconds to wait for an unreachable controller to come back (e.g. a restart during apply) before failing requests. Projects are re-opened once it answers. Set to 0 to fail immediately.",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"gns3_project":   resourceGns3Project(),
-			"gns3_cloud":     resourceGns3Cloud(),
-			"gns3_switch":    resourceGns3Switch(),
-			"gns3_template":  resourceGns3Template(),
-			"gns3_link":      resourceGns3Link(),
-			"gns3_start_all": resourceGns3StartAll(),
-			"gns3_docker":    resourceGns3Docker(),
-			"gns3_qemu_node": resourceGns3Qemu(),
+			"gns3_project":         resourceGns3Project(),
+			"gns3_cloud":           resourceGns3Cloud(),
+			"gns3_switch":          resourceGns3Switch(),
+			"gns3_template":        resourceGns3Template(),
+			"gns3_link":            resourceGns3Link(),
+			"gns3_start_all":       resourceGns3StartAll(),
+			"gns3_docker":          resourceGns3Docker(),
+			"gns3_qemu_node":       resourceGns3Qemu(),
+			"gns3_text_annotation": resourceGns3TextAnnotation(),
+			"gns3_snapshot":        resourceGns3Snapshot(),
+			"gns3_node_duplicate":  resourceGns3NodeDuplicate(),
+			"gns3_image":           resourceGns3Image(),
+			"gns3_udp_tunnel":      resourceGns3UDPTunnel(),
+			"gns3_link_set":        resourceGns3LinkSet(),
+			"gns3_project_prune":   resourceGns3ProjectPrune(),
+			"gns3_console_script":  resourceGns3ConsoleScript(),
+			"gns3_appliance_node":  resourceGns3ApplianceNode(),
+			"gns3_node_group":      resourceGns3NodeGroup(),
+			"gns3_dynamips":        resourceGns3Dynamips(),
+			"gns3_iou":             resourceGns3IOU(),
+			"gns3_iou_license":     resourceGns3IOULicense(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"gns3_template_id": dataSourceGns3TemplateID(),
-			"gns3_node_id":     dataSourceGns3NodeID(),
-			"gns3_link_id":     dataSourceGns3LinkID(),
+			"gns3_template_id":        dataSourceGns3TemplateID(),
+			"gns3_node_id":            dataSourceGns3NodeID(),
+			"gns3_node":               dataSourceGns3Node(),
+			"gns3_link_id":            dataSourceGns3LinkID(),
+			"gns3_console_inventory":  dataSourceGns3ConsoleInventory(),
+			"gns3_ansible_inventory":  dataSourceGns3AnsibleInventory(),
+			"gns3_templates":          dataSourceGns3Templates(),
+			"gns3_statistics":         dataSourceGns3Statistics(),
+			"gns3_topology_export":    dataSourceGns3TopologyExport(),
+			"gns3_compute_interfaces": dataSourceGns3ComputeInterfaces(),
+			"gns3_compute":            dataSourceGns3Compute(),
+			"gns3_projects":           dataSourceGns3Projects(),
+			"gns3_node_event":         dataSourceGns3NodeEvent(),
+			"gns3_expired_snapshots":  dataSourceGns3ExpiredSnapshots(),
+			"gns3_project_file":       dataSourceGns3ProjectFile(),
+			"gns3_project_hcl":        dataSourceGns3ProjectHCL(),
+			"gns3_node_log":           dataSourceGns3NodeLog(),
+			"gns3_ports_usage":        dataSourceGns3PortsUsage(),
 		},
 		ConfigureFunc: providerConfigure,
 	}
+	traceResources(p)
+	return p
 }
 
 // providerConfigure initializes the provider with the GNS3 host configuration.
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
-	config := &ProviderConfig{
-		Host:   d.Get("host").(string),
-		APIURL: d.Get("host").(string),
+	host := trimHost(d.Get("host").(string))
+	token := d.Get("token").(string)
+
+	headers := map[string]string{}
+	for k, v := range d.Get("extra_headers").(map[string]interface{}) {
+		headers[k] = v.(string)
+	}
+
+	labels := map[string]string{}
+	for k, v := range d.Get("default_labels").(map[string]interface{}) {
+		labels[k] = v.(string)
+	}
+
+	config := newProviderConfig(host, newClient(token, headers))
+	config.Client.setIPProtocol(d.Get("ip_protocol").(string))
+	config.Client.limiter = newRateLimiter(d.Get("max_reads_per_second").(float64), d.Get("max_mutations_per_second").(float64))
+	if fallbacks := d.Get("fallback_hosts").([]interface{}); len(fallbacks) > 0 {
+		hosts := []string{host}
+		for _, h := range fallbacks {
+			hosts = append(hosts, h.(string))
+		}
+		config.Client.failover = newHostFailover(hosts)
+	}
+	config.SkipStart = d.Get("skip_start").(bool)
+	if v, ok := d.GetOkExists("auto_start_nodes"); ok {
+		autoStart := v.(bool)
+		config.AutoStartNodes = &autoStart
+	}
+	config.StartStagger = time.Duration(d.Get("start_stagger_seconds").(int)) * time.Second
+	config.StartCheck = time.Duration(d.Get("start_check_seconds").(int)) * time.Second
+	config.StartRetries = d.Get("start_retries").(int)
+	config.DefaultLabels = labels
+
+	if d.Get("dry_run").(bool) {
+		log.Printf("[WARN] GNS3 provider running in dry_run mode: no changes will be made on %s", host)
+		config.Client.dryRun = newDryRunStore()
+	} else if mode := d.Get("project_lock").(string); mode != "" {
+		config.Client.locks = newProjectLocks(mode)
 	}
 
-	log.Printf("[INFO] Terraform GNS3 Provider configured with host: %s", config.Host)
+	version, err := getServerVersion(config)
+	if err != nil {
+		log.Printf("[WARN] Could not determine GNS3 server version: %s", err)
+	} else {
+		if err := checkServerVersion(config.Host, version); err != nil {
+			return nil, err
+		}
+		config.APIVersion = version
+	}
+	// Enabled after the version probe so an unreachable host does not stall configuration
+	config.Client.restartTimeout = time.Duration(d.Get("restart_timeout").(int)) * time.Second
+	config.Client.tracer = newTracerFromEnv()
+
+	log.Printf("[INFO] Terraform GNS3 Provider configured with host: %s (version %q)", config.Host, config.APIVersion)
 	fmt.Println("[INFO] Terraform GNS3 Provider successfully initialized!")
 
 	return config, nil
 }
+
+// minServerVersion is the oldest controller version the provider supports. The
+// provider speaks the v2 API only, so the supported range ends before 3.0.
+const minServerVersion = "2.2.0"
+
+// serverVersionPattern matches the numeric part of a controller version such as
+// 2.2.44 or 3.0.0rc1.
+var serverVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseServerVersion returns the major, minor and patch numbers of a controller version.
+func parseServerVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	match := serverVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return parts, false
+	}
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(match[i+1])
+	}
+	return parts, true
+}
+
+// checkServerVersion fails with the detected and supported versions when the
+// controller is outside the range the provider supports. Versions that cannot
+// be parsed, e.g. development builds, are let through.
+func checkServerVersion(host, version string) error {
+	detected, ok := parseServerVersion(version)
+	if !ok {
+		log.Printf("[WARN] Could not parse GNS3 server version %q, assuming it is supported", version)
+		return nil
+	}
+	min, _ := parseServerVersion(minServerVersion)
+	if detected[0] > min[0] {
+		return fmt.Errorf("the GNS3 controller at %s runs version %s, which serves the v%d API; this provider only supports the v2 API of GNS3 %s up to 2.x", host, version, detected[0], minServerVersion)
+	}
+	for i := range detected {
+		if detected[i] != min[i] {
+			if detected[i] < min[i] {
+				return fmt.Errorf("the GNS3 controller at %s runs version %s; this provider requires at least %s", host, version, minServerVersion)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// getServerVersion returns the version reported by the controller. Controllers
+// without the v2 API are asked for their version through the v3 API.
+func getServerVersion(config *ProviderConfig) (string, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/version", config.Host))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		v3, err := config.Client.Get(fmt.Sprintf("%s/v3/version", config.Host))
+		if err != nil {
+			return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		defer v3.Body.Close()
+		if v3.StatusCode == http.StatusOK {
+			resp = v3
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", fmt.Errorf("failed to decode version response: %s", err)
+	}
+	return v.Version, nil
+}
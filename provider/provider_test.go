@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestProvider(t *testing.T) {
+	if err := Provider().InternalValidate(); err != nil {
+		t.Fatalf("provider failed validation: %s", err)
+	}
+}
+
+func TestServerVersionCheck(t *testing.T) {
+	cases := []struct {
+		version string
+		err     string
+	}{
+		{"2.2.44", ""},
+		{"2.2.0dev1", ""},
+		{"2.3.1", ""},
+		{"2.1.21", "requires at least 2.2.0"},
+		{"3.0.0rc1", "serves the v3 API"},
+		{"nightly", ""},
+	}
+	for _, tc := range cases {
+		err := checkServerVersion("http://gns3", tc.version)
+		if tc.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.version, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.version, tc.err, err)
+		}
+	}
+
+	// A v3 controller answers 404 on every /v2 path
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version": "3.0.2"}`))
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{"host": server.URL})
+	_, err := providerConfigure(d)
+	if err == nil || !strings.Contains(err.Error(), "3.0.2") || !strings.Contains(err.Error(), "2.2.0") {
+		t.Errorf("expected configuration to fail with the detected and supported versions, got %v", err)
+	}
+}
+
+func TestAutoStartNodesOption(t *testing.T) {
+	m := newMockController(t)
+	for _, tc := range []struct {
+		raw  map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"host": m.server.URL}, "unset"},
+		{map[string]interface{}{"host": m.server.URL, "auto_start_nodes": false}, "false"},
+		{map[string]interface{}{"host": m.server.URL, "auto_start_nodes": true}, "true"},
+	} {
+		meta, err := providerConfigure(schema.TestResourceDataRaw(t, Provider().Schema, tc.raw))
+		if err != nil {
+			t.Fatalf("configure failed: %s", err)
+		}
+		got := "unset"
+		if v := meta.(*ProviderConfig).AutoStartNodes; v != nil {
+			got = fmt.Sprint(*v)
+		}
+		if got != tc.want {
+			t.Errorf("expected auto_start_nodes %v to configure %s, got %s", tc.raw["auto_start_nodes"], tc.want, got)
+		}
+	}
+}
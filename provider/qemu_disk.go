@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// qemuDiskIncrease returns how many MB the HDA disk must grow by during this
+// apply: the whole increase for a new node or disk image, else what was added.
+func qemuDiskIncrease(d *schema.ResourceData) int {
+	if d.IsNewResource() || d.HasChange("hda_disk_image") {
+		return d.Get("hda_disk_size_increase_mb").(int)
+	}
+	old, new := d.GetChange("hda_disk_size_increase_mb")
+	if grow := new.(int) - old.(int); grow > 0 {
+		return grow
+	}
+	return 0
+}
+
+// qemuDiskCustomizeDiff rejects a disk increase without a disk, and one that
+// shrinks, which qemu-img cannot do safely.
+func qemuDiskCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	increase := d.Get("hda_disk_size_increase_mb").(int)
+	if increase > 0 && d.NewValueKnown("hda_disk_image") && d.Get("hda_disk_image").(string) == "" {
+		return fmt.Errorf("hda_disk_size_increase_mb needs an hda_disk_image to grow")
+	}
+	if d.Id() == "" || d.HasChange("hda_disk_image") {
+		return nil
+	}
+	if old, _ := d.GetChange("hda_disk_size_increase_mb"); old.(int) > increase {
+		return fmt.Errorf("hda_disk_size_increase_mb cannot go down from %d to %d: disks can only grow, replace the node to start from a smaller disk", old.(int), increase)
+	}
+	return nil
+}
+
+// resizeQemuDisk grows a disk of a stopped QEMU node by extendMB. The
+// controller has no route for it, so it goes to the compute API of the local
+// compute, where the provider creates QEMU nodes.
+func resizeQemuDisk(config *ProviderConfig, projectID, nodeID, drive string, extendMB int) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"drive_name": drive,
+		"extend":     extendMB,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal disk resize payload: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/compute/projects/%s/qemu/nodes/%s/resize_disk", config.Host, projectID, nodeID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to resize %s disk: %s", drive, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to resize %s disk, status code: %d, response: %s", drive, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// growQemuDisk applies hda_disk_size_increase_mb to a node, which must be stopped.
+func growQemuDisk(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string, running bool) error {
+	extend := qemuDiskIncrease(d)
+	if extend == 0 {
+		return nil
+	}
+	if running {
+		return fmt.Errorf("cannot grow the disk of QEMU node %s while it runs: enable stop_before_update or stop it first", d.Get("name").(string))
+	}
+	return resizeQemuDisk(config, projectID, nodeID, "hda", extend)
+}
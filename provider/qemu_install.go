@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// qemuInstallSchema returns the schema of the install_mode block, which installs
+// the OS from an ISO the first time the VM boots.
+func qemuInstallSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: []string{"cdrom_image"},
+		Description:   "Install the OS when the node is created: boot from cdrom_image, wait until the installation is done, detach the ISO and reboot from disk. Ignored after creation.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"cdrom_image": {
+					Type:         schema.TypeString,
+					Required:     true,
+					StateFunc:    normalizeImagePath,
+					ValidateFunc: validateImagePath,
+					Description:  "Installation ISO to boot from. Windows paths may use backslashes.",
+				},
+				"console_regex": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsValidRegExp,
+					Description:  "Regular expression the telnet console prints when the installation is done, e.g. a reboot prompt. Without it the installation is considered done once timeout elapses.",
+				},
+				"timeout": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      1800,
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "Seconds the installation may take. With console_regex, not seeing it in time fails the apply.",
+				},
+			},
+		},
+	}
+}
+
+// qemuInstall returns the install_mode block, or nil when it is not set.
+func qemuInstall(d *schema.ResourceData) map[string]interface{} {
+	blocks := d.Get("install_mode").([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+	return blocks[0].(map[string]interface{})
+}
+
+// runQemuInstall boots a freshly created VM from its installation ISO and, once
+// the installation is done, detaches the ISO and boots from disk. The VM is left
+// running only if start_vm asks for it.
+func runQemuInstall(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string, install map[string]interface{}) error {
+	if config.SkipStart {
+		return fmt.Errorf("install_mode needs to start the VM, which skip_start prevents; unset skip_start to install %q", d.Get("name"))
+	}
+	pattern := install["console_regex"].(string)
+	if pattern != "" && d.Get("console_type").(string) != "telnet" {
+		return fmt.Errorf("install_mode.console_regex needs console_type = \"telnet\", got %q", d.Get("console_type"))
+	}
+	timeout := time.Duration(install["timeout"].(int)) * time.Second
+
+	log.Printf("[INFO] Installing GNS3 node %s from %s", nodeID, install["cdrom_image"])
+	if err := nodeAction(config, projectID, nodeID, "start"); err != nil {
+		return err
+	}
+
+	if pattern != "" {
+		node, err := getNode(config, projectID, nodeID)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return fmt.Errorf("node %s disappeared during installation", nodeID)
+		}
+		addr := net.JoinHostPort(consoleHost(config, node), strconv.Itoa(jsonInt(node["console"])))
+		if err := waitForConsole(addr, regexp.MustCompile(pattern), timeout); err != nil {
+			return fmt.Errorf("installation of %q did not finish: %s", d.Get("name"), err)
+		}
+	} else {
+		time.Sleep(timeout)
+	}
+
+	log.Printf("[INFO] Installation of GNS3 node %s done, booting from disk", nodeID)
+	if err := updateNode(config, projectID, nodeID, map[string]interface{}{
+		"properties": map[string]interface{}{"cdrom_image": "", "boot_priority": "c"},
+	}); err != nil {
+		return err
+	}
+	if startRequested(d, config, "start_vm") {
+		return nodeAction(config, projectID, nodeID, "reload")
+	}
+	return nodeAction(config, projectID, nodeID, "stop")
+}
+
+// waitForConsole reads a telnet console until its output matches re. The
+// console is re-dialed while the VM brings it up.
+func waitForConsole(addr string, re *regexp.Regexp, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var output []byte
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("console %s unreachable: %s", addr, err)
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+
+		buf := make([]byte, 4096)
+		for {
+			conn.SetReadDeadline(deadline)
+			n, err := conn.Read(buf)
+			output = append(output, stripTelnetCommands(buf[:n])...)
+			// Only the tail can complete a match that was not found before
+			if len(output) > 64*1024 {
+				output = output[len(output)-64*1024:]
+			}
+			if re.Match(output) {
+				conn.Close()
+				return nil
+			}
+			if err != nil {
+				conn.Close()
+				if time.Now().After(deadline) {
+					return fmt.Errorf("console output did not match %q within %s", re, timeout)
+				}
+				break
+			}
+		}
+	}
+}
+
+// stripTelnetCommands removes telnet negotiation (IAC sequences) from console output.
+func stripTelnetCommands(b []byte) []byte {
+	const iac = 0xff
+	if bytes.IndexByte(b, iac) < 0 {
+		return b
+	}
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != iac || i+1 >= len(b) {
+			out = append(out, b[i])
+			continue
+		}
+		switch cmd := b[i+1]; {
+		case cmd == iac:
+			out = append(out, iac)
+			i++
+		case cmd >= 0xfb && cmd <= 0xfe:
+			// WILL, WONT, DO, DONT carry an option byte
+			i += 2
+		default:
+			i++
+		}
+	}
+	return out
+}
@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// qemuOption is a single flag from a QEMU options string with its optional value.
+type qemuOption struct {
+	Flag  string
+	Value string
+}
+
+// qemuManagedFlags maps QEMU flags to the schema attributes that already control them.
+var qemuManagedFlags = map[string]string{
+	"-m":     "ram",
+	"-smp":   "cpus",
+	"-cdrom": "cdrom_image",
+	"-hda":   "hda_disk_image",
+	"-bios":  "bios_image",
+	"-name":  "name",
+}
+
+// qemuDiskInterfaces are the disk buses GNS3 accepts for QEMU disk images.
+var qemuDiskInterfaces = []string{"ide", "sata", "nvme", "scsi", "sd", "mtd", "floppy", "pflash", "virtio", "none"}
+
+// qemuSingletonFlags may only be given once; the last occurrence wins, like on the QEMU command line.
+var qemuSingletonFlags = map[string]bool{
+	"-m":          true,
+	"-smp":        true,
+	"-cpu":        true,
+	"-machine":    true,
+	"-name":       true,
+	"-uuid":       true,
+	"-nographic":  true,
+	"-enable-kvm": true,
+	"-no-kvm":     true,
+	"-vga":        true,
+	"-boot":       true,
+}
+
+// splitQemuArgs splits an options string into words, honouring single and double quotes.
+func splitQemuArgs(raw string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inWord := false
+
+	for _, r := range raw {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// parseQemuOptions groups an options string into flag/value pairs.
+func parseQemuOptions(raw string) []qemuOption {
+	args := splitQemuArgs(raw)
+	var opts []qemuOption
+	for i := 0; i < len(args); i++ {
+		opt := qemuOption{Flag: args[i]}
+		if strings.HasPrefix(args[i], "-") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			opt.Value = args[i+1]
+			i++
+		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+// mergeQemuOptions drops exact duplicates and keeps only the last value of singleton flags,
+// preserving the order in which flags were first given.
+func mergeQemuOptions(opts []qemuOption) []qemuOption {
+	var merged []qemuOption
+	singletonIdx := map[string]int{}
+	seen := map[qemuOption]bool{}
+
+	for _, opt := range opts {
+		if qemuSingletonFlags[opt.Flag] {
+			if idx, ok := singletonIdx[opt.Flag]; ok {
+				merged[idx] = opt
+				continue
+			}
+			singletonIdx[opt.Flag] = len(merged)
+			merged = append(merged, opt)
+			continue
+		}
+		if seen[opt] {
+			continue
+		}
+		seen[opt] = true
+		merged = append(merged, opt)
+	}
+	return merged
+}
+
+// formatQemuOptions renders flag/value pairs back into a single options string.
+func formatQemuOptions(opts []qemuOption) string {
+	parts := make([]string, 0, len(opts)*2)
+	for _, opt := range opts {
+		parts = append(parts, quoteQemuArg(opt.Flag))
+		if opt.Value != "" {
+			parts = append(parts, quoteQemuArg(opt.Value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteQemuArg quotes an argument so splitQemuArgs reads it back unchanged. An
+// argument with both kinds of quotes is single quoted, with each embedded single
+// quote closing the quotes, added in double quotes and reopening them.
+func quoteQemuArg(arg string) string {
+	switch {
+	case !strings.ContainsAny(arg, " \t\n\r\"'"):
+		return arg
+	case !strings.Contains(arg, "'"):
+		return "'" + arg + "'"
+	case !strings.Contains(arg, "\""):
+		return "\"" + arg + "\""
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+// normalizeQemuOptions returns the canonical form of an options string so repeated
+// applies always send (and compare) the same flags.
+func normalizeQemuOptions(raw string) string {
+	return formatQemuOptions(mergeQemuOptions(parseQemuOptions(raw)))
+}
+
+// qemuRawOptions joins options, the entries of options_list in order and
+// sensitive_options into one options string. get is the Get method of a
+// ResourceData or ResourceDiff.
+func qemuRawOptions(get func(string) interface{}) string {
+	parts := []string{get("options").(string)}
+	for _, opt := range get("options_list").([]interface{}) {
+		if opt != nil {
+			parts = append(parts, opt.(string))
+		}
+	}
+	parts = append(parts, get("sensitive_options").(string))
+	return strings.Join(parts, " ")
+}
+
+// qemuOptionsValue returns the options sent to GNS3: options, options_list,
+// sensitive_options and the SMBIOS attributes, normalized.
+func qemuOptionsValue(d *schema.ResourceData) string {
+	return normalizeQemuOptions(qemuRawOptions(d.Get) + " " + formatQemuOptions(qemuSMBIOSOptions(d)))
+}
+
+// qemuSMBIOSOptions translates the serial_number, asset_tag and uuid attributes into QEMU flags.
+func qemuSMBIOSOptions(d *schema.ResourceData) []qemuOption {
+	var opts []qemuOption
+	if v := d.Get("serial_number").(string); v != "" {
+		opts = append(opts, qemuOption{Flag: "-smbios", Value: "type=1,serial=" + qemuEscapeComma(v)})
+	}
+	if v := d.Get("asset_tag").(string); v != "" {
+		opts = append(opts, qemuOption{Flag: "-smbios", Value: "type=3,asset=" + qemuEscapeComma(v)})
+	}
+	if v := d.Get("uuid").(string); v != "" {
+		opts = append(opts, qemuOption{Flag: "-uuid", Value: v})
+	}
+	return opts
+}
+
+// qemuEscapeComma doubles commas, which QEMU otherwise reads as option separators.
+func qemuEscapeComma(v string) string {
+	return strings.ReplaceAll(v, ",", ",,")
+}
+
+// qemuSMBIOSConflict returns the attribute a raw option also sets, if any.
+func qemuSMBIOSConflict(opt qemuOption) string {
+	switch {
+	case opt.Flag == "-uuid":
+		return "uuid"
+	case opt.Flag == "-smbios" && strings.Contains(opt.Value, "type=1") && strings.Contains(opt.Value, "uuid="):
+		return "uuid"
+	case opt.Flag == "-smbios" && strings.Contains(opt.Value, "type=1") && strings.Contains(opt.Value, "serial="):
+		return "serial_number"
+	case opt.Flag == "-smbios" && strings.Contains(opt.Value, "type=3") && strings.Contains(opt.Value, "asset="):
+		return "asset_tag"
+	}
+	return ""
+}
+
+// qemuSMBIOSCustomizeDiff rejects plans where options set the same SMBIOS field
+// as serial_number, asset_tag or uuid.
+func qemuSMBIOSCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	for _, opt := range parseQemuOptions(qemuRawOptions(d.Get)) {
+		attr := qemuSMBIOSConflict(opt)
+		if attr != "" && d.Get(attr).(string) != "" {
+			return fmt.Errorf("options set %s %s, which conflicts with the %q attribute; remove one of them", opt.Flag, opt.Value, attr)
+		}
+	}
+	return nil
+}
+
+// qemuOptionsDiffSuppress ignores whitespace, quoting and duplicate-flag differences.
+func qemuOptionsDiffSuppress(k, old, new string, d *schema.ResourceData) bool {
+	return normalizeQemuOptions(old) == normalizeQemuOptions(new)
+}
+
+// validateQemuOptions warns at plan time about duplicated flags and flags that
+// conflict with attributes managed by the resource schema.
+func validateQemuOptions(i interface{}, path cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	raw, ok := i.(string)
+	if !ok {
+		return diag.Errorf("expected options to be a string")
+	}
+
+	counts := map[string]int{}
+	var order []string
+	for _, opt := range parseQemuOptions(raw) {
+		if counts[opt.Flag] == 0 {
+			order = append(order, opt.Flag)
+		}
+		counts[opt.Flag]++
+		if attr, ok := qemuManagedFlags[opt.Flag]; ok && counts[opt.Flag] == 1 {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Warning,
+				Summary:       fmt.Sprintf("QEMU option %s conflicts with the %q attribute", opt.Flag, attr),
+				Detail:        fmt.Sprintf("The %s flag in options overrides or duplicates the value the provider sends for %q. Set %q instead.", opt.Flag, attr, attr),
+				AttributePath: path,
+			})
+		}
+	}
+	for _, flag := range order {
+		if n := counts[flag]; n > 1 && qemuSingletonFlags[flag] {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Warning,
+				Summary:       fmt.Sprintf("QEMU option %s is given %d times", flag, n),
+				Detail:        "Only the last occurrence is kept when the options are normalized.",
+				AttributePath: path,
+			})
+		}
+	}
+	return diags
+}
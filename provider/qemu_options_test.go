@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestNormalizeQemuOptions(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"-nographic", "-nographic"},
+		{"  -m 512   -m 1024 ", "-m 1024"},
+		{"-smbios type=1,serial=A -smbios type=1,serial=A", "-smbios type=1,serial=A"},
+		{`-append "console=ttyS0 quiet"`, "-append 'console=ttyS0 quiet'"},
+		{`-name "it's a lab"`, `-name "it's a lab"`},
+		{`-name 'say "hi"'"'"'s lab'`, `-name 'say "hi"'"'"'s lab'`},
+	}
+	for _, tc := range cases {
+		if got := normalizeQemuOptions(tc.in); got != tc.want {
+			t.Errorf("normalizeQemuOptions(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestQuoteQemuArgRoundTrip(t *testing.T) {
+	for _, arg := range []string{"plain", "two words", `say "hi"`, "it's", "it's a lab", `say "hi" it's`, "tab\there"} {
+		if got := splitQemuArgs(quoteQemuArg(arg)); len(got) != 1 || got[0] != arg {
+			t.Errorf("splitQemuArgs(quoteQemuArg(%q)) = %q", arg, got)
+		}
+	}
+}
+
+func TestQemuOptionsValueSMBIOS(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceGns3Qemu().Schema, map[string]interface{}{
+		"project_id":    "p",
+		"name":          "vm",
+		"options":       "-nographic",
+		"serial_number": "SN,1",
+		"asset_tag":     "LAB-42",
+		"uuid":          "5e4f0f7e-5f3c-4d6b-9a0e-0c8f6a2b1c3d",
+	})
+	want := "-nographic -smbios type=1,serial=SN,,1 -smbios type=3,asset=LAB-42 -uuid 5e4f0f7e-5f3c-4d6b-9a0e-0c8f6a2b1c3d"
+	if got := qemuOptionsValue(d); got != want {
+		t.Errorf("qemuOptionsValue = %q, want %q", got, want)
+	}
+}
+
+func TestQemuSMBIOSConflict(t *testing.T) {
+	cases := map[string]string{
+		"-uuid 5e4f0f7e-5f3c-4d6b-9a0e-0c8f6a2b1c3d": "uuid",
+		"-smbios type=1,serial=ABC":                  "serial_number",
+		"-smbios type=3,asset=TAG":                   "asset_tag",
+		"-smbios type=0,vendor=X":                    "",
+	}
+	for raw, want := range cases {
+		opts := parseQemuOptions(raw)
+		if got := qemuSMBIOSConflict(opts[0]); got != want {
+			t.Errorf("qemuSMBIOSConflict(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestQemuOptionsList(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceGns3Qemu().Schema, map[string]interface{}{
+		"project_id":   "p",
+		"name":         "vm",
+		"options":      "-nographic",
+		"options_list": []interface{}{"-machine  q35", "-device virtio-rng-pci", "-nographic", "-machine pc"},
+	})
+	want := "-nographic -machine pc -device virtio-rng-pci"
+	if got := qemuOptionsValue(d); got != want {
+		t.Errorf("qemuOptionsValue = %q, want %q", got, want)
+	}
+}
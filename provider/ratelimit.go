@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenBucket lets requests through at rate per second on average, with bursts
+// of up to burst requests after a quiet period.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket refilling at rate tokens per second, or
+// nil when rate is not positive, which never limits.
+func newTokenBucket(rate float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller must wait before using it.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter spreads requests to the controller over time, with separate
+// budgets for reads and for requests that change something, so a large refresh
+// cannot starve applies and vice versa.
+type rateLimiter struct {
+	reads     *tokenBucket
+	mutations *tokenBucket
+}
+
+// newRateLimiter returns a limiter for the given requests per second, or nil
+// when neither budget is limited. Zero leaves a budget unlimited.
+func newRateLimiter(readsPerSecond, mutationsPerSecond float64) *rateLimiter {
+	if readsPerSecond <= 0 && mutationsPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		reads:     newTokenBucket(readsPerSecond),
+		mutations: newTokenBucket(mutationsPerSecond),
+	}
+}
+
+// wait blocks until the budget of req allows it to be sent.
+func (l *rateLimiter) wait(req *http.Request) {
+	bucket := l.mutations
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		bucket = l.reads
+	}
+	if bucket == nil {
+		return
+	}
+	if delay := bucket.reserve(); delay > 0 {
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,419 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Appliance is the part of a GNS3 appliance definition (.gns3a) needed to
+// create a QEMU node from it.
+type Appliance struct {
+	Name            string                 `json:"name"`
+	PortNameFormat  string                 `json:"port_name_format"`
+	PortSegmentSize int                    `json:"port_segment_size"`
+	FirstPortName   string                 `json:"first_port_name"`
+	LinkedClone     *bool                  `json:"linked_clone"`
+	Qemu            map[string]interface{} `json:"qemu"`
+	Images          []ApplianceImage       `json:"images"`
+	Versions        []ApplianceVersion     `json:"versions"`
+}
+
+// ApplianceImage is a file an appliance version can use.
+type ApplianceImage struct {
+	Filename    string `json:"filename"`
+	Version     string `json:"version"`
+	MD5Sum      string `json:"md5sum"`
+	DownloadURL string `json:"download_url"`
+}
+
+// ApplianceVersion maps the disk slots of a node to image filenames.
+type ApplianceVersion struct {
+	Name   string            `json:"name"`
+	Images map[string]string `json:"images"`
+}
+
+// applianceQemuProperties are the settings of an appliance's qemu section that
+// are node properties under the same name.
+var applianceQemuProperties = []string{
+	"adapter_type", "adapters", "ram", "cpus", "console_type", "boot_priority",
+	"kernel_command_line", "options", "cpu_throttling", "process_priority", "on_close",
+	"hda_disk_interface", "hdb_disk_interface", "hdc_disk_interface", "hdd_disk_interface",
+}
+
+// resourceGns3ApplianceNode creates a QEMU node configured from an appliance
+// definition and version, using images already uploaded to the compute.
+func resourceGns3ApplianceNode() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withCanvasCheck(resourceGns3ApplianceNodeCreate),
+		Read:          resourceGns3ApplianceNodeRead,
+		Update:        resourceGns3ApplianceNodeUpdate,
+		Delete:        resourceGns3ApplianceNodeDelete,
+		CustomizeDiff: computeCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project the node is created in.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the node.",
+			},
+			"appliance_file": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"appliance_file", "appliance_name"},
+				Description:  "Path to a .gns3a appliance file.",
+			},
+			"appliance_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Name of an appliance known to the controller, as listed in the GNS3 GUI.",
+			},
+			"version": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The appliance version to deploy, e.g. 1.4.2.",
+			},
+			"compute_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "local",
+				Description: "The compute running the node. The images of the version must be uploaded to it.",
+			},
+			"ram": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "RAM in MB. Defaults to the appliance's.",
+			},
+			"cpus": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "Number of vCPUs. Defaults to the appliance's.",
+			},
+			"adapters": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "Number of network adapters. Defaults to the appliance's.",
+			},
+			"x": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "X coordinate of the node on the GNS3 canvas.",
+			},
+			"y": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Y coordinate of the node on the GNS3 canvas.",
+			},
+			"start": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether to start the node after creation.",
+			},
+			"images": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The image used for each disk slot of the node, e.g. hda_disk_image.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"ports":    nodePortsSchema(),
+			"gns3_url": webURLSchema("Web UI link to the node's console."),
+			"status":   nodeStatusSchema(),
+			"protect":  protectSchema(),
+
+			"console_auto_start": consoleAutoStartSchema(),
+		},
+	}
+}
+
+// loadAppliance reads an appliance definition from a file, or from the
+// controller's appliance list by name.
+func loadAppliance(config *ProviderConfig, file, name string) (*Appliance, error) {
+	var raw []byte
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read appliance file: %s", err)
+		}
+		raw = data
+	} else {
+		data, err := findControllerAppliance(config, name)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+
+	var appliance Appliance
+	if err := json.Unmarshal(raw, &appliance); err != nil {
+		return nil, fmt.Errorf("failed to decode appliance: %s", err)
+	}
+	if appliance.Qemu == nil {
+		return nil, fmt.Errorf("appliance %q is not a QEMU appliance", appliance.Name)
+	}
+	return &appliance, nil
+}
+
+// findControllerAppliance returns the definition of the appliance named name
+// from GET /v2/appliances.
+func findControllerAppliance(config *ProviderConfig, name string) ([]byte, error) {
+	url := fmt.Sprintf("%s/v2/appliances", config.Host)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list appliances: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list appliances, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var appliances []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&appliances); err != nil {
+		return nil, fmt.Errorf("failed to decode appliances: %s", err)
+	}
+	for _, raw := range appliances {
+		var a struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(raw, &a); err == nil && a.Name == name {
+			return raw, nil
+		}
+	}
+	return nil, fmt.Errorf("appliance %q not found on the controller", name)
+}
+
+// version returns the appliance version called name.
+func (a *Appliance) version(name string) (*ApplianceVersion, error) {
+	var known []string
+	for i := range a.Versions {
+		if a.Versions[i].Name == name {
+			return &a.Versions[i], nil
+		}
+		known = append(known, a.Versions[i].Name)
+	}
+	return nil, fmt.Errorf("appliance %q has no version %q, available versions: %s", a.Name, name, strings.Join(known, ", "))
+}
+
+// image returns the appliance image called filename, or nil.
+func (a *Appliance) image(filename string) *ApplianceImage {
+	for i := range a.Images {
+		if a.Images[i].Filename == filename {
+			return &a.Images[i]
+		}
+	}
+	return nil
+}
+
+// resolveApplianceImages checks that every image of a version is on the compute
+// with the checksum the appliance expects, and reports all missing ones at once.
+func resolveApplianceImages(config *ProviderConfig, computeID string, appliance *Appliance, version *ApplianceVersion) error {
+	images, err := listImages(config, computeID, "qemu")
+	if err != nil {
+		return err
+	}
+	available := map[string]Image{}
+	for _, image := range images {
+		available[image.Filename] = image
+		available[imageBaseName(image.Path)] = image
+	}
+
+	slots := make([]string, 0, len(version.Images))
+	for slot := range version.Images {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+
+	var problems []string
+	for _, slot := range slots {
+		filename := version.Images[slot]
+		expected := appliance.image(filename)
+		image, ok := available[filename]
+		switch {
+		case !ok:
+			problem := fmt.Sprintf("%s: %s is not uploaded to compute %s", slot, filename, computeID)
+			if expected != nil && expected.DownloadURL != "" {
+				problem += fmt.Sprintf(" (download it from %s)", expected.DownloadURL)
+			}
+			problems = append(problems, problem)
+		case expected != nil && expected.MD5Sum != "" && image.MD5Sum != "" && image.MD5Sum != expected.MD5Sum:
+			problems = append(problems, fmt.Sprintf("%s: %s has checksum %s, the appliance expects %s", slot, filename, image.MD5Sum, expected.MD5Sum))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("images of %s %s are not available:\n  %s", appliance.Name, version.Name, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// applianceNodePayload builds the node creation payload from an appliance
+// version; ram, cpus and adapters set on the resource override the appliance.
+func applianceNodePayload(d *schema.ResourceData, appliance *Appliance, version *ApplianceVersion) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, key := range applianceQemuProperties {
+		if v, ok := appliance.Qemu[key]; ok {
+			properties[key] = v
+		}
+	}
+	if arch, ok := appliance.Qemu["arch"].(string); ok {
+		properties["platform"] = arch
+	}
+	if appliance.LinkedClone != nil {
+		properties["linked_clone"] = *appliance.LinkedClone
+	}
+	for slot, filename := range version.Images {
+		properties[slot] = filename
+	}
+	for _, key := range []string{"ram", "cpus", "adapters"} {
+		if v, ok := d.GetOk(key); ok {
+			properties[key] = v.(int)
+		}
+	}
+
+	payload := map[string]interface{}{
+		"name":       d.Get("name").(string),
+		"node_type":  "qemu",
+		"compute_id": d.Get("compute_id").(string),
+		"properties": properties,
+	}
+	if appliance.PortNameFormat != "" {
+		payload["port_name_format"] = appliance.PortNameFormat
+	}
+	if appliance.PortSegmentSize != 0 {
+		payload["port_segment_size"] = appliance.PortSegmentSize
+	}
+	if v, ok := d.GetOkExists("console_auto_start"); ok {
+		payload["console_auto_start"] = v.(bool)
+	}
+	if appliance.FirstPortName != "" {
+		payload["first_port_name"] = appliance.FirstPortName
+	}
+	if xv, ok := d.GetOkExists("x"); ok {
+		payload["x"] = xv.(int)
+	}
+	if yv, ok := d.GetOkExists("y"); ok {
+		payload["y"] = yv.(int)
+	}
+	return payload
+}
+
+func resourceGns3ApplianceNodeCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+	computeID := d.Get("compute_id").(string)
+
+	appliance, err := loadAppliance(config, d.Get("appliance_file").(string), d.Get("appliance_name").(string))
+	if err != nil {
+		return err
+	}
+	version, err := appliance.version(d.Get("version").(string))
+	if err != nil {
+		return err
+	}
+	if err := resolveApplianceImages(config, computeID, appliance, version); err != nil {
+		return err
+	}
+
+	nodeID, err := createNode(config, projectID, applianceNodePayload(d, appliance, version))
+	if err != nil {
+		return err
+	}
+	d.SetId(nodeID)
+	d.Set("images", version.Images)
+
+	if startRequested(d, config, "start") {
+		if err := nodeAction(config, projectID, nodeID, "start"); err != nil {
+			return err
+		}
+	}
+
+	return resourceGns3ApplianceNodeRead(d, meta)
+}
+
+func resourceGns3ApplianceNodeRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	node, err := getNode(config, projectID, d.Id())
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("name", node["name"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("status", node["status"])
+	d.Set("console_auto_start", node["console_auto_start"] == true)
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	if props, ok := node["properties"].(map[string]interface{}); ok {
+		for _, key := range []string{"ram", "cpus", "adapters"} {
+			if _, ok := props[key]; ok {
+				d.Set(key, jsonInt(props[key]))
+			}
+		}
+	}
+	return nil
+}
+
+func resourceGns3ApplianceNodeUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	if d.HasChanges("name", "x", "y", "console_auto_start") {
+		update := map[string]interface{}{
+			"name": d.Get("name").(string),
+			"x":    d.Get("x").(int),
+			"y":    d.Get("y").(int),
+		}
+		if d.HasChange("console_auto_start") {
+			update["console_auto_start"] = d.Get("console_auto_start").(bool)
+		}
+		if err := updateNode(config, projectID, d.Id(), update); err != nil {
+			return err
+		}
+	}
+	return resourceGns3ApplianceNodeRead(d, meta)
+}
+
+func resourceGns3ApplianceNodeDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+	config := meta.(*ProviderConfig)
+	if err := deleteNode(config, d.Get("project_id").(string), d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
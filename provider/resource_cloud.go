@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Cloud represents a GNS3 cloud node API request/response.
@@ -18,24 +20,84 @@ type Cloud struct {
 	NodeType  string `json:"node_type"`
 	ComputeID string `json:"compute_id,omitempty"`
 	NodeID    string `json:"node_id,omitempty"`
+	Symbol    string `json:"symbol,omitempty"`
 	X         int    `json:"x,omitempty"`
 	Y         int    `json:"y,omitempty"`
+	// Properties carries the ports_mapping generated from mode.
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// cloudModes maps each cloud mode to the ports_mapping port type it generates.
+var cloudModes = map[string]string{
+	"bridge_to": "ethernet",
+	"host_only": "ethernet",
+	"tap":       "tap",
+}
+
+// cloudHostOnlyInterface is the host-only network of the GNS3 VM, used by
+// mode = "host_only" when no interfaces are given.
+const cloudHostOnlyInterface = "virbr0"
+
+// cloudPortsMapping returns a cloud ports_mapping with one port of portType per
+// host interface, numbered in order.
+func cloudPortsMapping(portType string, ifaces []string) []map[string]interface{} {
+	mapping := make([]map[string]interface{}, 0, len(ifaces))
+	for i, iface := range ifaces {
+		mapping = append(mapping, map[string]interface{}{
+			"interface":   iface,
+			"name":        iface,
+			"port_number": i,
+			"type":        portType,
+		})
+	}
+	return mapping
+}
+
+// cloudModeMapping returns the ports_mapping the mode and interfaces of a cloud
+// ask for, or nil when no mode is set.
+func cloudModeMapping(d *schema.ResourceData) []map[string]interface{} {
+	mode := d.Get("mode").(string)
+	if mode == "" {
+		return nil
+	}
+	var ifaces []string
+	for _, iface := range d.Get("interfaces").([]interface{}) {
+		ifaces = append(ifaces, iface.(string))
+	}
+	if len(ifaces) == 0 && mode == "host_only" {
+		ifaces = []string{cloudHostOnlyInterface}
+	}
+	return cloudPortsMapping(cloudModes[mode], ifaces)
+}
+
+// cloudModeCustomizeDiff rejects modes that need interfaces when none are given.
+func cloudModeCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	mode := d.Get("mode").(string)
+	if mode == "" || mode == "host_only" || !d.NewValueKnown("interfaces") {
+		return nil
+	}
+	if len(d.Get("interfaces").([]interface{})) == 0 {
+		return fmt.Errorf("mode = %q needs at least one host interface in interfaces", mode)
+	}
+	return nil
 }
 
 func resourceGns3Cloud() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceGns3CloudCreate,
-		Read:   resourceGns3CloudRead,
-		Update: resourceGns3CloudUpdate,
-		Delete: resourceGns3CloudDelete,
+		CreateContext: withCanvasCheck(resourceGns3CloudCreate),
+		Read:          resourceGns3CloudRead,
+		UpdateContext: withCanvasCheck(resourceGns3CloudUpdate),
+		Delete:        resourceGns3CloudDelete,
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceGns3CloudImporter,
 		},
+		CustomizeDiff: customdiff.All(computeCustomizeDiff, cloudModeCustomizeDiff),
 
 		Schema: map[string]*schema.Schema{
 			"project_id": {
 				Type:        schema.TypeString,
 				Required:    true,
+				ForceNew:    true,
 				Description: "The project ID where the cloud node is deployed.",
 			},
 			"name": {
@@ -47,7 +109,8 @@ func resourceGns3Cloud() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "local",
-				Description: "Compute ID where the cloud node is running.",
+				ForceNew:    true,
+				Description: "Compute ID where the cloud node is running. The controller cannot move a node, so changing it recreates the node.",
 			},
 			"x": { // ✅ Added X coordinate support
 				Type:        schema.TypeInt,
@@ -59,11 +122,37 @@ func resourceGns3Cloud() *schema.Resource {
 				Optional:    true,
 				Description: "Y position of the cloud node in GNS3 GUI.",
 			},
+			"mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(sortedKeys(cloudModes), false),
+				Description:  "Preset generating the cloud's ports: bridge_to bridges one port to each of interfaces, tap attaches one port to each tap interface, host_only bridges to the host-only network (default virbr0).",
+			},
+			"interfaces": {
+				Type:         schema.TypeList,
+				Optional:     true,
+				RequiredWith: []string{"mode"},
+				Description:  "Host interfaces the mode maps to cloud ports, in port order. Their names can be looked up with gns3_compute_interfaces.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"cloud_id": {
 				Type:        schema.TypeString,
 				Computed:    true,
 				Description: "The cloud node's ID assigned by GNS3.",
 			},
+			"symbol": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Symbol shown for the node on the canvas, e.g. :/symbols/cloud.svg. Defaults to the GNS3 cloud symbol.",
+			},
+			"status":         nodeStatusSchema(),
+			"ports":          nodePortsSchema(),
+			"gns3_url":       webURLSchema("Web UI link to the node's console."),
+			"adopt_existing": adoptExistingSchema(),
+			"protect":        protectSchema(),
 		},
 	}
 }
@@ -77,13 +166,26 @@ func resourceGns3CloudCreate(d *schema.ResourceData, meta interface{}) error {
 	x := d.Get("x").(int) // ✅ Retrieve X coordinate
 	y := d.Get("y").(int) // ✅ Retrieve Y coordinate
 
+	adopted, err := adoptExistingNode(d, config, projectID, name, "cloud")
+	if err != nil {
+		return err
+	}
+	if adopted {
+		d.Set("cloud_id", d.Id())
+		return resourceGns3CloudUpdate(d, meta)
+	}
+
 	cloud := Cloud{
 		Name:      name,
 		NodeType:  "cloud",
 		ComputeID: computeID,
+		Symbol:    d.Get("symbol").(string),
 		X:         x, // ✅ Add X coordinate to request
 		Y:         y, // ✅ Add Y coordinate to request
 	}
+	if mapping := cloudModeMapping(d); mapping != nil {
+		cloud.Properties = map[string]interface{}{"ports_mapping": mapping}
+	}
 
 	data, err := json.Marshal(cloud)
 	if err != nil {
@@ -91,7 +193,7 @@ func resourceGns3CloudCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes", host, projectID)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("error creating GNS3 cloud node: %s", err)
 	}
@@ -114,7 +216,7 @@ func resourceGns3CloudCreate(d *schema.ResourceData, meta interface{}) error {
 
 	d.SetId(createdCloud.NodeID)
 	d.Set("cloud_id", createdCloud.NodeID)
-	return nil
+	return resourceGns3CloudRead(d, meta)
 }
 
 // Update function for modifying existing cloud nodes
@@ -127,11 +229,19 @@ func resourceGns3CloudUpdate(d *schema.ResourceData, meta interface{}) error {
 	updateData := map[string]interface{}{}
 
 	if d.HasChange("name") {
-		updateData["name"] = d.Get("name").(string)
+		name := d.Get("name").(string)
+		updateData["name"] = name
+		label, err := renamedNodeLabel(config, projectID, cloudID, name)
+		if err != nil {
+			return err
+		}
+		if label != nil {
+			updateData["label"] = label
+		}
 	}
 
-	if d.HasChange("compute_id") {
-		updateData["compute_id"] = d.Get("compute_id").(string)
+	if d.HasChange("symbol") {
+		updateData["symbol"] = d.Get("symbol").(string)
 	}
 
 	if d.HasChange("x") {
@@ -142,6 +252,12 @@ func resourceGns3CloudUpdate(d *schema.ResourceData, meta interface{}) error {
 		updateData["y"] = d.Get("y").(int) // ✅ Update Y coordinate
 	}
 
+	if d.HasChanges("mode", "interfaces") {
+		if mapping := cloudModeMapping(d); mapping != nil {
+			updateData["properties"] = map[string]interface{}{"ports_mapping": mapping}
+		}
+	}
+
 	if len(updateData) == 0 {
 		return nil
 	}
@@ -158,8 +274,7 @@ func resourceGns3CloudUpdate(d *schema.ResourceData, meta interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error updating GNS3 cloud node: %s", err)
 	}
@@ -180,7 +295,7 @@ func resourceGns3CloudRead(d *schema.ResourceData, meta interface{}) error {
 	nodeID := d.Id()
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("error reading cloud node: %s", err)
 	}
@@ -197,29 +312,47 @@ func resourceGns3CloudRead(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("unexpected read status %d: %s", resp.StatusCode, body)
 	}
 
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("failed to decode cloud node: %s", err)
+	}
+	d.Set("name", node["name"])
+	d.Set("cloud_id", node["node_id"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("symbol", node["symbol"])
+	d.Set("status", node["status"])
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	// Interfaces are only read back for clouds managed through a mode
+	if d.Get("mode").(string) != "" {
+		props, _ := node["properties"].(map[string]interface{})
+		mapping, _ := props["ports_mapping"].([]interface{})
+		ifaces := make([]string, 0, len(mapping))
+		for _, p := range mapping {
+			port, _ := p.(map[string]interface{})
+			iface, _ := port["interface"].(string)
+			ifaces = append(ifaces, iface)
+		}
+		d.Set("interfaces", ifaces)
+	}
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+
 	return nil
 }
 
 func resourceGns3CloudDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "cloud"); err != nil {
+		return err
+	}
+
 	config := meta.(*ProviderConfig)
-	host := config.Host
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
-	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request for cloud node: %s", err)
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to delete cloud node: %s", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to delete cloud node, status code: %d", resp.StatusCode)
+	if err := deleteNode(config, projectID, nodeID); err != nil {
+		return err
 	}
 
 	d.SetId("")
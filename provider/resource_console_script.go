@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceGns3ConsoleScript runs send/expect steps on a node's telnet console
+// when it is created, and fails the apply when an expectation is not met. The
+// captured output can be used by other resources and outputs.
+func resourceGns3ConsoleScript() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceGns3ConsoleScriptCreate,
+		Read:   resourceGns3ConsoleScriptRead,
+		Delete: resourceGns3ConsoleScriptDelete,
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project the node belongs to.",
+			},
+			"node_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The node whose console runs the script. Its console_type must be telnet.",
+			},
+			"step": {
+				Type:        schema.TypeList,
+				Required:    true,
+				ForceNew:    true,
+				MinItems:    1,
+				Description: "Steps run in order: each sends a line, then waits for its output to match expect.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"send": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Line sent to the console, followed by a carriage return. Empty sends nothing, e.g. to wait for a prompt.",
+						},
+						"expect": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringIsValidRegExp,
+							Description:  "Regular expression the console output must match after send. Empty does not wait.",
+						},
+						"timeout": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      30,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "Seconds to wait for expect before the step fails.",
+						},
+					},
+				},
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Arbitrary values; changing any of them runs the script again.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"output": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Everything the console printed while the script ran.",
+			},
+			"outputs": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The console output of each step, in step order.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// consoleSession is a telnet console connection with the output read so far.
+type consoleSession struct {
+	conn   net.Conn
+	output []byte
+	// mark is where the output not yet consumed by an expectation starts
+	mark int
+}
+
+// dialConsole connects to a console, re-dialing until timeout while the node
+// brings it up.
+func dialConsole(addr string, timeout time.Duration) (*consoleSession, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err == nil {
+			return &consoleSession{conn: conn}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("console %s unreachable: %s", addr, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// send writes a line to the console.
+func (s *consoleSession) send(line string) error {
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// expect reads the console until the output since the last match matches re,
+// and returns that output.
+func (s *consoleSession) expect(re *regexp.Regexp, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 4096)
+	for {
+		if loc := re.FindIndex(s.output[s.mark:]); loc != nil {
+			out := string(s.output[s.mark : s.mark+loc[1]])
+			s.mark += loc[1]
+			return out, nil
+		}
+		s.conn.SetReadDeadline(deadline)
+		n, err := s.conn.Read(buf)
+		s.output = append(s.output, stripTelnetCommands(buf[:n])...)
+		if err != nil && n == 0 {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return string(s.output[s.mark:]), fmt.Errorf("console output did not match %q within %s", re, timeout)
+			}
+			return string(s.output[s.mark:]), fmt.Errorf("console closed: %s", err)
+		}
+	}
+}
+
+// unconsumed returns the output not yet consumed by an expectation.
+func (s *consoleSession) unconsumed() string {
+	out := string(s.output[s.mark:])
+	s.mark = len(s.output)
+	return out
+}
+
+func resourceGns3ConsoleScriptCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+	nodeID := d.Get("node_id").(string)
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("node %s not found in project %s", nodeID, projectID)
+	}
+	if consoleType, _ := node["console_type"].(string); consoleType != "telnet" {
+		return fmt.Errorf("console scripts need a telnet console, node %s has console_type %q", nodeID, consoleType)
+	}
+
+	addr := net.JoinHostPort(consoleHost(config, node), strconv.Itoa(jsonInt(node["console"])))
+	session, err := dialConsole(addr, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer session.conn.Close()
+
+	steps := d.Get("step").([]interface{})
+	outputs := make([]string, 0, len(steps))
+	for i, raw := range steps {
+		step := raw.(map[string]interface{})
+		send := step["send"].(string)
+		if send != "" {
+			log.Printf("[DEBUG] Console script step %d on node %s: sending %q", i, nodeID, send)
+			if err := session.send(send); err != nil {
+				return fmt.Errorf("step %d: failed to send %q: %s", i, send, err)
+			}
+		}
+
+		pattern := step["expect"].(string)
+		if pattern == "" {
+			outputs = append(outputs, session.unconsumed())
+			continue
+		}
+		out, err := session.expect(regexp.MustCompile(pattern), time.Duration(step["timeout"].(int))*time.Second)
+		if err != nil {
+			return fmt.Errorf("step %d of the console script on node %s failed: %s\nconsole output:\n%s", i, nodeID, err, out)
+		}
+		outputs = append(outputs, out)
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s/%d", projectID, nodeID, time.Now().UnixNano()))
+	d.Set("output", string(session.output))
+	d.Set("outputs", outputs)
+	return nil
+}
+
+// resourceGns3ConsoleScriptRead keeps the recorded output: a script is not run
+// again unless the resource is replaced.
+func resourceGns3ConsoleScriptRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourceGns3ConsoleScriptDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
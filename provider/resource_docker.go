@@ -9,16 +9,23 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // DockerProperties holds Docker-specific options for a node.
 type DockerProperties struct {
-	Image        string   `json:"image"`
-	Environment  *string  `json:"environment,omitempty"`
-	ConsoleType  string   `json:"console_type"`
-	ExtraVolumes []string `json:"extra_volumes,omitempty"`
-	StartCommand *string  `json:"start_command,omitempty"`
+	Image       string  `json:"image"`
+	Environment *string `json:"environment,omitempty"`
+	ConsoleType string  `json:"console_type"`
+	// ConsoleHTTPPort and ConsoleHTTPPath select the container web service the
+	// http/https console proxies to.
+	ConsoleHTTPPort int      `json:"console_http_port,omitempty"`
+	ConsoleHTTPPath string   `json:"console_http_path,omitempty"`
+	ExtraVolumes    []string `json:"extra_volumes,omitempty"`
+	StartCommand    *string  `json:"start_command,omitempty"`
+	Usage           string   `json:"usage,omitempty"`
 }
 
 // DockerNode represents the JSON payload for creating a Docker node.
@@ -28,24 +35,36 @@ type DockerNode struct {
 	ComputeID  string           `json:"compute_id,omitempty"`
 	Properties DockerProperties `json:"properties"`
 	NodeID     string           `json:"node_id,omitempty"`
-	X          int              `json:"x,omitempty"` // Added X coordinate
-	Y          int              `json:"y,omitempty"` // Added Y coordinate
+	// ConsoleType is also sent at the top level, where the controller keeps it.
+	ConsoleType string `json:"console_type,omitempty"`
+	X           int    `json:"x,omitempty"` // Added X coordinate
+	Y           int    `json:"y,omitempty"` // Added Y coordinate
+
+	ConsoleAutoStart *bool `json:"console_auto_start,omitempty"`
 }
 
+// resourceGns3Docker defines the Terraform resource schema for GNS3 Docker nodes.
+// GNS3 always runs these containers privileged with all capabilities added; the
+// controller API offers no properties to change that.
 func resourceGns3Docker() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceGns3DockerCreate,
-		Read:   resourceGns3DockerRead,
-		Update: resourceGns3DockerUpdate,
+		CreateContext: withCanvasCheck(resourceGns3DockerCreate),
+		Read:          resourceGns3DockerRead,
+		UpdateContext: updateWithWarnings(resourceGns3DockerUpdate, map[string]string{
+			"extra_volumes": "Extra volumes are only applied when the container is created. Recreate the node to change them.",
+			"start_command": "The start command is only applied when the container is created. Recreate the node to change it.",
+		}),
 		Delete: resourceGns3DockerDelete,
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceGns3DockerImporter,
 		},
+		CustomizeDiff: customdiff.All(computeCustomizeDiff, dockerPostStartCustomizeDiff, reloadOnChangeCustomizeDiff(resourceGns3Docker)),
 
 		Schema: map[string]*schema.Schema{
 			"project_id": {
 				Type:        schema.TypeString,
 				Required:    true,
+				ForceNew:    true,
 				Description: "The project ID where the Docker node will be created.",
 			},
 			"name": {
@@ -57,18 +76,33 @@ func resourceGns3Docker() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "local",
-				Description: "The compute ID (default: 'local').",
+				ForceNew:    true,
+				Description: "The compute ID (default: 'local'). The controller cannot move a node, so changing it recreates the node.",
 			},
 			"image": {
 				Type:        schema.TypeString,
 				Required:    true,
 				ForceNew:    true, // Ensures re-creation when image changes
 				Description: "The Docker image name. The image must be available in GNS3.",
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return dockerImageTag(old) == dockerImageTag(new)
+				},
 			},
 			"environment": {
-				Type:        schema.TypeMap,
-				Optional:    true,
-				Description: "Optional Docker environment variables in key-value format.",
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ValidateFunc: validateDockerEnvironment,
+				Description:  "Optional Docker environment variables in key-value format.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"sensitive_environment": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				Sensitive:    true,
+				ValidateFunc: validateDockerEnvironment,
+				Description:  "Environment variables holding secrets. Merged with environment (overriding it) and hidden from plan output.",
 				Elem: &schema.Schema{
 					Type: schema.TypeString,
 				},
@@ -101,16 +135,123 @@ func resourceGns3Docker() *schema.Resource {
 				Optional:    true,
 				Description: "Command to run when starting the Docker container.",
 			},
+			"usage": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Usage instructions shown for the node in the GNS3 GUI, e.g. credentials or how to reach the service.",
+			},
 			"start": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     true,
 				Description: "Whether to start the Docker container after creation.",
 			},
+			"post_start_commands": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Shell commands typed into the telnet console once the container is started, in order. A non-zero exit status fails the apply. They run again when the list changes.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"post_start_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      60,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Seconds each post-start command may take.",
+			},
+			"discover_ip": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "After the container starts, read the IPv4 address of eth0, e.g. leased by DHCP from a NAT or cloud node, into first_interface_ip. Needs a telnet console and the ip command in the container.",
+			},
+			"discover_ip_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      60,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Seconds to wait for eth0 to get an address.",
+			},
+			"first_interface_ip": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "IPv4 address of eth0 found by discover_ip when the container was last started by Terraform.",
+			},
+			"console_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "none",
+				ValidateFunc: validation.StringInSlice([]string{"none", "telnet", "vnc", "http", "https"}, false),
+				Description:  "Console type of the container. Use http or https to reach a web service in the container through the GNS3 console proxy.",
+			},
+			"console_http_port": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      80,
+				ValidateFunc: validation.IsPortNumber,
+				Description:  "Container port of the web service exposed by an http/https console.",
+			},
+			"console_http_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "/",
+				Description: "Path of the web service exposed by an http/https console.",
+			},
+			"console": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Host port of the console allocated by GNS3.",
+			},
+			"console_host": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Host to connect to for the console.",
+			},
+			"console_url": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "URL of the container web service when console_type is http or https.",
+			},
+			"mac_addresses": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "MAC address of each container adapter, indexed by adapter number, e.g. for DHCP reservations.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"auto_offset":          autoOffsetSchema(),
+			"uplink":               uplinkSchema(),
+			"ports":                nodePortsSchema(),
+			"gns3_url":             webURLSchema("Web UI link to the node's console."),
+			"status":               nodeStatusSchema(),
+			"adopt_existing":       adoptExistingSchema(),
+			"protect":              protectSchema(),
+			"reload_on_change":     reloadOnChangeSchema(),
+			"stop_before_update":   stopBeforeUpdateSchema(),
+			"restart_after_update": restartAfterUpdateSchema(),
+			"console_auto_start":   consoleAutoStartSchema(),
 		},
 	}
 }
 
+// dockerRestartAttributes are the settings GNS3 only applies when it recreates
+// the container, which it refuses to do while the container runs.
+var dockerRestartAttributes = []string{
+	"name", "environment", "sensitive_environment", "console_type", "console_http_port", "console_http_path",
+}
+
+// dockerImageTag returns an image reference with the tag GNS3 gives it: an image
+// without one is stored as its latest tag.
+func dockerImageTag(image string) string {
+	if image == "" || strings.Contains(image, ":") {
+		return image
+	}
+	return image + ":latest"
+}
+
 func resourceGns3DockerCreate(d *schema.ResourceData, meta interface{}) error {
 	config := meta.(*ProviderConfig)
 	host := config.Host
@@ -121,15 +262,18 @@ func resourceGns3DockerCreate(d *schema.ResourceData, meta interface{}) error {
 	x := d.Get("x").(int)
 	y := d.Get("y").(int)
 
-	// Convert environment map into a single string format (comma-separated key=value pairs)
+	adopted, err := adoptExistingNode(d, config, projectID, name, "docker")
+	if err != nil {
+		return err
+	}
+	if adopted {
+		d.Set("docker_id", d.Id())
+		return resourceGns3DockerUpdate(d, meta)
+	}
+
+	// Convert environment maps into the newline-separated KEY=VALUE format GNS3 expects
 	var envStr *string
-	if v, ok := d.GetOk("environment"); ok {
-		envVars := v.(map[string]interface{})
-		envList := []string{}
-		for key, value := range envVars {
-			envList = append(envList, fmt.Sprintf("%s=%s", key, value.(string)))
-		}
-		envFormatted := strings.Join(envList, ",")
+	if envFormatted := dockerEnvironment(d); envFormatted != "" {
 		envStr = &envFormatted
 	}
 
@@ -148,19 +292,37 @@ func resourceGns3DockerCreate(d *schema.ResourceData, meta interface{}) error {
 		startCommand = &cmd
 	}
 
+	var consoleAutoStart *bool
+	if v, ok := d.GetOkExists("console_auto_start"); ok {
+		autoStart := v.(bool)
+		consoleAutoStart = &autoStart
+	}
+
+	if offset := autoOffset(d); offset != nil {
+		if x, y, err = autoOffsetPosition(config, projectID, name, x, y, offset); err != nil {
+			return err
+		}
+	}
+
 	// Build the payload for the Docker node
 	dockerNode := DockerNode{
-		Name:      name,
-		NodeType:  "docker",
-		ComputeID: computeID,
-		X:         x,
-		Y:         y,
+		Name:        name,
+		NodeType:    "docker",
+		ComputeID:   computeID,
+		ConsoleType: d.Get("console_type").(string),
+		X:           x,
+		Y:           y,
+
+		ConsoleAutoStart: consoleAutoStart,
 		Properties: DockerProperties{
-			Image:        image,
-			Environment:  envStr,
-			ConsoleType:  "none",
-			ExtraVolumes: extraVolumes,
-			StartCommand: startCommand,
+			Image:           image,
+			Environment:     envStr,
+			ConsoleType:     d.Get("console_type").(string),
+			ConsoleHTTPPort: d.Get("console_http_port").(int),
+			ConsoleHTTPPath: d.Get("console_http_path").(string),
+			ExtraVolumes:    extraVolumes,
+			StartCommand:    startCommand,
+			Usage:           usageWithLabels(config, d.Get("usage").(string)),
 		},
 	}
 
@@ -178,8 +340,7 @@ func resourceGns3DockerCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %s", err)
 	}
@@ -204,26 +365,105 @@ func resourceGns3DockerCreate(d *schema.ResourceData, meta interface{}) error {
 	d.SetId(createdDocker.NodeID)
 	d.Set("docker_id", createdDocker.NodeID)
 
+	if err := syncUplink(d, config, projectID, computeID, createdDocker.NodeID); err != nil {
+		return err
+	}
+
 	// Optionally start the container
-	if d.Get("start").(bool) {
-		startURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/start", host, projectID, createdDocker.NodeID)
-		startReq, err := http.NewRequest("POST", startURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to build start request: %s", err)
+	if startRequested(d, config, "start") {
+		if err := nodeAction(config, projectID, createdDocker.NodeID, "start"); err != nil {
+			return err
 		}
-		startResp, err := client.Do(startReq)
-		if err != nil {
-			return fmt.Errorf("failed to start docker node: %s", err)
+	}
+
+	if err := runDockerPostStartCommands(d, config, projectID, createdDocker.NodeID); err != nil {
+		return err
+	}
+	if err := discoverDockerIP(d, config, projectID, createdDocker.NodeID); err != nil {
+		return err
+	}
+
+	return resourceGns3DockerRead(d, meta)
+}
+
+// dockerEnvironment merges environment and sensitive_environment into the
+// KEY=VALUE lines GNS3 passes to the container, sorted so the value only
+// changes when the variables do.
+func dockerEnvironment(d *schema.ResourceData) string {
+	env := map[string]string{}
+	for _, attr := range []string{"environment", "sensitive_environment"} {
+		for key, value := range d.Get(attr).(map[string]interface{}) {
+			env[key] = value.(string)
+		}
+	}
+
+	envList := make([]string, 0, len(env))
+	for _, key := range sortedKeys(env) {
+		envList = append(envList, fmt.Sprintf("%s=%s", key, env[key]))
+	}
+	return strings.Join(envList, "\n")
+}
+
+// parseDockerEnvironment splits the controller's environment string back into
+// variables. Lines without "=" are ignored, as GNS3 does.
+func parseDockerEnvironment(raw string) map[string]string {
+	env := map[string]string{}
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if key, value, ok := strings.Cut(line, "="); ok && key != "" {
+			env[key] = value
 		}
-		defer startResp.Body.Close()
+	}
+	return env
+}
 
-		if startResp.StatusCode != http.StatusOK {
-			startBody, _ := ioutil.ReadAll(startResp.Body)
-			return fmt.Errorf("failed to start docker node, status code: %d, response: %s", startResp.StatusCode, string(startBody))
+// validateDockerEnvironment rejects variables that cannot survive the
+// line-based environment format: names with "=" or line breaks, and values
+// with line breaks.
+func validateDockerEnvironment(v interface{}, k string) ([]string, []error) {
+	var errs []error
+	for key, value := range v.(map[string]interface{}) {
+		if key == "" || strings.ContainsAny(key, "=\r\n") {
+			errs = append(errs, fmt.Errorf("%s: invalid variable name %q", k, key))
+		}
+		if s, _ := value.(string); strings.ContainsAny(s, "\r\n") {
+			errs = append(errs, fmt.Errorf("%s: value of %q must not contain line breaks", k, key))
 		}
 	}
+	return nil, errs
+}
 
-	return nil
+// setDockerEnvironment reads the controller's environment back into state.
+// Variables declared in sensitive_environment stay there; everything else,
+// including variables added outside Terraform, lands in environment.
+func setDockerEnvironment(d *schema.ResourceData, node map[string]interface{}) error {
+	props, _ := node["properties"].(map[string]interface{})
+	raw, ok := props["environment"].(string)
+	if !ok && props["environment"] != nil {
+		return nil
+	}
+
+	configured := d.Get("environment").(map[string]interface{})
+	secrets := d.Get("sensitive_environment").(map[string]interface{})
+	env := map[string]interface{}{}
+	sensitive := map[string]interface{}{}
+	for key, value := range parseDockerEnvironment(raw) {
+		if _, isSecret := secrets[key]; isSecret {
+			sensitive[key] = value
+			// The sensitive value overrides environment, so the controller
+			// never sees the plain one; keep it as configured
+			if plain, ok := configured[key]; ok {
+				env[key] = plain
+			}
+			continue
+		}
+		env[key] = value
+	}
+
+	if err := d.Set("environment", env); err != nil {
+		return err
+	}
+	return d.Set("sensitive_environment", sensitive)
 }
 
 func resourceGns3DockerRead(d *schema.ResourceData, meta interface{}) error {
@@ -233,7 +473,7 @@ func resourceGns3DockerRead(d *schema.ResourceData, meta interface{}) error {
 	nodeID := d.Id()
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve Docker node: %s", err)
 	}
@@ -248,7 +488,60 @@ func resourceGns3DockerRead(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("failed to read Docker node, status code: %d", resp.StatusCode)
 	}
 
-	// Optionally, you can decode the response to update state further.
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("failed to decode Docker node: %s", err)
+	}
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	d.Set("mac_addresses", portMACAddresses(node))
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	d.Set("status", node["status"])
+	d.Set("console_auto_start", node["console_auto_start"] == true)
+	if err := setDockerEnvironment(d, node); err != nil {
+		return fmt.Errorf("failed to set environment: %s", err)
+	}
+	d.Set("name", node["name"])
+	d.Set("compute_id", node["compute_id"])
+	if consoleType, ok := node["console_type"].(string); ok {
+		d.Set("console_type", consoleType)
+	}
+	if props, ok := node["properties"].(map[string]interface{}); ok {
+		if image, ok := props["image"].(string); ok {
+			d.Set("image", image)
+		}
+		if port, ok := props["console_http_port"]; ok {
+			d.Set("console_http_port", jsonInt(port))
+		}
+		if path, ok := props["console_http_path"].(string); ok {
+			d.Set("console_http_path", path)
+		}
+		if usage, ok := props["usage"].(string); ok {
+			d.Set("usage", usageWithoutLabels(usage))
+		}
+	}
+	// with auto_offset x/y keep the configured grid origin
+	if autoOffset(d) != nil {
+		if err := setAutoOffsetPosition(d, node); err != nil {
+			return fmt.Errorf("failed to set auto_offset: %s", err)
+		}
+	} else {
+		d.Set("x", jsonInt(node["x"]))
+		d.Set("y", jsonInt(node["y"]))
+	}
+
+	console := jsonInt(node["console"])
+	consoleHostname := consoleHost(config, node)
+	d.Set("console", console)
+	d.Set("console_host", consoleHostname)
+
+	consoleURL := ""
+	if scheme := d.Get("console_type").(string); (scheme == "http" || scheme == "https") && console != 0 {
+		consoleURL = fmt.Sprintf("%s://%s:%d%s", scheme, consoleHostname, console, d.Get("console_http_path").(string))
+	}
+	d.Set("console_url", consoleURL)
+
 	return nil
 }
 
@@ -258,19 +551,63 @@ func resourceGns3DockerUpdate(d *schema.ResourceData, meta interface{}) error {
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		d.SetId("")
+		return nil
+	}
+	status, _ := node["status"].(string)
+	stopped, err := stopForUpdate(d, config, projectID, nodeID, status, dockerRestartAttributes...)
+	if err != nil {
+		return err
+	}
+
 	// Build the updated payload.
 	updateData := make(map[string]interface{})
-	if d.HasChange("environment") {
-		envVars := d.Get("environment").(map[string]interface{})
-		envList := []string{}
-		for key, value := range envVars {
-			envList = append(envList, fmt.Sprintf("%s=%s", key, value.(string)))
+	properties := make(map[string]interface{})
+	if d.HasChange("name") {
+		updateData["name"] = d.Get("name").(string)
+	}
+	if d.HasChange("x") {
+		if xv, ok := d.GetOkExists("x"); ok {
+			updateData["x"] = xv.(int)
+		}
+	}
+	if d.HasChange("y") {
+		if yv, ok := d.GetOkExists("y"); ok {
+			updateData["y"] = yv.(int)
+		}
+	}
+	if offset := autoOffset(d); offset != nil && d.HasChanges("x", "y", "auto_offset") {
+		x, y, err := autoOffsetPosition(config, projectID, d.Get("name").(string), d.Get("x").(int), d.Get("y").(int), offset)
+		if err != nil {
+			return err
 		}
-		envFormatted := strings.Join(envList, ",")
-		updateData["environment"] = envFormatted
+		updateData["x"], updateData["y"] = x, y
+	}
+	if d.HasChanges("environment", "sensitive_environment") {
+		properties["environment"] = dockerEnvironment(d)
+	}
+	if d.HasChange("console_type") {
+		updateData["console_type"] = d.Get("console_type").(string)
+	}
+	if d.HasChange("console_auto_start") {
+		updateData["console_auto_start"] = d.Get("console_auto_start").(bool)
+	}
+	if d.HasChange("usage") {
+		properties["usage"] = usageWithLabels(config, d.Get("usage").(string))
+	}
+	if d.HasChanges("console_http_port", "console_http_path") {
+		properties["console_http_port"] = d.Get("console_http_port").(int)
+		properties["console_http_path"] = d.Get("console_http_path").(string)
+	}
+	if len(properties) > 0 {
+		updateData["properties"] = properties
 	}
 	// Note: Image is ForceNew so we do not update it.
-	// Also, extra_volumes, x, and y are typically not updated dynamically, but you could add them if needed.
 
 	data, err := json.Marshal(updateData)
 	if err != nil {
@@ -284,8 +621,7 @@ func resourceGns3DockerUpdate(d *schema.ResourceData, meta interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to update Docker node: %s", err)
 	}
@@ -295,30 +631,53 @@ func resourceGns3DockerUpdate(d *schema.ResourceData, meta interface{}) error {
 		body, _ := ioutil.ReadAll(resp.Body)
 		return fmt.Errorf("failed to update Docker node, status code: %d, response: %s", resp.StatusCode, string(body))
 	}
-	if d.HasChange("start_command") {
-		updateData["start_command"] = d.Get("start_command").(string)
+
+	if err := restartAfterUpdate(d, config, projectID, nodeID, stopped); err != nil {
+		return err
+	}
+
+	if err := syncUplink(d, config, projectID, d.Get("compute_id").(string), nodeID); err != nil {
+		return err
+	}
+
+	if err := reloadNodeIfChanged(d, config, projectID, nodeID); err != nil {
+		return err
+	}
+
+	// A restarted container lost what the commands set up, so they run again
+	if d.HasChange("post_start_commands") || stopped {
+		if err := runDockerPostStartCommands(d, config, projectID, nodeID); err != nil {
+			return err
+		}
+	}
+	// A restarted container may lease another address
+	if d.HasChange("discover_ip") || stopped {
+		if err := discoverDockerIP(d, config, projectID, nodeID); err != nil {
+			return err
+		}
 	}
 
 	return resourceGns3DockerRead(d, meta)
 }
 
 func resourceGns3DockerDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+
 	config := meta.(*ProviderConfig)
-	host := config.Host
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
-	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request for docker node: %s", err)
+	if blocks := d.Get("uplink").([]interface{}); len(blocks) > 0 && blocks[0] != nil {
+		if err := deleteUplink(config, projectID, blocks[0].(map[string]interface{})); err != nil {
+			return err
+		}
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to delete docker node: %s", err)
+
+	if err := deleteNode(config, projectID, nodeID); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
 	d.SetId("")
 	return nil
@@ -341,6 +700,10 @@ func resourceGns3DockerImporter(
 	if err := d.Set("project_id", projectID); err != nil {
 		return nil, err
 	}
+	if err := setImportDefaults(d, resourceGns3Docker().Schema, "start", "protect", "adopt_existing", "stop_before_update",
+		"restart_after_update", "discover_ip", "discover_ip_timeout", "post_start_timeout"); err != nil {
+		return nil, err
+	}
 	d.SetId(nodeID)
 
 	return []*schema.ResourceData{d}, nil
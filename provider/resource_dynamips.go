@@ -0,0 +1,457 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dynamipsPlatform describes the chassis, network modules and WAN interface
+// cards a Dynamips platform accepts.
+type dynamipsPlatform struct {
+	// chassis are the models of the platform; the first one is the default.
+	chassis []string
+	// slots are the modules allowed in each slot, indexed by slot number.
+	slots [][]string
+	// chassisSlots limits the number of slots for chassis with fewer of them.
+	chassisSlots map[string]int
+	wics         int
+	wicModules   []string
+}
+
+var (
+	dynamipsNM2600 = []string{"NM-1FE-TX", "NM-16ESW", "NM-4T", "NM-1E", "NM-4E"}
+	dynamipsNM3600 = []string{"NM-1E", "NM-4E", "NM-1FE-TX", "NM-16ESW", "NM-4T"}
+	dynamipsNM37xx = []string{"NM-1FE-TX", "NM-4T", "NM-16ESW"}
+	dynamipsPA7200 = []string{"PA-A1", "PA-FE-TX", "PA-2FE-TX", "PA-GE", "PA-4T+", "PA-8T", "PA-4E", "PA-8E", "PA-POS-OC3"}
+	dynamipsWICs   = []string{"WIC-1T", "WIC-2T", "WIC-1ENET"}
+)
+
+// dynamipsPlatforms are the router platforms Dynamips emulates.
+var dynamipsPlatforms = map[string]dynamipsPlatform{
+	"c1700": {
+		chassis:    []string{"1720", "1721", "1750", "1751", "1760"},
+		slots:      [][]string{{"C1700-MB-1FE"}, {"C1700-MB-WIC1"}},
+		wics:       2,
+		wicModules: dynamipsWICs,
+	},
+	"c2600": {
+		chassis:    []string{"2610", "2611", "2620", "2621", "2610XM", "2611XM", "2620XM", "2621XM", "2650XM", "2651XM"},
+		slots:      [][]string{{"C2600-MB-1E", "C2600-MB-2E", "C2600-MB-1FE", "C2600-MB-2FE"}, dynamipsNM2600},
+		wics:       3,
+		wicModules: []string{"WIC-1T", "WIC-2T"},
+	},
+	"c2691": {
+		slots:      [][]string{{"GT96100-FE"}, dynamipsNM37xx},
+		wics:       3,
+		wicModules: dynamipsWICs,
+	},
+	"c3600": {
+		chassis:      []string{"3640", "3620", "3660"},
+		slots:        [][]string{append([]string{"Leopard-2FE"}, dynamipsNM3600...), dynamipsNM3600, dynamipsNM3600, dynamipsNM3600, dynamipsNM3600, dynamipsNM3600, dynamipsNM3600},
+		chassisSlots: map[string]int{"3620": 2, "3640": 4},
+	},
+	"c3725": {
+		slots:      [][]string{{"GT96100-FE"}, dynamipsNM37xx, dynamipsNM37xx},
+		wics:       3,
+		wicModules: dynamipsWICs,
+	},
+	"c3745": {
+		slots:      [][]string{{"GT96100-FE"}, dynamipsNM37xx, dynamipsNM37xx, dynamipsNM37xx, dynamipsNM37xx},
+		wics:       3,
+		wicModules: dynamipsWICs,
+	},
+	"c7200": {
+		slots: [][]string{{"C7200-IO-FE", "C7200-IO-2FE", "C7200-IO-GE-E"}, dynamipsPA7200, dynamipsPA7200, dynamipsPA7200, dynamipsPA7200, dynamipsPA7200, dynamipsPA7200},
+	},
+}
+
+// dynamipsSlots and dynamipsWICSlots are the module attributes of gns3_dynamips.
+var (
+	dynamipsSlots       = []string{"slot0", "slot1", "slot2", "slot3", "slot4", "slot5", "slot6"}
+	dynamipsWICSlots    = []string{"wic0", "wic1", "wic2"}
+	dynamipsModuleSlots = append(append([]string{}, dynamipsSlots...), dynamipsWICSlots...)
+)
+
+// dynamipsRestartAttributes are the settings Dynamips only changes on a stopped router.
+var dynamipsRestartAttributes = append([]string{"image", "ram", "nvram", "idlepc"}, dynamipsModuleSlots...)
+
+// resourceGns3Dynamips defines a Dynamips router node with its network modules
+// and WAN interface cards, checked against the platform at plan time.
+func resourceGns3Dynamips() *schema.Resource {
+	s := map[string]*schema.Schema{
+		"project_id": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The project the router is created in.",
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "The name of the router.",
+		},
+		"compute_id": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     "local",
+			Description: "The compute running the router.",
+		},
+		"platform": {
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringInSlice(sortedPlatforms(), false),
+			Description:  "Router platform: c1700, c2600, c2691, c3600, c3725, c3745 or c7200.",
+		},
+		"chassis": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "Chassis of c1700, c2600 and c3600 routers, e.g. 3640. Defaults to the first model of the platform.",
+		},
+		"image": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "IOS image from the compute's Dynamips image directory.",
+		},
+		"ram": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Computed:    true,
+			Description: "RAM in MB. Defaults to the platform's.",
+		},
+		"nvram": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Computed:    true,
+			Description: "NVRAM in KB. Defaults to the platform's.",
+		},
+		"idlepc": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			Description: "Idle-PC value of the image, e.g. 0x60630d08, to keep the host CPU from spinning.",
+		},
+		"x": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "X coordinate of the node on the GNS3 canvas.",
+		},
+		"y": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "Y coordinate of the node on the GNS3 canvas.",
+		},
+		"start": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether to start the router after creation.",
+		},
+		"ports":                nodePortsSchema(),
+		"gns3_url":             webURLSchema("Web UI link to the node's console."),
+		"status":               nodeStatusSchema(),
+		"protect":              protectSchema(),
+		"stop_before_update":   stopBeforeUpdateSchema(),
+		"restart_after_update": restartAfterUpdateSchema(),
+	}
+	for i, slot := range dynamipsSlots {
+		s[slot] = &schema.Schema{
+			Type:             schema.TypeString,
+			Optional:         true,
+			DiffSuppressFunc: suppressDynamipsOnboardModule,
+			Description:      fmt.Sprintf("Module in slot %d, e.g. NM-1FE-TX or PA-GE. Onboard modules the chassis comes with are kept when unset.", i),
+		}
+	}
+	for i, wic := range dynamipsWICSlots {
+		s[wic] = &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: fmt.Sprintf("WAN interface card %d, e.g. WIC-2T.", i),
+		}
+	}
+
+	return &schema.Resource{
+		CreateContext: withCanvasCheck(resourceGns3DynamipsCreate),
+		Read:          resourceGns3DynamipsRead,
+		Update:        resourceGns3DynamipsUpdate,
+		Delete:        resourceGns3DynamipsDelete,
+		CustomizeDiff: customdiff.All(computeCustomizeDiff, dynamipsModulesCustomizeDiff),
+		Schema:        s,
+	}
+}
+
+// sortedPlatforms returns the names of the Dynamips platforms.
+func sortedPlatforms() []string {
+	platforms := make([]string, 0, len(dynamipsPlatforms))
+	for name := range dynamipsPlatforms {
+		platforms = append(platforms, name)
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// checkDynamipsModules returns an error describing the first module that does
+// not fit the platform and chassis, or nil. modules maps slotN and wicN to the
+// configured module; empty modules are not checked.
+func checkDynamipsModules(platformName, chassis string, modules map[string]string) error {
+	platform := dynamipsPlatforms[platformName]
+	if chassis != "" && len(platform.chassis) > 0 {
+		if !containsString(platform.chassis, chassis) {
+			return fmt.Errorf("chassis %q is not a %s model; use one of %s", chassis, platformName, strings.Join(platform.chassis, ", "))
+		}
+	} else if chassis != "" {
+		return fmt.Errorf("the %s platform has no chassis models, remove chassis", platformName)
+	}
+	if chassis == "" && len(platform.chassis) > 0 {
+		chassis = platform.chassis[0]
+	}
+
+	slotCount := len(platform.slots)
+	if n, ok := platform.chassisSlots[chassis]; ok {
+		slotCount = n
+	}
+	for i, slot := range dynamipsSlots {
+		module := modules[slot]
+		if module == "" {
+			continue
+		}
+		if i >= slotCount {
+			return fmt.Errorf("%s: a %s %s has slots 0-%d only", slot, platformName, chassis, slotCount-1)
+		}
+		if !containsString(platform.slots[i], module) {
+			return fmt.Errorf("%s: module %q does not fit slot %d of a %s; use one of %s", slot, module, i, platformName, strings.Join(platform.slots[i], ", "))
+		}
+	}
+	for i, wic := range dynamipsWICSlots {
+		module := modules[wic]
+		if module == "" {
+			continue
+		}
+		if i >= platform.wics {
+			if platform.wics == 0 {
+				return fmt.Errorf("%s: the %s platform takes no WAN interface cards", wic, platformName)
+			}
+			return fmt.Errorf("%s: a %s has WIC slots 0-%d only", wic, platformName, platform.wics-1)
+		}
+		if !containsString(platform.wicModules, module) {
+			return fmt.Errorf("%s: %q is not a WAN interface card of a %s; use one of %s", wic, module, platformName, strings.Join(platform.wicModules, ", "))
+		}
+	}
+	return nil
+}
+
+// suppressDynamipsOnboardModule hides the onboard module GNS3 puts in slot 0,
+// or in any slot that accepts a single module, when the slot is not configured.
+func suppressDynamipsOnboardModule(k, old, new string, d *schema.ResourceData) bool {
+	if new != "" || old == "" {
+		return false
+	}
+	platform, ok := dynamipsPlatforms[d.Get("platform").(string)]
+	if !ok {
+		return false
+	}
+	for i, slot := range dynamipsSlots {
+		if slot == k && i < len(platform.slots) {
+			return i == 0 || len(platform.slots[i]) == 1
+		}
+	}
+	return false
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// dynamipsModulesCustomizeDiff rejects modules a platform does not accept, which
+// Dynamips would otherwise only report when the router is created or started.
+func dynamipsModulesCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("platform") {
+		return nil
+	}
+	// An unset chassis is computed; the platform default is checked then
+	chassis := ""
+	if d.NewValueKnown("chassis") {
+		chassis = d.Get("chassis").(string)
+	}
+	modules := map[string]string{}
+	for _, key := range dynamipsModuleSlots {
+		if !d.NewValueKnown(key) {
+			continue
+		}
+		modules[key] = d.Get(key).(string)
+	}
+	return checkDynamipsModules(d.Get("platform").(string), chassis, modules)
+}
+
+// dynamipsProperties returns the node properties for the configured settings.
+// On update only changed settings are included.
+func dynamipsProperties(d *schema.ResourceData) map[string]interface{} {
+	properties := map[string]interface{}{}
+	set := func(key string, value interface{}) {
+		if d.IsNewResource() || d.HasChange(key) {
+			properties[key] = value
+		}
+	}
+	if d.IsNewResource() {
+		properties["platform"] = d.Get("platform").(string)
+		if v, ok := d.GetOk("chassis"); ok {
+			properties["chassis"] = v.(string)
+		}
+	}
+	set("image", d.Get("image").(string))
+	for _, key := range []string{"ram", "nvram"} {
+		if v, ok := d.GetOk(key); ok {
+			set(key, v.(int))
+		}
+	}
+	if v, ok := d.GetOk("idlepc"); ok {
+		set("idlepc", v.(string))
+	}
+	for _, key := range dynamipsModuleSlots {
+		if module := d.Get(key).(string); module != "" {
+			set(key, module)
+		} else if !d.IsNewResource() {
+			set(key, nil)
+		}
+	}
+	return properties
+}
+
+func resourceGns3DynamipsCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	payload := map[string]interface{}{
+		"name":       d.Get("name").(string),
+		"node_type":  "dynamips",
+		"compute_id": d.Get("compute_id").(string),
+		"properties": dynamipsProperties(d),
+	}
+	if xv, ok := d.GetOkExists("x"); ok {
+		payload["x"] = xv.(int)
+	}
+	if yv, ok := d.GetOkExists("y"); ok {
+		payload["y"] = yv.(int)
+	}
+
+	nodeID, err := createNode(config, projectID, payload)
+	if err != nil {
+		return err
+	}
+	d.SetId(nodeID)
+
+	if startRequested(d, config, "start") {
+		if err := nodeAction(config, projectID, nodeID, "start"); err != nil {
+			return err
+		}
+	}
+
+	return resourceGns3DynamipsRead(d, meta)
+}
+
+func resourceGns3DynamipsRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	node, err := getNode(config, projectID, d.Id())
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("name", node["name"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("status", node["status"])
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+
+	props, _ := node["properties"].(map[string]interface{})
+	for _, key := range []string{"platform", "chassis", "image", "idlepc"} {
+		if v, ok := props[key].(string); ok {
+			d.Set(key, v)
+		}
+	}
+	for _, key := range []string{"ram", "nvram"} {
+		if _, ok := props[key]; ok {
+			d.Set(key, jsonInt(props[key]))
+		}
+	}
+	for _, key := range dynamipsModuleSlots {
+		module, _ := props[key].(string)
+		d.Set(key, module)
+	}
+	return nil
+}
+
+func resourceGns3DynamipsUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+	nodeID := d.Id()
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		d.SetId("")
+		return nil
+	}
+	status, _ := node["status"].(string)
+	stopped, err := stopForUpdate(d, config, projectID, nodeID, status, dynamipsRestartAttributes...)
+	if err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{}
+	if properties := dynamipsProperties(d); len(properties) > 0 {
+		update["properties"] = properties
+	}
+	for _, key := range []string{"name", "x", "y"} {
+		if d.HasChange(key) {
+			update[key] = d.Get(key)
+		}
+	}
+	if len(update) > 0 {
+		if err := updateNode(config, projectID, nodeID, update); err != nil {
+			return err
+		}
+	}
+
+	if err := restartAfterUpdate(d, config, projectID, nodeID, stopped); err != nil {
+		return err
+	}
+	return resourceGns3DynamipsRead(d, meta)
+}
+
+func resourceGns3DynamipsDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+	config := meta.(*ProviderConfig)
+	if err := deleteNode(config, d.Get("project_id").(string), d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,289 @@
+package provider
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Image represents an entry of a compute's image listing.
+type Image struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	MD5Sum   string `json:"md5sum"`
+	Filesize int64  `json:"filesize"`
+}
+
+// resourceGns3Image uploads a disk or firmware image to a compute.
+func resourceGns3Image() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceGns3ImageCreate,
+		Read:   resourceGns3ImageRead,
+		// Only discovery_timeout can change in place and it has no remote counterpart
+		Update:        resourceGns3ImageRead,
+		Delete:        resourceGns3ImageDelete,
+		CustomizeDiff: computeCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"source": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Local path of the image file to upload.",
+			},
+			"filename": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringDoesNotContainAny(`/\`),
+				Description:  "Name of the image on the compute. Defaults to the base name of source, with / or \\ as separator.",
+			},
+			"emulator": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "qemu",
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{"qemu", "iou", "dynamips"}, false),
+				Description:  "Emulator the image is for: qemu, iou or dynamips.",
+			},
+			"compute_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "local",
+				ForceNew:    true,
+				Description: "The compute to upload the image to.",
+			},
+			"discovery_timeout": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     300,
+				Description: "Seconds to wait for the compute to list the uploaded image.",
+			},
+			"md5sum": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "MD5 checksum of the image as reported by the compute.",
+			},
+			"filesize": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Size of the image in bytes.",
+			},
+		},
+	}
+}
+
+// windowsDrivePath matches a Windows path starting with a drive letter.
+var windowsDrivePath = regexp.MustCompile(`^[A-Za-z]:`)
+
+// normalizeImagePath rewrites a Windows image path (C:\GNS3\images\a.qcow2) with
+// forward slashes and an upper-case drive letter, which gns3server accepts on
+// Windows, so the same image is always sent and stored the same way. Other paths
+// are only trimmed.
+func normalizeImagePath(v interface{}) string {
+	p := strings.TrimSpace(v.(string))
+	if !windowsDrivePath.MatchString(p) && !strings.Contains(p, `\`) {
+		return p
+	}
+	p = strings.ReplaceAll(p, `\`, "/")
+	if windowsDrivePath.MatchString(p) {
+		p = strings.ToUpper(p[:1]) + p[1:]
+	}
+	return p
+}
+
+// validateImagePath rejects image paths the compute would resolve differently
+// than intended: drive-relative Windows paths such as C:images\a.qcow2.
+func validateImagePath(v interface{}, k string) ([]string, []error) {
+	p := strings.TrimSpace(v.(string))
+	if windowsDrivePath.MatchString(p) && len(p) > 2 && p[2] != '\\' && p[2] != '/' {
+		return nil, []error{fmt.Errorf("%s: %q is relative to the current directory of drive %s; use an absolute path such as %s\\%s", k, p, p[:2], p[:2], p[2:])}
+	}
+	return nil, nil
+}
+
+// imagePathSchema returns the schema of an image attribute that accepts names
+// relative to the compute's image directory as well as Windows or POSIX paths.
+func imagePathSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeString,
+		Optional:     true,
+		StateFunc:    normalizeImagePath,
+		ValidateFunc: validateImagePath,
+		Description:  description + " Windows paths may use backslashes; they are sent with forward slashes.",
+	}
+}
+
+// imageBaseName returns the file name of a local image path, treating both
+// slashes and backslashes as separators whatever the provider runs on.
+func imageBaseName(source string) string {
+	if i := strings.LastIndexAny(source, `/\`); i >= 0 {
+		return source[i+1:]
+	}
+	return source
+}
+
+// fileMD5 returns the hex MD5 checksum of a local file.
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// listImages returns the images a compute knows for an emulator.
+func listImages(config *ProviderConfig, computeID, emulator string) ([]Image, error) {
+	url := fmt.Sprintf("%s/v2/computes/%s/%s/images", config.Host, computeID, emulator)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list images, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var images []Image
+	if err := json.NewDecoder(resp.Body).Decode(&images); err != nil {
+		return nil, fmt.Errorf("failed to decode images: %s", err)
+	}
+	return images, nil
+}
+
+// findImage looks an image up by filename in a compute's listing.
+func findImage(config *ProviderConfig, computeID, emulator, filename string) (*Image, error) {
+	images, err := listImages(config, computeID, emulator)
+	if err != nil {
+		return nil, err
+	}
+	for i := range images {
+		if images[i].Filename == filename || imageBaseName(images[i].Path) == filename {
+			return &images[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// waitForImage polls the compute until it lists the image, with the expected
+// checksum when one is known, so nodes created afterwards can use it.
+func waitForImage(config *ProviderConfig, computeID, emulator, filename, checksum string, timeout time.Duration) (*Image, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		image, err := findImage(config, computeID, emulator, filename)
+		if err != nil {
+			return nil, err
+		}
+		if image != nil && (checksum == "" || image.MD5Sum == "" || strings.EqualFold(image.MD5Sum, checksum)) {
+			return image, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("image %s was not listed by compute %s within %s", filename, computeID, timeout)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func resourceGns3ImageCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	source := d.Get("source").(string)
+	computeID := d.Get("compute_id").(string)
+	emulator := d.Get("emulator").(string)
+
+	filename := d.Get("filename").(string)
+	if filename == "" {
+		filename = imageBaseName(source)
+	}
+
+	checksum, err := fileMD5(source)
+	if err != nil {
+		return fmt.Errorf("failed to read image %s: %s", source, err)
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open image %s: %s", source, err)
+	}
+	defer f.Close()
+
+	url := fmt.Sprintf("%s/v2/computes/%s/%s/images/%s", host, computeID, emulator, neturl.PathEscape(filename))
+	resp, err := config.Client.withoutTimeout().Post(url, "application/octet-stream", f)
+	if err != nil {
+		return fmt.Errorf("error uploading image %s: %s", filename, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to upload image, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	// Nothing was uploaded in a dry run, so the compute never lists the image
+	if config.Client.dryRun != nil {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to read image %s: %s", source, err)
+		}
+		d.SetId(fmt.Sprintf("%s/%s/%s", computeID, emulator, filename))
+		d.Set("filename", filename)
+		d.Set("md5sum", checksum)
+		d.Set("filesize", info.Size())
+		return nil
+	}
+
+	// The compute indexes uploads asynchronously; wait until it lists the file
+	timeout := time.Duration(d.Get("discovery_timeout").(int)) * time.Second
+	if _, err := waitForImage(config, computeID, emulator, filename, checksum, timeout); err != nil {
+		return err
+	}
+
+	d.SetId(fmt.Sprintf("%s/%s/%s", computeID, emulator, filename))
+	d.Set("filename", filename)
+	return resourceGns3ImageRead(d, meta)
+}
+
+func resourceGns3ImageRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	image, err := findImage(config, d.Get("compute_id").(string), d.Get("emulator").(string), d.Get("filename").(string))
+	if err != nil {
+		return err
+	}
+	if image == nil {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("md5sum", image.MD5Sum)
+	d.Set("filesize", image.Filesize)
+	return nil
+}
+
+// resourceGns3ImageDelete only forgets the image: the GNS3 v2 API cannot remove
+// images from a compute, so the file stays in its images directory.
+func resourceGns3ImageDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,255 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// iouL2Image matches the names Cisco gives IOL layer 2 (switching) images,
+// e.g. i86bi-linux-l2-adventerprisek9-15.1a.bin.
+var iouL2Image = regexp.MustCompile(`(?i)(^|[-_])l2([-_.]|$)`)
+
+// iouRestartAttributes are the settings IOU only changes on a stopped node.
+var iouRestartAttributes = []string{"path", "ethernet_adapters", "serial_adapters", "ram", "nvram", "l1_keepalives"}
+
+// resourceGns3IOU defines an IOU (IOS on Linux) node, used for layer 2 switching
+// and layer 3 routing labs.
+func resourceGns3IOU() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withCanvasCheck(resourceGns3IOUCreate),
+		Read:          resourceGns3IOURead,
+		Update:        resourceGns3IOUUpdate,
+		Delete:        resourceGns3IOUDelete,
+		CustomizeDiff: computeCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project the node is created in.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the node.",
+			},
+			"compute_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "local",
+				Description: "The compute running the node. IOU needs a Linux compute, such as the GNS3 VM.",
+			},
+			"path": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "IOU image from the compute's IOU image directory, e.g. i86bi-linux-l2-adventerprisek9-15.1a.bin.",
+			},
+			"image_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "l2 for layer 2 (switching) images and l3 for the others, guessed from the image name.",
+			},
+			"ethernet_adapters": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      2,
+				ValidateFunc: validation.IntBetween(0, 16),
+				Description:  "Number of Ethernet adapters, each with 4 ports.",
+			},
+			"serial_adapters": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      2,
+				ValidateFunc: validation.IntBetween(0, 16),
+				Description:  "Number of serial adapters, each with 4 ports.",
+			},
+			"ram": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     256,
+				Description: "RAM in MB.",
+			},
+			"nvram": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     128,
+				Description: "NVRAM in KB.",
+			},
+			"l1_keepalives": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Emulate layer 1 keepalives, so interfaces go down when their link is suspended or deleted.",
+			},
+			"x": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "X coordinate of the node on the GNS3 canvas.",
+			},
+			"y": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Y coordinate of the node on the GNS3 canvas.",
+			},
+			"start": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether to start the node after creation. A license must be set up, e.g. with gns3_iou_license.",
+			},
+			"ports":                nodePortsSchema(),
+			"gns3_url":             webURLSchema("Web UI link to the node's console."),
+			"status":               nodeStatusSchema(),
+			"protect":              protectSchema(),
+			"stop_before_update":   stopBeforeUpdateSchema(),
+			"restart_after_update": restartAfterUpdateSchema(),
+		},
+	}
+}
+
+// iouImageType returns l2 for layer 2 IOU images and l3 for the others.
+func iouImageType(path string) string {
+	if iouL2Image.MatchString(imageBaseName(path)) {
+		return "l2"
+	}
+	return "l3"
+}
+
+// iouProperties returns the node properties for the configured settings. On
+// update only changed settings are included.
+func iouProperties(d *schema.ResourceData) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, key := range iouRestartAttributes {
+		if d.IsNewResource() || d.HasChange(key) {
+			properties[key] = d.Get(key)
+		}
+	}
+	return properties
+}
+
+func resourceGns3IOUCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	payload := map[string]interface{}{
+		"name":       d.Get("name").(string),
+		"node_type":  "iou",
+		"compute_id": d.Get("compute_id").(string),
+		"properties": iouProperties(d),
+	}
+	if xv, ok := d.GetOkExists("x"); ok {
+		payload["x"] = xv.(int)
+	}
+	if yv, ok := d.GetOkExists("y"); ok {
+		payload["y"] = yv.(int)
+	}
+
+	nodeID, err := createNode(config, projectID, payload)
+	if err != nil {
+		return err
+	}
+	d.SetId(nodeID)
+
+	if startRequested(d, config, "start") {
+		if err := nodeAction(config, projectID, nodeID, "start"); err != nil {
+			return err
+		}
+	}
+
+	return resourceGns3IOURead(d, meta)
+}
+
+func resourceGns3IOURead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	node, err := getNode(config, projectID, d.Id())
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("name", node["name"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("status", node["status"])
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+
+	props, _ := node["properties"].(map[string]interface{})
+	if path, ok := props["path"].(string); ok {
+		d.Set("path", path)
+	}
+	for _, key := range []string{"ethernet_adapters", "serial_adapters", "ram", "nvram"} {
+		if _, ok := props[key]; ok {
+			d.Set(key, jsonInt(props[key]))
+		}
+	}
+	if keepalives, ok := props["l1_keepalives"].(bool); ok {
+		d.Set("l1_keepalives", keepalives)
+	}
+	d.Set("image_type", iouImageType(d.Get("path").(string)))
+	return nil
+}
+
+func resourceGns3IOUUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+	nodeID := d.Id()
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		d.SetId("")
+		return nil
+	}
+	status, _ := node["status"].(string)
+	stopped, err := stopForUpdate(d, config, projectID, nodeID, status, iouRestartAttributes...)
+	if err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{}
+	if properties := iouProperties(d); len(properties) > 0 {
+		update["properties"] = properties
+	}
+	for _, key := range []string{"name", "x", "y"} {
+		if d.HasChange(key) {
+			update[key] = d.Get(key)
+		}
+	}
+	if len(update) > 0 {
+		if err := updateNode(config, projectID, nodeID, update); err != nil {
+			return err
+		}
+	}
+
+	if err := restartAfterUpdate(d, config, projectID, nodeID, stopped); err != nil {
+		return err
+	}
+	return resourceGns3IOURead(d, meta)
+}
+
+func resourceGns3IOUDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+	config := meta.(*ProviderConfig)
+	if err := deleteNode(config, d.Get("project_id").(string), d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
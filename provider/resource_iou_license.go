@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// iouLicenseID is the ID of the controller's single IOU license.
+const iouLicenseID = "iou_license"
+
+// IOULicense is the controller-wide IOU license (iourc) sent to computes
+// running IOU nodes.
+type IOULicense struct {
+	IOURCContent string `json:"iourc_content"`
+	LicenseCheck bool   `json:"license_check"`
+}
+
+// resourceGns3IOULicense manages the controller's IOU license. There is one per
+// controller; destroying the resource clears it.
+func resourceGns3IOULicense() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceGns3IOULicenseWrite,
+		Read:          resourceGns3IOULicenseRead,
+		Update:        resourceGns3IOULicenseWrite,
+		Delete:        resourceGns3IOULicenseDelete,
+		CustomizeDiff: iouLicenseCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"license_file": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"license_file", "license_content"},
+				Description:  "Path to an iourc file. Changes to its content are applied on the next apply.",
+			},
+			"license_content": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Content of the iourc file, e.g. from a secret store.",
+			},
+			"license_check": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Whether computes check the license before starting IOU nodes.",
+			},
+			"content_sha256": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-256 of the license on the controller, so changes made in the GUI show up as drift.",
+			},
+		},
+	}
+}
+
+// iouLicenseContent returns the configured license.
+func iouLicenseContent(get func(string) interface{}) (string, error) {
+	if path := get("license_file").(string); path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read IOU license file: %s", err)
+		}
+		return string(content), nil
+	}
+	return get("license_content").(string), nil
+}
+
+// contentSHA256 returns the hex SHA-256 of content.
+func contentSHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// iouLicenseCustomizeDiff plans an update when the license file changed, or
+// when the license on the controller differs from the configured one.
+func iouLicenseCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("license_file") || !d.NewValueKnown("license_content") {
+		return d.SetNewComputed("content_sha256")
+	}
+	content, err := iouLicenseContent(d.Get)
+	if err != nil {
+		return err
+	}
+	if sum := contentSHA256(content); sum != d.Get("content_sha256").(string) {
+		return d.SetNew("content_sha256", sum)
+	}
+	return nil
+}
+
+// putIOULicense replaces the controller's IOU license.
+func putIOULicense(config *ProviderConfig, license IOULicense) error {
+	data, err := json.Marshal(license)
+	if err != nil {
+		return fmt.Errorf("failed to marshal IOU license: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/iou_license", config.Host)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create IOU license request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update IOU license: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update IOU license, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func resourceGns3IOULicenseWrite(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	content, err := iouLicenseContent(d.Get)
+	if err != nil {
+		return err
+	}
+	if err := putIOULicense(config, IOULicense{IOURCContent: content, LicenseCheck: d.Get("license_check").(bool)}); err != nil {
+		return err
+	}
+
+	d.SetId(iouLicenseID)
+	return resourceGns3IOULicenseRead(d, meta)
+}
+
+func resourceGns3IOULicenseRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	url := fmt.Sprintf("%s/v2/iou_license", config.Host)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to read IOU license: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to read IOU license, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var license IOULicense
+	if err := json.NewDecoder(resp.Body).Decode(&license); err != nil {
+		return fmt.Errorf("failed to decode IOU license: %s", err)
+	}
+
+	d.Set("license_check", license.LicenseCheck)
+	d.Set("content_sha256", contentSHA256(license.IOURCContent))
+	return nil
+}
+
+func resourceGns3IOULicenseDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	if err := putIOULicense(config, IOULicense{LicenseCheck: true}); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // LinkNode represents a node in a GNS3 link.
@@ -22,14 +23,88 @@ type LinkNode struct {
 
 // Link represents a GNS3 link between nodes.
 type Link struct {
-	LinkID string     `json:"link_id,omitempty"`
-	Nodes  []LinkNode `json:"nodes"`
+	LinkID  string           `json:"link_id,omitempty"`
+	Nodes   []LinkNode       `json:"nodes"`
+	Filters map[string][]int `json:"filters,omitempty"`
+	// Capturing and CaptureFileName report a running packet capture; they are read only.
+	Capturing       bool   `json:"capturing,omitempty"`
+	CaptureFileName string `json:"capture_file_name,omitempty"`
+	// Suspend reports a link whose cable is disconnected; no traffic passes it.
+	Suspend bool `json:"suspend,omitempty"`
 }
 
-func waitForNode(host, projectID, nodeID string) error {
-	url := fmt.Sprintf("%s/v2/projects/%s/nodes", host, projectID)
+// linkBandwidthFilter is the link filter type used for bandwidth limits.
+const linkBandwidthFilter = "bandwidth"
+
+// linkSupportsFilter reports whether the controller offers a filter type on a link.
+func linkSupportsFilter(config *ProviderConfig, projectID, linkID, filterType string) (bool, error) {
+	url := fmt.Sprintf("%s/v2/projects/%s/links/%s/available_filters", config.Host, projectID, linkID)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return false, fmt.Errorf("failed to get available link filters: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return false, fmt.Errorf("failed to get available link filters, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var filters []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&filters); err != nil {
+		return false, fmt.Errorf("failed to decode available link filters: %s", err)
+	}
+	for _, f := range filters {
+		if f["type"] == filterType {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setLinkBandwidth applies (or, with 0, removes) a bandwidth limit on a link.
+func setLinkBandwidth(config *ProviderConfig, projectID, linkID string, kbps int) error {
+	filters := map[string][]int{}
+	if kbps > 0 {
+		supported, err := linkSupportsFilter(config, projectID, linkID, linkBandwidthFilter)
+		if err != nil {
+			return err
+		}
+		if !supported {
+			return fmt.Errorf("the controller offers no %q filter on link %s; bandwidth_kbps requires a GNS3 version with bandwidth shaping", linkBandwidthFilter, linkID)
+		}
+		filters[linkBandwidthFilter] = []int{kbps}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"filters": filters})
+	if err != nil {
+		return fmt.Errorf("failed to marshal link filters: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/links/%s", config.Host, projectID, linkID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create link filter request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to set link filters: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set link filters, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func waitForNode(config *ProviderConfig, projectID, nodeID string) error {
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes", config.Host, projectID)
 	for i := 0; i < 10; i++ {
-		resp, err := http.Get(url)
+		resp, err := config.Client.Get(url)
 		if err != nil {
 			return fmt.Errorf("failed to query nodes: %s", err)
 		}
@@ -52,6 +127,120 @@ func waitForNode(host, projectID, nodeID string) error {
 	return fmt.Errorf("node %s not found in controller after polling", nodeID)
 }
 
+// allocateLinkPort returns the first free adapter/port of a node. exclude names
+// an "adapter/port" already taken by the other end of the link being created.
+func allocateLinkPort(config *ProviderConfig, projectID, nodeID, exclude string) (int, int, error) {
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if node == nil {
+		return 0, 0, fmt.Errorf("node %s not found in project %s", nodeID, projectID)
+	}
+	free, err := nodeFreePorts(config, projectID, node)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, port := range free {
+		adapter, number := port["adapter_number"].(int), port["port_number"].(int)
+		if fmt.Sprintf("%d/%d", adapter, number) != exclude {
+			return adapter, number, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("node %q has no free port left", node["name"])
+}
+
+// linkEndpointKeys are the attributes naming the ports a link connects.
+var linkEndpointKeys = []string{"project_id", "node_a_id", "node_a_adapter", "node_a_port", "node_b_id", "node_b_adapter", "node_b_port"}
+
+// findNodePort returns the port of a node at adapter/port, or nil when the node
+// reports no such port.
+func findNodePort(node map[string]interface{}, adapter, number int) map[string]interface{} {
+	for _, port := range flattenNodePorts(node) {
+		if port["adapter_number"].(int) == adapter && port["port_number"].(int) == number {
+			return port
+		}
+	}
+	return nil
+}
+
+// checkLinkEndpoints reports the links the controller would reject: a port a node
+// does not have, ports of different types, or a port another link already uses.
+// linkID is the link being changed, whose own ports are not counted as used.
+// Nodes that do not exist yet, or report no ports, are not checked.
+func checkLinkEndpoints(config *ProviderConfig, projectID, linkID string, ends []LinkNode) error {
+	var names, types []string
+	for _, end := range ends {
+		node, err := getNode(config, projectID, end.NodeID)
+		if err != nil {
+			return err
+		}
+		if node == nil || len(flattenNodePorts(node)) == 0 {
+			return nil
+		}
+		name, _ := node["name"].(string)
+		port := findNodePort(node, end.AdapterNumber, end.PortNumber)
+		if port == nil {
+			return fmt.Errorf("node %q has no port %d on adapter %d", name, end.PortNumber, end.AdapterNumber)
+		}
+		portName, _ := port["name"].(string)
+		linkType, _ := port["link_type"].(string)
+		names = append(names, fmt.Sprintf("%q port %s", name, portName))
+		types = append(types, linkType)
+	}
+
+	if ends[0] == ends[1] {
+		return fmt.Errorf("cannot link node %s to itself", names[0])
+	}
+	if types[0] != "" && types[1] != "" && types[0] != types[1] {
+		return fmt.Errorf("cannot link node %s (%s) to node %s (%s): the port types differ", names[0], types[0], names[1], types[1])
+	}
+
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if link.LinkID == linkID {
+			continue
+		}
+		for _, used := range link.Nodes {
+			for i, end := range ends {
+				if used == end {
+					return fmt.Errorf("node %s is already used by link %s", names[i], link.LinkID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// linkCustomizeDiff checks the ports of a new or changed link at plan time, once
+// the nodes and ports are known.
+func linkCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	config, ok := meta.(*ProviderConfig)
+	if !ok || (d.Id() != "" && !d.HasChanges(linkEndpointKeys...)) {
+		return nil
+	}
+	if d.Get("node_a_auto_port").(bool) || d.Get("node_b_auto_port").(bool) {
+		return nil
+	}
+	for _, key := range linkEndpointKeys {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+	return checkLinkEndpoints(config, d.Get("project_id").(string), d.Id(), linkEnds(d.Get))
+}
+
+// linkEnds returns the two ends of a link as configured.
+func linkEnds(get func(string) interface{}) []LinkNode {
+	return []LinkNode{
+		{NodeID: get("node_a_id").(string), AdapterNumber: get("node_a_adapter").(int), PortNumber: get("node_a_port").(int)},
+		{NodeID: get("node_b_id").(string), AdapterNumber: get("node_b_adapter").(int), PortNumber: get("node_b_port").(int)},
+	}
+}
+
 // resourceGns3Link defines the GNS3 link resource schema.
 func resourceGns3Link() *schema.Resource {
 	return &schema.Resource{
@@ -62,11 +251,13 @@ func resourceGns3Link() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceGns3LinkImporter,
 		},
+		CustomizeDiff: linkCustomizeDiff,
 
 		Schema: map[string]*schema.Schema{
 			"project_id": {
 				Type:        schema.TypeString,
 				Required:    true,
+				ForceNew:    true,
 				Description: "The project ID in which the link is created.",
 			},
 			"node_a_id": {
@@ -76,13 +267,23 @@ func resourceGns3Link() *schema.Resource {
 			},
 			"node_a_adapter": {
 				Type:        schema.TypeInt,
-				Required:    true,
-				Description: "Adapter number for the first node.",
+				Optional:    true,
+				Computed:    true,
+				Description: "Adapter number for the first node. Chosen by the provider with node_a_auto_port.",
 			},
 			"node_a_port": {
 				Type:        schema.TypeInt,
-				Required:    true,
-				Description: "Port number for the first node.",
+				Optional:    true,
+				Computed:    true,
+				Description: "Port number for the first node. Chosen by the provider with node_a_auto_port.",
+			},
+			"node_a_auto_port": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ForceNew:      true,
+				ConflictsWith: []string{"node_a_adapter", "node_a_port"},
+				Description:   "Link the first free adapter/port of the first node instead of node_a_adapter/node_a_port.",
 			},
 			"node_b_id": {
 				Type:        schema.TypeString,
@@ -91,19 +292,55 @@ func resourceGns3Link() *schema.Resource {
 			},
 			"node_b_adapter": {
 				Type:        schema.TypeInt,
-				Required:    true,
-				Description: "Adapter number for the second node.",
+				Optional:    true,
+				Computed:    true,
+				Description: "Adapter number for the second node. Chosen by the provider with node_b_auto_port.",
 			},
 			"node_b_port": {
 				Type:        schema.TypeInt,
-				Required:    true,
-				Description: "Port number for the second node.",
+				Optional:    true,
+				Computed:    true,
+				Description: "Port number for the second node. Chosen by the provider with node_b_auto_port.",
+			},
+			"node_b_auto_port": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ForceNew:      true,
+				ConflictsWith: []string{"node_b_adapter", "node_b_port"},
+				Description:   "Link the first free adapter/port of the second node instead of node_b_adapter/node_b_port.",
+			},
+			"bandwidth_kbps": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Limit the link to this bandwidth in kbit/s to simulate WAN links. Requires a controller offering a bandwidth link filter; 0 removes the limit.",
 			},
 			"link_id": {
 				Type:        schema.TypeString,
 				Computed:    true,
 				Description: "The unique ID of the link returned by the GNS3 API.",
 			},
+			"node_a_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the first node.",
+			},
+			"node_b_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the second node.",
+			},
+			"capturing": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether a packet capture is running on the link.",
+			},
+			"capture_file_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "File the running packet capture writes to.",
+			},
 		},
 	}
 }
@@ -119,27 +356,39 @@ func resourceGns3LinkCreate(d *schema.ResourceData, meta interface{}) error {
 	nodeBID := d.Get("node_b_id").(string)
 
 	// Poll the controller until both nodes are registered
-	if err := waitForNode(host, projectID, nodeAID); err != nil {
+	if err := waitForNode(config, projectID, nodeAID); err != nil {
 		return fmt.Errorf("node A not found: %s", err)
 	}
-	if err := waitForNode(host, projectID, nodeBID); err != nil {
+	if err := waitForNode(config, projectID, nodeBID); err != nil {
 		return fmt.Errorf("node B not found: %s", err)
 	}
 
-	// Build the link payload.
-	link := Link{
-		Nodes: []LinkNode{
-			{
-				NodeID:        nodeAID,
-				AdapterNumber: d.Get("node_a_adapter").(int),
-				PortNumber:    d.Get("node_a_port").(int),
-			},
-			{
-				NodeID:        nodeBID,
-				AdapterNumber: d.Get("node_b_adapter").(int),
-				PortNumber:    d.Get("node_b_port").(int),
-			},
-		},
+	// Pick free ports where requested. Links are created one at a time meanwhile,
+	// so the next allocation sees this link
+	if d.Get("node_a_auto_port").(bool) || d.Get("node_b_auto_port").(bool) {
+		config.portMu.Lock()
+		defer config.portMu.Unlock()
+	}
+	for _, side := range []string{"node_a", "node_b"} {
+		if !d.Get(side + "_auto_port").(bool) {
+			continue
+		}
+		exclude := ""
+		if side == "node_b" && d.Get("node_a_id") == nodeBID {
+			exclude = fmt.Sprintf("%d/%d", d.Get("node_a_adapter").(int), d.Get("node_a_port").(int))
+		}
+		adapter, port, err := allocateLinkPort(config, projectID, d.Get(side+"_id").(string), exclude)
+		if err != nil {
+			return err
+		}
+		d.Set(side+"_adapter", adapter)
+		d.Set(side+"_port", port)
+	}
+
+	// Build the link payload, rejecting ports the controller would refuse
+	link := Link{Nodes: linkEnds(d.Get)}
+	if err := checkLinkEndpoints(config, projectID, "", link.Nodes); err != nil {
+		return err
 	}
 
 	linkData, err := json.Marshal(link)
@@ -148,7 +397,7 @@ func resourceGns3LinkCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	url := fmt.Sprintf("%s/v2/projects/%s/links", host, projectID)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(linkData))
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(linkData))
 	if err != nil {
 		return fmt.Errorf("failed to create link: %s", err)
 	}
@@ -169,7 +418,13 @@ func resourceGns3LinkCreate(d *schema.ResourceData, meta interface{}) error {
 
 	d.SetId(createdLink.LinkID)
 	d.Set("link_id", createdLink.LinkID)
-	return nil
+
+	if kbps := d.Get("bandwidth_kbps").(int); kbps > 0 {
+		if err := setLinkBandwidth(config, projectID, createdLink.LinkID, kbps); err != nil {
+			return err
+		}
+	}
+	return resourceGns3LinkRead(d, meta)
 }
 
 func resourceGns3LinkRead(d *schema.ResourceData, meta interface{}) error {
@@ -179,7 +434,7 @@ func resourceGns3LinkRead(d *schema.ResourceData, meta interface{}) error {
 	linkID := d.Id()
 
 	url := fmt.Sprintf("%s/v2/projects/%s/links/%s", host, projectID, linkID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("error reading GNS3 link: %s", err)
 	}
@@ -196,7 +451,43 @@ func resourceGns3LinkRead(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("failed to read link, status code: %d, response: %s", resp.StatusCode, string(body))
 	}
 
-	// Optionally parse and update fields if needed
+	var link Link
+	if err := json.NewDecoder(resp.Body).Decode(&link); err != nil {
+		return fmt.Errorf("failed to decode link: %s", err)
+	}
+	bandwidth := 0
+	if v := link.Filters[linkBandwidthFilter]; len(v) > 0 {
+		bandwidth = v[0]
+	}
+	d.Set("bandwidth_kbps", bandwidth)
+	d.Set("link_id", linkID)
+	d.Set("capturing", link.Capturing)
+	d.Set("capture_file_name", link.CaptureFileName)
+
+	if len(link.Nodes) != 2 {
+		return fmt.Errorf("link %s does not connect exactly two nodes", linkID)
+	}
+	// Keep the ends in the order of the configuration; imports take the controller's order
+	ends := link.Nodes
+	if ends[1].NodeID == d.Get("node_a_id").(string) && ends[0].NodeID != ends[1].NodeID {
+		ends = []LinkNode{ends[1], ends[0]}
+	}
+	for i, side := range []string{"node_a", "node_b"} {
+		d.Set(side+"_id", ends[i].NodeID)
+		d.Set(side+"_adapter", ends[i].AdapterNumber)
+		d.Set(side+"_port", ends[i].PortNumber)
+
+		node, err := getNode(config, projectID, ends[i].NodeID)
+		if err != nil {
+			return err
+		}
+		name := ""
+		if node != nil {
+			name, _ = node["name"].(string)
+		}
+		d.Set(side+"_name", name)
+	}
+
 	return nil
 }
 
@@ -208,19 +499,11 @@ func resourceGns3LinkUpdate(d *schema.ResourceData, meta interface{}) error {
 	linkID := d.Id()
 
 	// Build the update payload with the updated attributes.
-	link := Link{
-		Nodes: []LinkNode{
-			{
-				NodeID:        d.Get("node_a_id").(string),
-				AdapterNumber: d.Get("node_a_adapter").(int),
-				PortNumber:    d.Get("node_a_port").(int),
-			},
-			{
-				NodeID:        d.Get("node_b_id").(string),
-				AdapterNumber: d.Get("node_b_adapter").(int),
-				PortNumber:    d.Get("node_b_port").(int),
-			},
-		},
+	link := Link{Nodes: linkEnds(d.Get)}
+	if d.HasChanges(linkEndpointKeys...) {
+		if err := checkLinkEndpoints(config, projectID, linkID, link.Nodes); err != nil {
+			return err
+		}
 	}
 
 	linkData, err := json.Marshal(link)
@@ -235,8 +518,7 @@ func resourceGns3LinkUpdate(d *schema.ResourceData, meta interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to update link: %s", err)
 	}
@@ -248,6 +530,12 @@ func resourceGns3LinkUpdate(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("failed to update link, status code: %d, error: %v", resp.StatusCode, errorResponse)
 	}
 
+	if d.HasChange("bandwidth_kbps") {
+		if err := setLinkBandwidth(config, projectID, linkID, d.Get("bandwidth_kbps").(int)); err != nil {
+			return err
+		}
+	}
+
 	// Optionally re-read the resource state.
 	return resourceGns3LinkRead(d, meta)
 }
@@ -255,31 +543,8 @@ func resourceGns3LinkUpdate(d *schema.ResourceData, meta interface{}) error {
 // resourceGns3LinkDelete deletes the link.
 func resourceGns3LinkDelete(d *schema.ResourceData, meta interface{}) error {
 	config := meta.(*ProviderConfig)
-	host := config.Host
-	projectID := d.Get("project_id").(string)
-	linkID := d.Id()
-
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/v2/projects/%s/links/%s", host, projectID, linkID), nil)
-	if err != nil {
-		return fmt.Errorf("error creating delete request: %s", err)
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error deleting GNS3 link: %s", err)
-	}
-	defer resp.Body.Close()
-
-	// Ignore 404 errors during delete — treat as already gone
-	if resp.StatusCode == http.StatusNotFound {
-		d.SetId("")
-		return nil
-	}
-
-	if resp.StatusCode != http.StatusNoContent {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("failed to delete GNS3 link, status code: %d, response: %s", resp.StatusCode, string(body))
+	if err := deleteLink(config, d.Get("project_id").(string), d.Id()); err != nil {
+		return err
 	}
 
 	d.SetId("")
@@ -0,0 +1,285 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceGns3LinkSet links a group of nodes in a star, ring or full mesh, picking
+// free ports automatically. Links missing on the controller or no longer wanted
+// after a change of nodes are reconciled in place.
+func resourceGns3LinkSet() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceGns3LinkSetCreate,
+		Read:          resourceGns3LinkSetRead,
+		Update:        resourceGns3LinkSetUpdate,
+		Delete:        resourceGns3LinkSetDelete,
+		CustomizeDiff: linkSetCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project the nodes belong to.",
+			},
+			"topology": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"star", "ring", "mesh"}, false),
+				Description:  "Shape of the link set: star (hub_node_id to every node), ring (each node to the next, the last back to the first) or mesh (every pair of nodes).",
+			},
+			"hub_node_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Center of a star topology.",
+			},
+			"node_ids": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Description: "Nodes to link: the spokes of a star, or the members of a ring or mesh in order.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"links": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Links of the set with the ports chosen for them.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"link_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_a_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_a_adapter": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"node_a_port": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"node_b_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"node_b_adapter": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"node_b_port": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// linkSetPairs returns the node pairs a topology connects, without duplicates.
+func linkSetPairs(topology, hub string, nodes []string) ([][2]string, error) {
+	var pairs [][2]string
+	switch topology {
+	case "star":
+		if hub == "" {
+			return nil, fmt.Errorf("hub_node_id is required for a star topology")
+		}
+		for _, n := range nodes {
+			pairs = append(pairs, [2]string{hub, n})
+		}
+	case "ring":
+		for i := range nodes {
+			pairs = append(pairs, [2]string{nodes[i], nodes[(i+1)%len(nodes)]})
+		}
+	case "mesh":
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				pairs = append(pairs, [2]string{nodes[i], nodes[j]})
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	unique := pairs[:0]
+	for _, p := range pairs {
+		if p[0] == p[1] || seen[linkSetPairKey(p[0], p[1])] {
+			continue
+		}
+		seen[linkSetPairKey(p[0], p[1])] = true
+		unique = append(unique, p)
+	}
+	return unique, nil
+}
+
+// linkSetPairKey identifies a pair of nodes regardless of order.
+func linkSetPairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
+
+// linkSetWanted returns the pairs the configuration asks for.
+func linkSetWanted(d interface{ Get(string) interface{} }) ([][2]string, error) {
+	var nodes []string
+	for _, n := range d.Get("node_ids").([]interface{}) {
+		nodes = append(nodes, n.(string))
+	}
+	return linkSetPairs(d.Get("topology").(string), d.Get("hub_node_id").(string), nodes)
+}
+
+// linkSetCustomizeDiff plans an update when links of the set went missing, so
+// they are recreated, and marks links as changing whenever the shape changes.
+func linkSetCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("node_ids") || !d.NewValueKnown("hub_node_id") {
+		return d.SetNewComputed("links")
+	}
+	wanted, err := linkSetWanted(d)
+	if err != nil {
+		return err
+	}
+	if d.Id() == "" {
+		return nil
+	}
+	if d.HasChanges("topology", "hub_node_id", "node_ids") || len(d.Get("links").([]interface{})) != len(wanted) {
+		return d.SetNewComputed("links")
+	}
+	return nil
+}
+
+// reconcileLinkSet creates the wanted links missing from state and deletes the
+// ones no longer wanted.
+func reconcileLinkSet(d *schema.ResourceData, config *ProviderConfig) error {
+	projectID := d.Get("project_id").(string)
+	wanted, err := linkSetWanted(d)
+	if err != nil {
+		return err
+	}
+	wantedKeys := map[string]bool{}
+	for _, p := range wanted {
+		wantedKeys[linkSetPairKey(p[0], p[1])] = true
+	}
+
+	// Keep the links still wanted, delete the rest
+	old, _ := d.GetChange("links")
+	existing := map[string]map[string]interface{}{}
+	for _, raw := range old.([]interface{}) {
+		link := raw.(map[string]interface{})
+		key := linkSetPairKey(link["node_a_id"].(string), link["node_b_id"].(string))
+		if wantedKeys[key] {
+			existing[key] = link
+			continue
+		}
+		if err := deleteLink(config, projectID, link["link_id"].(string)); err != nil {
+			return err
+		}
+	}
+
+	links := make([]interface{}, 0, len(wanted))
+	defer func() { d.Set("links", links) }()
+
+	config.portMu.Lock()
+	defer config.portMu.Unlock()
+	for _, p := range wanted {
+		if link, ok := existing[linkSetPairKey(p[0], p[1])]; ok {
+			links = append(links, link)
+			continue
+		}
+
+		aAdapter, aPort, err := allocateLinkPort(config, projectID, p[0], "")
+		if err != nil {
+			return err
+		}
+		bAdapter, bPort, err := allocateLinkPort(config, projectID, p[1], "")
+		if err != nil {
+			return err
+		}
+		linkID, err := createLink(config, projectID,
+			LinkNode{NodeID: p[0], AdapterNumber: aAdapter, PortNumber: aPort},
+			LinkNode{NodeID: p[1], AdapterNumber: bAdapter, PortNumber: bPort})
+		if err != nil {
+			return fmt.Errorf("failed to link %s and %s: %s", p[0], p[1], err)
+		}
+		links = append(links, map[string]interface{}{
+			"link_id":        linkID,
+			"node_a_id":      p[0],
+			"node_a_adapter": aAdapter,
+			"node_a_port":    aPort,
+			"node_b_id":      p[1],
+			"node_b_adapter": bAdapter,
+			"node_b_port":    bPort,
+		})
+	}
+	return nil
+}
+
+func resourceGns3LinkSetCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return fmt.Errorf("failed to generate link set ID: %s", err)
+	}
+	d.SetId(id)
+
+	// Links created before a failure stay in state so they are cleaned up on destroy
+	if err := reconcileLinkSet(d, config); err != nil {
+		return err
+	}
+	return resourceGns3LinkSetRead(d, meta)
+}
+
+func resourceGns3LinkSetRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	present, err := listProjectLinks(config, d.Get("project_id").(string))
+	if err != nil {
+		return err
+	}
+	ids := map[string]bool{}
+	for _, link := range present {
+		ids[link.LinkID] = true
+	}
+
+	// Links removed outside Terraform are dropped here and recreated on the next apply
+	var links []interface{}
+	for _, raw := range d.Get("links").([]interface{}) {
+		if link := raw.(map[string]interface{}); ids[link["link_id"].(string)] {
+			links = append(links, link)
+		}
+	}
+	return d.Set("links", links)
+}
+
+func resourceGns3LinkSetUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := reconcileLinkSet(d, meta.(*ProviderConfig)); err != nil {
+		return err
+	}
+	return resourceGns3LinkSetRead(d, meta)
+}
+
+func resourceGns3LinkSetDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	for _, raw := range d.Get("links").([]interface{}) {
+		if err := deleteLink(config, projectID, raw.(map[string]interface{})["link_id"].(string)); err != nil {
+			return err
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
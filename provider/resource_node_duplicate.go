@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceGns3NodeDuplicate defines a resource that clones an existing node.
+func resourceGns3NodeDuplicate() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withCanvasCheck(resourceGns3NodeDuplicateCreate),
+		Read:          resourceGns3NodeDuplicateRead,
+		UpdateContext: withCanvasCheck(resourceGns3NodeDuplicateUpdate),
+		Delete:        resourceGns3NodeDuplicateDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceGns3NodeDuplicateImporter,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project containing the source node.",
+			},
+			"source_node_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the node to duplicate.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Name of the clone. Defaults to the name generated by GNS3.",
+			},
+			"x": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				Description: "X position of the clone in GNS3 GUI.",
+			},
+			"y": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				Description: "Y position of the clone in GNS3 GUI.",
+			},
+			"z": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     1,
+				Description: "Stacking order of the clone.",
+			},
+			"node_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the cloned node.",
+			},
+			"node_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The node type of the clone.",
+			},
+			"ports":    nodePortsSchema(),
+			"gns3_url": webURLSchema("Web UI link to the node's console."),
+			"protect":  protectSchema(),
+		},
+	}
+}
+
+func resourceGns3NodeDuplicateCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+	sourceID := d.Get("source_node_id").(string)
+
+	payload := map[string]interface{}{
+		"x": d.Get("x").(int),
+		"y": d.Get("y").(int),
+		"z": d.Get("z").(int),
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal duplicate request: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/duplicate", host, projectID, sourceID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to duplicate node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to duplicate node, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("failed to decode duplicate response: %s", err)
+	}
+	nodeID, ok := node["node_id"].(string)
+	if !ok || nodeID == "" {
+		return fmt.Errorf("failed to retrieve node_id from GNS3 API response")
+	}
+	d.SetId(nodeID)
+
+	// Rename the clone if a name was requested
+	if v, ok := d.GetOk("name"); ok && v.(string) != node["name"] {
+		if err := updateNode(config, projectID, nodeID, map[string]interface{}{"name": v.(string)}); err != nil {
+			return err
+		}
+	}
+
+	return resourceGns3NodeDuplicateRead(d, meta)
+}
+
+func resourceGns3NodeDuplicateRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, d.Id())
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to read duplicated node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		d.SetId("")
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to read duplicated node, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("failed to decode duplicated node: %s", err)
+	}
+
+	d.Set("node_id", node["node_id"])
+	d.Set("node_type", node["node_type"])
+	d.Set("name", node["name"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("z", jsonInt(node["z"]))
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	return nil
+}
+
+func resourceGns3NodeDuplicateUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	updateData := map[string]interface{}{}
+	if d.HasChange("name") {
+		updateData["name"] = d.Get("name").(string)
+	}
+	if d.HasChange("x") {
+		updateData["x"] = d.Get("x").(int)
+	}
+	if d.HasChange("y") {
+		updateData["y"] = d.Get("y").(int)
+	}
+	if d.HasChange("z") {
+		updateData["z"] = d.Get("z").(int)
+	}
+
+	if len(updateData) > 0 {
+		if err := updateNode(config, projectID, d.Id(), updateData); err != nil {
+			return err
+		}
+	}
+
+	return resourceGns3NodeDuplicateRead(d, meta)
+}
+
+func resourceGns3NodeDuplicateDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	if err := deleteNode(config, projectID, d.Id()); err != nil {
+		return err
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceGns3NodeDuplicateImporter(
+	ctx context.Context,
+	d *schema.ResourceData,
+	meta interface{},
+) ([]*schema.ResourceData, error) {
+	raw := d.Id()
+	var projectID, nodeID string
+
+	if parts := strings.SplitN(raw, "/", 2); len(parts) == 2 {
+		projectID = parts[0]
+		nodeID = parts[1]
+	} else {
+		return nil, fmt.Errorf("invalid import ID %q — expected format <project_id>/<node_id>", raw)
+	}
+
+	if err := d.Set("project_id", projectID); err != nil {
+		return nil, err
+	}
+	d.SetId(nodeID)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,435 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// defaultNodeSize is used for nodes the controller reports no symbol size for.
+const defaultNodeSize = 60
+
+// svgSize matches the height and width attributes of a drawing's SVG root.
+var svgSize = regexp.MustCompile(`^<svg height="(\d+)" width="(\d+)"`)
+
+// resourceGns3NodeGroup draws a labeled rectangle around a set of nodes, sized
+// from their positions on the canvas, and follows them when they move.
+func resourceGns3NodeGroup() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withCanvasCheck(resourceGns3NodeGroupCreate),
+		Read:          resourceGns3NodeGroupRead,
+		Update:        resourceGns3NodeGroupUpdate,
+		Delete:        resourceGns3NodeGroupDelete,
+		CustomizeDiff: nodeGroupCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project the nodes belong to.",
+			},
+			"node_ids": {
+				Type:        schema.TypeSet,
+				Required:    true,
+				MinItems:    1,
+				Description: "The nodes the rectangle surrounds.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"label": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Text drawn above the top left corner of the rectangle.",
+			},
+			"padding": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      20,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Space between the nodes and the rectangle, in pixels.",
+			},
+			"color": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "#000000",
+				ValidateFunc: validation.StringMatch(hexColor, "must be a hex color such as #ff0000"),
+				Description:  "Color of the border and the label.",
+			},
+			"fill_color": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "#ffffff",
+				ValidateFunc: validation.StringMatch(hexColor, "must be a hex color such as #ff0000"),
+				Description:  "Fill color of the rectangle.",
+			},
+			"border_width": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      2,
+				ValidateFunc: validation.IntBetween(0, 20),
+				Description:  "Width of the border in pixels.",
+			},
+			"z": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     -1,
+				Description: "Stacking order of the rectangle. The default keeps it behind the nodes.",
+			},
+			"x": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "X position of the rectangle.",
+			},
+			"y": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Y position of the rectangle.",
+			},
+			"width": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Width of the rectangle.",
+			},
+			"height": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Height of the rectangle.",
+			},
+			"drawing_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the rectangle drawing.",
+			},
+			"label_drawing_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the label drawing, if any.",
+			},
+		},
+	}
+}
+
+// nodeGroupBounds returns the position and size of the rectangle surrounding
+// nodeIDs with padding around them.
+func nodeGroupBounds(config *ProviderConfig, projectID string, nodeIDs []string, padding int) (x, y, width, height int, err error) {
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	byID := map[string]map[string]interface{}{}
+	for _, node := range nodes {
+		if id, ok := node["node_id"].(string); ok {
+			byID[id] = node
+		}
+	}
+
+	var minX, minY, maxX, maxY int
+	for i, id := range nodeIDs {
+		node, ok := byID[id]
+		if !ok {
+			return 0, 0, 0, 0, fmt.Errorf("node %s not found in project %s", id, projectID)
+		}
+		nx, ny := jsonInt(node["x"]), jsonInt(node["y"])
+		nw, nh := jsonInt(node["width"]), jsonInt(node["height"])
+		if nw == 0 {
+			nw = defaultNodeSize
+		}
+		if nh == 0 {
+			nh = defaultNodeSize
+		}
+		if i == 0 || nx < minX {
+			minX = nx
+		}
+		if i == 0 || ny < minY {
+			minY = ny
+		}
+		if i == 0 || nx+nw > maxX {
+			maxX = nx + nw
+		}
+		if i == 0 || ny+nh > maxY {
+			maxY = ny + nh
+		}
+	}
+	return minX - padding, minY - padding, maxX - minX + 2*padding, maxY - minY + 2*padding, nil
+}
+
+// nodeGroupCustomizeDiff plans a resize when the nodes moved since the
+// rectangle was drawn.
+func nodeGroupCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.NewValueKnown("node_ids") {
+		return nil
+	}
+	config := meta.(*ProviderConfig)
+	x, y, width, height, err := nodeGroupBounds(config, d.Get("project_id").(string), setToStrings(d.Get("node_ids").(*schema.Set)), d.Get("padding").(int))
+	if err != nil {
+		// Nodes created or replaced in the same apply are not there yet
+		log.Printf("[DEBUG] Not checking the bounds of node group %s: %s", d.Id(), err)
+		return nil
+	}
+	if x == d.Get("x").(int) && y == d.Get("y").(int) && width == d.Get("width").(int) && height == d.Get("height").(int) {
+		return nil
+	}
+	for _, key := range []string{"x", "y", "width", "height"} {
+		if err := d.SetNewComputed(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setToStrings returns the elements of a set of strings.
+func setToStrings(set *schema.Set) []string {
+	var out []string
+	for _, v := range set.List() {
+		out = append(out, v.(string))
+	}
+	return out
+}
+
+// nodeGroupSVG renders the rectangle drawing.
+func nodeGroupSVG(d *schema.ResourceData, width, height int) string {
+	return fmt.Sprintf(
+		`<svg height="%d" width="%d"><rect fill="%s" fill-opacity="1.0" height="%d" stroke="%s" stroke-width="%d" width="%d" /></svg>`,
+		height, width, d.Get("fill_color").(string), height, d.Get("color").(string), d.Get("border_width").(int), width,
+	)
+}
+
+// nodeGroupLabel returns the label drawing placed above the rectangle at x, y.
+func nodeGroupLabel(d *schema.ResourceData, x, y int) Drawing {
+	return Drawing{
+		SVG: textSVG(d.Get("label").(string), "TypeWriter", 10, true, d.Get("color").(string)),
+		X:   x,
+		Y:   y - 25,
+		Z:   d.Get("z").(int) + 1,
+	}
+}
+
+// createDrawing adds a drawing to a project and returns its ID.
+func createDrawing(config *ProviderConfig, projectID string, drawing Drawing) (string, error) {
+	data, err := json.Marshal(drawing)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal drawing data: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings", config.Host, projectID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return "", fmt.Errorf("error creating GNS3 drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var created Drawing
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode drawing response: %s", err)
+	}
+	if created.DrawingID == "" {
+		return "", fmt.Errorf("failed to retrieve drawing_id from GNS3 API response")
+	}
+	return created.DrawingID, nil
+}
+
+// getDrawing returns a drawing of a project, or nil if it does not exist.
+func getDrawing(config *ProviderConfig, projectID, drawingID string) (*Drawing, error) {
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings/%s", config.Host, projectID, drawingID)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error reading drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to read drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var drawing Drawing
+	if err := json.NewDecoder(resp.Body).Decode(&drawing); err != nil {
+		return nil, fmt.Errorf("failed to decode drawing: %s", err)
+	}
+	return &drawing, nil
+}
+
+// updateDrawing changes the given fields of a drawing.
+func updateDrawing(config *ProviderConfig, projectID, drawingID string, updateData map[string]interface{}) error {
+	data, err := json.Marshal(updateData)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update data: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings/%s", config.Host, projectID, drawingID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create update request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error updating drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// deleteDrawing removes a drawing; one that is already gone is not an error.
+func deleteDrawing(config *ProviderConfig, projectID, drawingID string) error {
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings/%s", config.Host, projectID, drawingID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete request for drawing: %s", err)
+	}
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func resourceGns3NodeGroupCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	x, y, width, height, err := nodeGroupBounds(config, projectID, setToStrings(d.Get("node_ids").(*schema.Set)), d.Get("padding").(int))
+	if err != nil {
+		return err
+	}
+
+	drawingID, err := createDrawing(config, projectID, Drawing{
+		SVG: nodeGroupSVG(d, width, height),
+		X:   x,
+		Y:   y,
+		Z:   d.Get("z").(int),
+	})
+	if err != nil {
+		return err
+	}
+	d.SetId(drawingID)
+	d.Set("drawing_id", drawingID)
+
+	if d.Get("label").(string) != "" {
+		labelID, err := createDrawing(config, projectID, nodeGroupLabel(d, x, y))
+		if err != nil {
+			return err
+		}
+		d.Set("label_drawing_id", labelID)
+	}
+
+	return resourceGns3NodeGroupRead(d, meta)
+}
+
+func resourceGns3NodeGroupRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	drawing, err := getDrawing(config, d.Get("project_id").(string), d.Id())
+	if err != nil {
+		return err
+	}
+	if drawing == nil {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("drawing_id", drawing.DrawingID)
+	d.Set("x", drawing.X)
+	d.Set("y", drawing.Y)
+	d.Set("z", drawing.Z)
+	if size := svgSize.FindStringSubmatch(drawing.SVG); size != nil {
+		height, _ := strconv.Atoi(size[1])
+		width, _ := strconv.Atoi(size[2])
+		d.Set("width", width)
+		d.Set("height", height)
+	}
+	return nil
+}
+
+func resourceGns3NodeGroupUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	x, y, width, height, err := nodeGroupBounds(config, projectID, setToStrings(d.Get("node_ids").(*schema.Set)), d.Get("padding").(int))
+	if err != nil {
+		return err
+	}
+
+	if err := updateDrawing(config, projectID, d.Id(), map[string]interface{}{
+		"svg": nodeGroupSVG(d, width, height),
+		"x":   x,
+		"y":   y,
+		"z":   d.Get("z").(int),
+	}); err != nil {
+		return err
+	}
+
+	labelID := d.Get("label_drawing_id").(string)
+	switch {
+	case d.Get("label").(string) == "" && labelID != "":
+		if err := deleteDrawing(config, projectID, labelID); err != nil {
+			return err
+		}
+		d.Set("label_drawing_id", "")
+	case d.Get("label").(string) != "" && labelID == "":
+		labelID, err := createDrawing(config, projectID, nodeGroupLabel(d, x, y))
+		if err != nil {
+			return err
+		}
+		d.Set("label_drawing_id", labelID)
+	case labelID != "":
+		label := nodeGroupLabel(d, x, y)
+		if err := updateDrawing(config, projectID, labelID, map[string]interface{}{
+			"svg": label.SVG,
+			"x":   label.X,
+			"y":   label.Y,
+			"z":   label.Z,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return resourceGns3NodeGroupRead(d, meta)
+}
+
+func resourceGns3NodeGroupDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	if labelID := d.Get("label_drawing_id").(string); labelID != "" {
+		if err := deleteDrawing(config, projectID, labelID); err != nil {
+			return err
+		}
+	}
+	if err := deleteDrawing(config, projectID, d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
@@ -3,18 +3,48 @@ package provider
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Project represents the structure for GNS3 project API requests/responses.
 type Project struct {
 	Name      string `json:"name"`
 	ProjectID string `json:"project_id,omitempty"`
+	Path      string `json:"path,omitempty"`
+
+	SceneWidth      int  `json:"scene_width,omitempty"`
+	SceneHeight     int  `json:"scene_height,omitempty"`
+	GridSize        int  `json:"grid_size,omitempty"`
+	DrawingGridSize int  `json:"drawing_grid_size,omitempty"`
+	Zoom            int  `json:"zoom,omitempty"`
+	ShowGrid        bool `json:"show_grid,omitempty"`
+	SnapToGrid      bool `json:"snap_to_grid,omitempty"`
+}
+
+// projectCanvasAttributes are the project attributes describing how the canvas
+// is drawn. They are sent to the controller under the same names.
+var projectCanvasAttributes = []string{"scene_width", "scene_height", "grid_size", "drawing_grid_size", "zoom", "show_grid", "snap_to_grid"}
+
+// absoluteProjectPath matches POSIX and Windows (drive letter) absolute paths.
+var absoluteProjectPath = regexp.MustCompile(`^(/|[A-Za-z]:[\\/])`)
+
+// validateProjectPath rejects relative paths, which the controller would resolve
+// against its own working directory.
+func validateProjectPath(v interface{}, k string) ([]string, []error) {
+	path := v.(string)
+	if path != "" && !absoluteProjectPath.MatchString(path) {
+		return nil, []error{fmt.Errorf("%q must be an absolute path on the GNS3 server, got %q", k, path)}
+	}
+	return nil, nil
 }
 
 // resourceGns3Project defines the Terraform resource schema for GNS3 projects.
@@ -39,6 +69,79 @@ func resourceGns3Project() *schema.Resource {
 				Computed:    true,
 				Description: "The ID assigned by GNS3 to the project.",
 			},
+			"path": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validateProjectPath,
+				Description:  "Absolute directory on the GNS3 server where the project is stored. Defaults to the server's projects directory.",
+			},
+			"gns3_url": webURLSchema("Web UI link to the project's canvas."),
+			"protect":  protectSchema(),
+			"state": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "opened",
+				ValidateFunc: validation.StringInSlice([]string{"opened", "closed"}, false),
+				Description:  "Whether the project is opened or closed. Closing a project stops its nodes and frees compute resources.",
+			},
+			"readme": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Contents of the project's README.txt, which the GNS3 GUI shows as the project notes. Edits made outside Terraform show up as drift.",
+			},
+			"readme_sha256": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-256 of the README.txt stored in the project.",
+			},
+			"scene_width": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Width of the canvas in pixels. GNS3 defaults to 2000.",
+			},
+			"scene_height": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Height of the canvas in pixels. GNS3 defaults to 1000.",
+			},
+			"grid_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Spacing of the grid nodes snap to, in pixels. GNS3 defaults to 75.",
+			},
+			"drawing_grid_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Spacing of the grid drawings snap to, in pixels. GNS3 defaults to 25.",
+			},
+			"zoom": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Zoom level the project opens with, in percent. GNS3 defaults to 100.",
+			},
+			"show_grid": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+				Description: "Whether the grid is drawn on the canvas.",
+			},
+			"snap_to_grid": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+				Description: "Whether nodes moved in the GUI snap to the grid.",
+			},
 		},
 	}
 }
@@ -49,18 +152,32 @@ func resourceGns3ProjectCreate(d *schema.ResourceData, meta interface{}) error {
 	projectName := d.Get("name").(string)
 
 	// Step 1: Create on controller
-	project := Project{Name: projectName}
+	project := Project{
+		Name:            projectName,
+		Path:            d.Get("path").(string),
+		SceneWidth:      d.Get("scene_width").(int),
+		SceneHeight:     d.Get("scene_height").(int),
+		GridSize:        d.Get("grid_size").(int),
+		DrawingGridSize: d.Get("drawing_grid_size").(int),
+		Zoom:            d.Get("zoom").(int),
+		ShowGrid:        d.Get("show_grid").(bool),
+		SnapToGrid:      d.Get("snap_to_grid").(bool),
+	}
 	projectData, err := json.Marshal(project)
 	if err != nil {
 		return fmt.Errorf("failed to marshal project: %w", err)
 	}
 
-	controllerResp, err := http.Post(fmt.Sprintf("%s/v2/projects", host), "application/json", bytes.NewBuffer(projectData))
+	controllerResp, err := config.Client.Post(fmt.Sprintf("%s/v2/projects", host), "application/json", bytes.NewBuffer(projectData))
 	if err != nil {
 		return fmt.Errorf("controller POST failed: %w", err)
 	}
 	defer controllerResp.Body.Close()
 
+	if controllerResp.StatusCode == http.StatusForbidden && project.Path != "" {
+		body, _ := ioutil.ReadAll(controllerResp.Body)
+		return fmt.Errorf("controller refused custom project path %q (the server must allow paths outside its projects directory): %s", project.Path, body)
+	}
 	if controllerResp.StatusCode != http.StatusCreated {
 		body, _ := ioutil.ReadAll(controllerResp.Body)
 		return fmt.Errorf("controller project create failed: %s", body)
@@ -86,7 +203,7 @@ func resourceGns3ProjectCreate(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("failed to marshal compute payload: %w", err)
 	}
 
-	computeResp, err := http.Post(fmt.Sprintf("%s/v2/compute/projects", host), "application/json", bytes.NewBuffer(computeData))
+	computeResp, err := config.Client.Post(fmt.Sprintf("%s/v2/compute/projects", host), "application/json", bytes.NewBuffer(computeData))
 	if err != nil {
 		return fmt.Errorf("compute POST failed: %w", err)
 	}
@@ -97,25 +214,22 @@ func resourceGns3ProjectCreate(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("compute project create failed: %s", body)
 	}
 
-	// Step 3: Open the project on controller
-	openURL := fmt.Sprintf("%s/v2/projects/%s/open", host, projectID)
-	openReq, err := http.NewRequest("POST", openURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to prepare open project request: %w", err)
+	// Step 3: Open the project on controller, or leave it closed if requested
+	if err := projectAction(config, projectID, "open"); err != nil {
+		return err
 	}
-
-	openResp, err := http.DefaultClient.Do(openReq)
-	if err != nil {
-		return fmt.Errorf("failed to open/sync project on controller: %w", err)
+	if readme := d.Get("readme").(string); readme != "" {
+		if err := writeProjectFile(config, projectID, projectReadmeFile, []byte(readme)); err != nil {
+			return err
+		}
 	}
-	defer openResp.Body.Close()
-
-	if openResp.StatusCode != http.StatusOK && openResp.StatusCode != http.StatusCreated {
-		body, _ := ioutil.ReadAll(openResp.Body)
-		return fmt.Errorf("failed to open/sync project, status: %d, response: %s", openResp.StatusCode, string(body))
+	if d.Get("state").(string) == "closed" {
+		if err := projectAction(config, projectID, "close"); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return resourceGns3ProjectRead(d, meta)
 }
 
 // resourceGns3ProjectRead reads the project state from GNS3.
@@ -129,7 +243,7 @@ func resourceGns3ProjectRead(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	url := fmt.Sprintf("%s/v2/projects/%s", host, projectID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to read project from GNS3: %s", err)
 	}
@@ -156,20 +270,48 @@ func resourceGns3ProjectRead(d *schema.ResourceData, meta interface{}) error {
 
 	d.Set("name", project["name"])
 	d.Set("project_id", project["project_id"])
+	d.Set("path", project["path"])
+	d.Set("gns3_url", projectWebURL(config, projectID))
+	if status, ok := project["status"].(string); ok {
+		d.Set("state", status)
+	}
+	for _, attr := range []string{"scene_width", "scene_height", "grid_size", "drawing_grid_size", "zoom"} {
+		if v, ok := project[attr]; ok {
+			d.Set(attr, jsonInt(v))
+		}
+	}
+	d.Set("show_grid", project["show_grid"])
+	d.Set("snap_to_grid", project["snap_to_grid"])
+
+	if err := readProjectReadme(d, config, projectID); err != nil {
+		return err
+	}
+
+	// Keep the scene size used for canvas warnings in step with the project
+	if width, height := jsonInt(project["scene_width"]), jsonInt(project["scene_height"]); width > 0 && height > 0 {
+		config.Cache.Set("scene/"+projectID, sceneSize{Width: width, Height: height})
+	}
 
 	return nil
 }
 
-// resourceGns3ProjectUpdate updates the project's name.
+// resourceGns3ProjectUpdate updates the project's name, path and canvas settings.
 func resourceGns3ProjectUpdate(d *schema.ResourceData, meta interface{}) error {
 	config := meta.(*ProviderConfig)
 	host := config.Host
 	projectID := d.Id()
 
-	if d.HasChange("name") {
-		newName := d.Get("name").(string)
+	if d.HasChanges(append([]string{"name", "path"}, projectCanvasAttributes...)...) {
 		updateData := map[string]interface{}{
-			"name": newName,
+			"name": d.Get("name").(string),
+		}
+		if d.HasChange("path") {
+			updateData["path"] = d.Get("path").(string)
+		}
+		for _, attr := range projectCanvasAttributes {
+			if d.HasChange(attr) {
+				updateData[attr] = d.Get(attr)
+			}
 		}
 		data, err := json.Marshal(updateData)
 		if err != nil {
@@ -183,13 +325,16 @@ func resourceGns3ProjectUpdate(d *schema.ResourceData, meta interface{}) error {
 		}
 		req.Header.Set("Content-Type", "application/json")
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := config.Client.Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to update project: %s", err)
 		}
 		defer resp.Body.Close()
 
+		if resp.StatusCode == http.StatusForbidden && d.HasChange("path") {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return fmt.Errorf("controller refused to move project to %q: %s", d.Get("path").(string), body)
+		}
 		if resp.StatusCode != http.StatusOK {
 			var errorResponse map[string]interface{}
 			_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
@@ -197,11 +342,115 @@ func resourceGns3ProjectUpdate(d *schema.ResourceData, meta interface{}) error {
 		}
 	}
 
+	if d.HasChange("readme") {
+		if err := writeProjectFile(config, projectID, projectReadmeFile, []byte(d.Get("readme").(string))); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("state") {
+		action := "open"
+		if d.Get("state").(string) == "closed" {
+			action = "close"
+		}
+		if err := projectAction(config, projectID, action); err != nil {
+			return err
+		}
+	}
+
 	return resourceGns3ProjectRead(d, meta)
 }
 
+// projectReadmeFile is the project file the GNS3 GUI shows as the project notes.
+const projectReadmeFile = "README.txt"
+
+// readProjectFile returns a file of the project directory, or nil if it does not exist.
+func readProjectFile(config *ProviderConfig, projectID, path string) ([]byte, error) {
+	url := fmt.Sprintf("%s/v2/projects/%s/files/%s", config.Host, projectID, path)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file %s: %s", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read project file %s, status code: %d, response: %s", path, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// writeProjectFile replaces a file of the project directory.
+func writeProjectFile(config *ProviderConfig, projectID, path string, content []byte) error {
+	url := fmt.Sprintf("%s/v2/projects/%s/files/%s", config.Host, projectID, path)
+	resp, err := config.Client.Post(url, "application/octet-stream", bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to write project file %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to write project file %s, status code: %d, response: %s", path, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// readProjectReadme records the hash of the project's README.txt and, when it no
+// longer matches the readme in state, the contents found, so the next plan
+// restores them. Projects without a managed readme are left alone.
+func readProjectReadme(d *schema.ResourceData, config *ProviderConfig, projectID string) error {
+	readme := d.Get("readme").(string)
+	if readme == "" {
+		d.Set("readme_sha256", "")
+		return nil
+	}
+	content, err := readProjectFile(config, projectID, projectReadmeFile)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	remote := hex.EncodeToString(sum[:])
+	if local := sha256.Sum256([]byte(readme)); hex.EncodeToString(local[:]) != remote {
+		d.Set("readme", string(content))
+	}
+	d.Set("readme_sha256", remote)
+	return nil
+}
+
+// projectAction opens or closes a project on the controller.
+func projectAction(config *ProviderConfig, projectID, action string) error {
+	url := fmt.Sprintf("%s/v2/projects/%s/%s", config.Host, projectID, action)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s project request: %w", action, err)
+	}
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s project on controller: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s project, status: %d, response: %s", action, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 // resourceGns3ProjectDelete deletes the project from GNS3.
 func resourceGns3ProjectDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "project"); err != nil {
+		return err
+	}
+
 	config := meta.(*ProviderConfig)
 	host := config.Host
 	projectID := d.Id()
@@ -211,8 +460,7 @@ func resourceGns3ProjectDelete(d *schema.ResourceData, meta interface{}) error {
 	if err != nil {
 		return fmt.Errorf("failed to create delete request: %s", err)
 	}
-	client := &http.Client{}
-	_, err = client.Do(req)
+	_, err = config.Client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceGns3ProjectPrune deletes the nodes, and optionally links, of a project
+// that Terraform does not manage. Everything it finds is reported at refresh
+// and pruned on the next apply, so the project converges on the configuration.
+func resourceGns3ProjectPrune() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceGns3ProjectPruneCreate,
+		Read:          resourceGns3ProjectPruneRead,
+		Update:        resourceGns3ProjectPruneUpdate,
+		Delete:        resourceGns3ProjectPruneDelete,
+		CustomizeDiff: projectPruneCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project to prune.",
+			},
+			"managed_node_ids": {
+				Type:        schema.TypeSet,
+				Required:    true,
+				Description: "Nodes to keep, usually the IDs of every node resource of the project. Any other node is deleted, unless it is locked on the canvas or is a cloud or NAT node linked only to kept nodes, like the uplink clouds and management NAT nodes created by node resources.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"prune_links": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Also delete links not listed in managed_link_ids, except the links of kept cloud and NAT helper nodes. Otherwise only the links of pruned nodes go away with them.",
+			},
+			"managed_link_ids": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "Links to keep when prune_links is set.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"unmanaged_node_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Nodes found at refresh that the next apply deletes.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"unmanaged_link_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Links found at refresh that the next apply deletes.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// unmanagedObjects returns the nodes and links of a project that the managed
+// sets do not cover. Locked nodes and the helper nodes of managed nodes, with
+// their links, are never reported.
+func unmanagedObjects(config *ProviderConfig, projectID string, nodes, links *schema.Set) ([]string, []string, error) {
+	projectNodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	projectLinks, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	helpers := helperNodes(projectNodes, projectLinks, nodes)
+
+	nodeIDs := []string{}
+	for _, node := range projectNodes {
+		id, _ := node["node_id"].(string)
+		if locked, _ := node["locked"].(bool); locked || nodes.Contains(id) || helpers[id] {
+			continue
+		}
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+
+	linkIDs := []string{}
+	if links != nil {
+		for _, link := range projectLinks {
+			helperLink := false
+			for _, end := range link.Nodes {
+				helperLink = helperLink || helpers[end.NodeID]
+			}
+			if !helperLink && !links.Contains(link.LinkID) {
+				linkIDs = append(linkIDs, link.LinkID)
+			}
+		}
+		sort.Strings(linkIDs)
+	}
+	return nodeIDs, linkIDs, nil
+}
+
+// helperNodes returns the cloud and NAT nodes linked only to managed nodes, such
+// as the uplink clouds and management NAT nodes that node resources create. Those
+// are tracked in the state of the node they serve, not in managed_node_ids.
+func helperNodes(projectNodes []map[string]interface{}, projectLinks []Link, managed *schema.Set) map[string]bool {
+	candidates := make(map[string]bool)
+	for _, node := range projectNodes {
+		if t, _ := node["node_type"].(string); t == "cloud" || t == "nat" {
+			id, _ := node["node_id"].(string)
+			candidates[id] = false
+		}
+	}
+	rejected := make(map[string]bool)
+	for _, link := range projectLinks {
+		for _, end := range link.Nodes {
+			if _, ok := candidates[end.NodeID]; !ok {
+				continue
+			}
+			for _, other := range link.Nodes {
+				if other.NodeID != end.NodeID && !managed.Contains(other.NodeID) {
+					rejected[end.NodeID] = true
+				}
+			}
+			candidates[end.NodeID] = true
+		}
+	}
+
+	helpers := make(map[string]bool)
+	for id, linked := range candidates {
+		if linked && !rejected[id] {
+			helpers[id] = true
+		}
+	}
+	return helpers
+}
+
+// managedLinks returns the managed_link_ids set, or nil when links are not pruned.
+func managedLinks(d *schema.ResourceData) *schema.Set {
+	if !d.Get("prune_links").(bool) {
+		return nil
+	}
+	return d.Get("managed_link_ids").(*schema.Set)
+}
+
+// projectPruneCustomizeDiff plans an update whenever refresh found unmanaged objects.
+func projectPruneCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	if d.HasChanges("managed_node_ids", "managed_link_ids", "prune_links") ||
+		len(d.Get("unmanaged_node_ids").([]interface{})) > 0 ||
+		len(d.Get("unmanaged_link_ids").([]interface{})) > 0 {
+		if err := d.SetNewComputed("unmanaged_node_ids"); err != nil {
+			return err
+		}
+		return d.SetNewComputed("unmanaged_link_ids")
+	}
+	return nil
+}
+
+// pruneProject deletes the unmanaged links, then the unmanaged nodes.
+func pruneProject(d *schema.ResourceData, config *ProviderConfig) error {
+	projectID := d.Get("project_id").(string)
+	nodeIDs, linkIDs, err := unmanagedObjects(config, projectID, d.Get("managed_node_ids").(*schema.Set), managedLinks(d))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range linkIDs {
+		log.Printf("[INFO] Pruning unmanaged GNS3 link %s from project %s", id, projectID)
+		if err := deleteLink(config, projectID, id); err != nil {
+			return err
+		}
+	}
+	for _, id := range nodeIDs {
+		log.Printf("[INFO] Pruning unmanaged GNS3 node %s from project %s", id, projectID)
+		if err := deleteNode(config, projectID, id); err != nil {
+			return fmt.Errorf("failed to prune node %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
+func resourceGns3ProjectPruneCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := pruneProject(d, meta.(*ProviderConfig)); err != nil {
+		return err
+	}
+	d.SetId(d.Get("project_id").(string) + "-prune")
+	return resourceGns3ProjectPruneRead(d, meta)
+}
+
+func resourceGns3ProjectPruneRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	nodeIDs, linkIDs, err := unmanagedObjects(config, d.Get("project_id").(string), d.Get("managed_node_ids").(*schema.Set), managedLinks(d))
+	if err != nil {
+		return err
+	}
+	d.Set("unmanaged_node_ids", nodeIDs)
+	d.Set("unmanaged_link_ids", linkIDs)
+	return nil
+}
+
+func resourceGns3ProjectPruneUpdate(d *schema.ResourceData, meta interface{}) error {
+	if err := pruneProject(d, meta.(*ProviderConfig)); err != nil {
+		return err
+	}
+	return resourceGns3ProjectPruneRead(d, meta)
+}
+
+// resourceGns3ProjectPruneDelete only forgets the resource; nothing is restored.
+func resourceGns3ProjectPruneDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
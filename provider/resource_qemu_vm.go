@@ -9,23 +9,27 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // ResourceGns3Qemu defines a new Terraform resource for creating a QEMU VM instance in GNS3.
 func resourceGns3Qemu() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceGns3QemuCreate,
-		Read:   resourceGns3QemuRead,
-		Update: resourceGns3QemuUpdate,
-		Delete: resourceGns3QemuDelete,
+		CreateContext: withCanvasCheck(resourceGns3QemuCreate),
+		Read:          resourceGns3QemuRead,
+		UpdateContext: withCanvasCheck(resourceGns3QemuUpdate),
+		Delete:        resourceGns3QemuDelete,
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceQemuImporter, // use custom importer
 		},
+		CustomizeDiff: customdiff.All(qemuSMBIOSCustomizeDiff, qemuDiskCustomizeDiff, reloadOnChangeCustomizeDiff(resourceGns3Qemu)),
 		Schema: map[string]*schema.Schema{
 			"project_id": {
 				Type:        schema.TypeString,
 				Required:    true,
+				ForceNew:    true,
 				Description: "The UUID of the GNS3 project",
 			},
 			"name": {
@@ -45,16 +49,29 @@ func resourceGns3Qemu() *schema.Resource {
 				Default:     1,
 				Description: "Number of network adapters",
 			},
-			"bios_image": {
-				Type:        schema.TypeString,
+			"management_network": {
+				Type:        schema.TypeBool,
 				Optional:    true,
-				Description: "Path to the QEMU BIOS image",
+				Default:     false,
+				Description: "Append a management adapter, after the configured adapters, linked to a NAT node managed by the provider for out-of-band access.",
 			},
-			"cdrom_image": {
+			"management_nat_node_id": {
 				Type:        schema.TypeString,
-				Optional:    true,
-				Description: "Path to the QEMU CDROM image",
+				Computed:    true,
+				Description: "ID of the NAT node created for the management network.",
+			},
+			"management_link_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the link between the management adapter and the NAT node.",
+			},
+			"management_mac_address": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "MAC address of the management adapter, e.g. for a DHCP reservation.",
 			},
+			"bios_image":  imagePathSchema("Path to the QEMU BIOS image."),
+			"cdrom_image": imagePathSchema("Path to the QEMU CDROM image."),
 			"console": {
 				Type:        schema.TypeInt,
 				Optional:    true,
@@ -84,9 +101,51 @@ func resourceGns3Qemu() *schema.Resource {
 				Description: "Explicit MAC address to assign to the VM's primary network interface",
 			},
 			"options": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Description:      "Additional QEMU options (e.g. -smbios to set serial number). Duplicate flags are merged deterministically.",
+				DiffSuppressFunc: qemuOptionsDiffSuppress,
+				ValidateDiagFunc: validateQemuOptions,
+			},
+			"options_list": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Additional QEMU options as a list, e.g. one flag and its value per entry. Entries are appended to options in list order, so modules can concat() their own; duplicates are merged like in options.",
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					DiffSuppressFunc: qemuOptionsDiffSuppress,
+					ValidateDiagFunc: validateQemuOptions,
+				},
+			},
+			"sensitive_options": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Additional QEMU options containing secrets (e.g. passwords in -fw_cfg). Appended to options and hidden from plan output.",
+			},
+			"serial_number": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Additional QEMU options (e.g. -smbios to set serial number)",
+				Description: "System serial number, passed to QEMU as -smbios type=1,serial=...",
+			},
+			"asset_tag": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Chassis asset tag, passed to QEMU as -smbios type=3,asset=...",
+			},
+			"uuid": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsUUID,
+				Description:  "System UUID, passed to QEMU as -uuid.",
+			},
+			"node_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsUUID,
+				Description:  "UUID to create the node with, for reproducible topologies and cross-referencing with external systems. Assigned by the controller when unset. It must be unique in the project.",
 			},
 			"start_vm": {
 				Type:        schema.TypeBool,
@@ -97,12 +156,39 @@ func resourceGns3Qemu() *schema.Resource {
 			"platform": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Platform architecture for QEMU node (e.g. x86_64, aarch64). Required to determine QEMU binary.",
+				ForceNew:    true,
+				Description: "Platform architecture for QEMU node (e.g. x86_64, aarch64). Required to determine QEMU binary. Changing it recreates the node.",
 			},
-			"hda_disk_image": {
-				Type:        schema.TypeString,
+			"hda_disk_image": imagePathSchema("Path to the HDA (bootable) disk image file for the QEMU node."),
+			"hda_disk_interface": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "virtio",
+				ValidateFunc: validation.StringInSlice(qemuDiskInterfaces, false),
+				Description:  "Bus the HDA disk is attached to: ide, sata, nvme, scsi, sd, mtd, floppy, pflash, virtio or none. Images without virtio drivers need ide or sata.",
+			},
+			"hda_disk_size_increase_mb": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Grow the HDA disk by this many MB over the size of its image, instead of resizing it with qemu-img by hand. Raising it later grows the disk by the difference while the node is stopped; it cannot go down. The guest still has to grow its partitions.",
+			},
+			"install_mode": qemuInstallSchema(),
+			"replicate_network_connection_state": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Whether the guest sees a disconnected (suspended) link as its interface going down.",
+			},
+			"disconnected_adapters": {
+				Type:        schema.TypeSet,
 				Optional:    true,
-				Description: "Path to the HDA (bootable) disk image file for the QEMU node",
+				Description: "Adapters whose cable is disconnected: the links attached to them are suspended, and the links of adapters removed from the list are reconnected. Links of adapters never listed are left alone, so the node at their other end can manage them. Use it to simulate interface flaps.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
 			},
 			// NEW: optional canvas coordinates
 			"x": {
@@ -115,17 +201,35 @@ func resourceGns3Qemu() *schema.Resource {
 				Optional:    true,
 				Description: "Y coordinate of the node on the GNS3 canvas",
 			},
+			"auto_offset":          autoOffsetSchema(),
+			"uplink":               uplinkSchema(),
+			"ports":                nodePortsSchema(),
+			"gns3_url":             webURLSchema("Web UI link to the node's console."),
+			"status":               nodeStatusSchema(),
+			"adopt_existing":       adoptExistingSchema(),
+			"protect":              protectSchema(),
+			"reload_on_change":     reloadOnChangeSchema(),
+			"stop_before_update":   stopBeforeUpdateSchema(),
+			"restart_after_update": restartAfterUpdateSchema(),
+			"console_auto_start":   consoleAutoStartSchema(),
 		},
 	}
 }
 
+// qemuRestartAttributes are the settings QEMU only reads when the VM boots.
+var qemuRestartAttributes = []string{
+	"adapter_type", "adapters", "management_network", "bios_image", "console", "console_type", "cpus", "ram",
+	"mac_address", "options", "options_list", "sensitive_options", "serial_number",
+	"asset_tag", "uuid", "hda_disk_image", "hda_disk_interface", "hda_disk_size_increase_mb",
+}
+
 func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 	config := meta.(*ProviderConfig)
 	projectID := d.Get("project_id").(string)
 
 	name := d.Get("name").(string)
 	adapterType := d.Get("adapter_type").(string)
-	adapters := d.Get("adapters").(int)
+	adapters := qemuAdapterCount(d)
 	biosImage := d.Get("bios_image").(string)
 	cdromImage, _ := d.GetOk("cdrom_image")
 	consoleVal, consoleOk := d.GetOk("console")
@@ -134,19 +238,37 @@ func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 	ram := d.Get("ram").(int)
 	platform := d.Get("platform").(string)
 
+	adopted, err := adoptExistingNode(d, config, projectID, name, "qemu")
+	if err != nil {
+		return err
+	}
+	if adopted {
+		if want := d.Get("node_id").(string); want != "" && want != d.Id() {
+			return fmt.Errorf("node %q adopted by adopt_existing has ID %s, not the configured node_id %s", name, d.Id(), want)
+		}
+		return resourceGns3QemuUpdate(d, meta)
+	}
+
 	properties := map[string]interface{}{
 		"adapter_type": adapterType,
 		"adapters":     adapters,
-		"bios_image":   biosImage,
+		"bios_image":   normalizeImagePath(biosImage),
 		"cdrom_image":  "",
 		"console_type": consoleType,
 		"ram":          ram,
 		"cpus":         cpus,
 		"platform":     platform,
+
+		"replicate_network_connection_state": d.Get("replicate_network_connection_state").(bool),
 	}
 
 	if cdromImage != nil {
-		properties["cdrom_image"] = cdromImage.(string)
+		properties["cdrom_image"] = normalizeImagePath(cdromImage)
+	}
+	install := qemuInstall(d)
+	if install != nil {
+		properties["cdrom_image"] = normalizeImagePath(install["cdrom_image"])
+		properties["boot_priority"] = "d"
 	}
 	if consoleOk {
 		properties["console"] = consoleVal.(int)
@@ -154,12 +276,15 @@ func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 	if v, ok := d.GetOk("mac_address"); ok {
 		properties["mac_address"] = v.(string)
 	}
-	if v, ok := d.GetOk("options"); ok {
-		properties["options"] = v.(string)
+	if options := qemuOptionsValue(d); options != "" {
+		properties["options"] = options
 	}
 	if v, ok := d.GetOk("hda_disk_image"); ok {
-		properties["hda_disk_image"] = v.(string)
-		properties["hda_disk_interface"] = "virtio"
+		properties["hda_disk_image"] = normalizeImagePath(v)
+		properties["hda_disk_interface"] = d.Get("hda_disk_interface").(string)
+	}
+	if usage := usageWithLabels(config, ""); usage != "" {
+		properties["usage"] = usage
 	}
 
 	// Controller-level API
@@ -169,6 +294,12 @@ func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 		"compute_id": "local", // adjust if needed
 		"properties": properties,
 	}
+	if v, ok := d.GetOk("node_id"); ok {
+		payload["node_id"] = v.(string)
+	}
+	if v, ok := d.GetOkExists("console_auto_start"); ok {
+		payload["console_auto_start"] = v.(bool)
+	}
 
 	// include x/y if explicitly set (even if zero)
 	if xv, ok := d.GetOkExists("x"); ok {
@@ -177,6 +308,13 @@ func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 	if yv, ok := d.GetOkExists("y"); ok {
 		payload["y"] = yv.(int)
 	}
+	if offset := autoOffset(d); offset != nil {
+		x, y, err := autoOffsetPosition(config, projectID, name, d.Get("x").(int), d.Get("y").(int), offset)
+		if err != nil {
+			return err
+		}
+		payload["x"], payload["y"] = x, y
+	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -184,7 +322,7 @@ func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes", config.Host, projectID)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonPayload))
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return fmt.Errorf("failed to create QEMU node via controller: %s", err)
 	}
@@ -206,21 +344,27 @@ func resourceGns3QemuCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 	d.SetId(nodeID)
 
-	// Start VM if requested
-	if d.Get("start_vm").(bool) {
-		startURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/start", config.Host, projectID, nodeID)
-		req, err := http.NewRequest("POST", startURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create start request: %s", err)
-		}
-		startResp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to start QEMU node: %s", err)
+	if err := syncUplink(d, config, projectID, "local", nodeID); err != nil {
+		return err
+	}
+	if err := attachManagementNetwork(d, config, projectID, nodeID); err != nil {
+		return err
+	}
+	if err := syncDisconnectedAdapters(d, config, projectID, nodeID); err != nil {
+		return err
+	}
+	if err := growQemuDisk(d, config, projectID, nodeID, false); err != nil {
+		return err
+	}
+
+	// Install the OS, or start VM if requested
+	if install != nil {
+		if err := runQemuInstall(d, config, projectID, nodeID, install); err != nil {
+			return err
 		}
-		defer startResp.Body.Close()
-		if startResp.StatusCode != http.StatusOK {
-			body, _ := ioutil.ReadAll(startResp.Body)
-			return fmt.Errorf("failed to start node, status: %d, response: %s", startResp.StatusCode, string(body))
+	} else if startRequested(d, config, "start_vm") {
+		if err := nodeAction(config, projectID, nodeID, "start"); err != nil {
+			return err
 		}
 	}
 
@@ -234,7 +378,7 @@ func resourceGns3QemuRead(d *schema.ResourceData, meta interface{}) error {
 
 	// Use the controller's project/node endpoint, not the compute API path
 	apiURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", config.Host, projectID, nodeID)
-	resp, err := http.Get(apiURL)
+	resp, err := config.Client.Get(apiURL)
 	if err != nil {
 		return fmt.Errorf("failed to read QEMU node: %s", err)
 	}
@@ -254,8 +398,39 @@ func resourceGns3QemuRead(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	d.Set("name", node["name"])
+	d.Set("node_id", node["node_id"])
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	d.Set("status", node["status"])
+	d.Set("console_auto_start", node["console_auto_start"] == true)
+	d.Set("management_mac_address", managementMACAddress(d, node))
+	if disconnectedAdaptersManaged(d) {
+		disconnected, err := disconnectedAdapters(config, projectID, nodeID)
+		if err != nil {
+			return err
+		}
+		d.Set("disconnected_adapters", disconnected)
+	}
+
+	// The interface only matters, and is only compared, when a disk is attached
+	if props, ok := node["properties"].(map[string]interface{}); ok {
+		if replicate, ok := props["replicate_network_connection_state"].(bool); ok {
+			d.Set("replicate_network_connection_state", replicate)
+		}
+		if iface, ok := props["hda_disk_interface"].(string); ok && iface != "" && props["hda_disk_image"] != "" && props["hda_disk_image"] != nil {
+			d.Set("hda_disk_interface", iface)
+		}
+	}
 
-	// hydrate x/y if present
+	// hydrate x/y if present; with auto_offset they keep the configured grid origin
+	if autoOffset(d) != nil {
+		if err := setAutoOffsetPosition(d, node); err != nil {
+			return fmt.Errorf("failed to set auto_offset: %s", err)
+		}
+		return nil
+	}
 	if xv, ok := node["x"]; ok {
 		switch t := xv.(type) {
 		case float64:
@@ -281,29 +456,47 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
+	// Cables are toggled on the links, without stopping the VM
+	if d.HasChange("disconnected_adapters") {
+		if err := syncDisconnectedAdapters(d, config, projectID, nodeID); err != nil {
+			return err
+		}
+	}
+
 	// If nothing changed, just refresh state
 	if !(d.HasChange("name") ||
 		d.HasChange("adapter_type") ||
 		d.HasChange("adapters") ||
+		d.HasChange("management_network") ||
 		d.HasChange("bios_image") ||
 		d.HasChange("cdrom_image") ||
 		d.HasChange("console") ||
 		d.HasChange("console_type") ||
+		d.HasChange("console_auto_start") ||
 		d.HasChange("cpus") ||
 		d.HasChange("ram") ||
 		d.HasChange("mac_address") ||
 		d.HasChange("options") ||
-		d.HasChange("platform") ||
+		d.HasChange("options_list") ||
+		d.HasChange("sensitive_options") ||
+		d.HasChange("serial_number") ||
+		d.HasChange("asset_tag") ||
+		d.HasChange("uuid") ||
 		d.HasChange("hda_disk_image") ||
+		d.HasChange("hda_disk_interface") ||
+		d.HasChange("hda_disk_size_increase_mb") ||
+		d.HasChange("replicate_network_connection_state") ||
 		d.HasChange("start_vm") ||
 		d.HasChange("x") ||
-		d.HasChange("y")) {
+		d.HasChange("y") ||
+		d.HasChange("auto_offset") ||
+		d.HasChange("uplink")) {
 		return resourceGns3QemuRead(d, meta)
 	}
 
 	// 1) GET live node to merge properties & check status
 	getURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", config.Host, projectID, nodeID)
-	resp, err := http.Get(getURL)
+	resp, err := config.Client.Get(getURL)
 	if err != nil {
 		return fmt.Errorf("failed to read QEMU node (pre-update): %s", err)
 	}
@@ -330,39 +523,29 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 		props = p
 	}
 
-	// 2) Stop if running (some props require stop)
-	wasRunning := false
-	if s, ok := node["status"].(string); ok && s == "started" {
-		wasRunning = true
-		stopURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/stop", config.Host, projectID, nodeID)
-		req, err := http.NewRequest("POST", stopURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create stop request: %s", err)
-		}
-		stopResp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to stop QEMU node: %s", err)
-		}
-		defer stopResp.Body.Close()
-		if stopResp.StatusCode != http.StatusOK && stopResp.StatusCode != http.StatusConflict {
-			body, _ := ioutil.ReadAll(stopResp.Body)
-			return fmt.Errorf("failed to stop node, status: %d, response: %s", stopResp.StatusCode, string(body))
-		}
+	// 2) Stop if running and a setting only read at boot changes
+	status, _ := node["status"].(string)
+	wasRunning, err := stopForUpdate(d, config, projectID, nodeID, status, qemuRestartAttributes...)
+	if err != nil {
+		return err
 	}
 
 	// 3) Overlay changed fields into properties (top-level handled separately)
 	if d.HasChange("adapter_type") {
 		props["adapter_type"] = d.Get("adapter_type").(string)
 	}
-	if d.HasChange("adapters") {
-		props["adapters"] = d.Get("adapters").(int)
+	if d.HasChanges("adapters", "management_network") {
+		if err := dropManagementNetwork(d, config, projectID); err != nil {
+			return err
+		}
+		props["adapters"] = qemuAdapterCount(d)
 	}
 	if d.HasChange("bios_image") {
-		props["bios_image"] = d.Get("bios_image").(string)
+		props["bios_image"] = normalizeImagePath(d.Get("bios_image"))
 	}
 	if d.HasChange("cdrom_image") {
 		if v, ok := d.GetOk("cdrom_image"); ok {
-			props["cdrom_image"] = v.(string)
+			props["cdrom_image"] = normalizeImagePath(v)
 		} else {
 			delete(props, "cdrom_image")
 		}
@@ -390,20 +573,20 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 			delete(props, "mac_address")
 		}
 	}
-	if d.HasChange("options") {
-		if v, ok := d.GetOk("options"); ok {
-			props["options"] = v.(string)
+	if d.HasChanges("options", "options_list", "sensitive_options", "serial_number", "asset_tag", "uuid") {
+		if options := qemuOptionsValue(d); options != "" {
+			props["options"] = options
 		} else {
 			delete(props, "options")
 		}
 	}
-	if d.HasChange("platform") {
-		props["platform"] = d.Get("platform").(string)
+	if d.HasChange("replicate_network_connection_state") {
+		props["replicate_network_connection_state"] = d.Get("replicate_network_connection_state").(bool)
 	}
-	if d.HasChange("hda_disk_image") {
+	if d.HasChanges("hda_disk_image", "hda_disk_interface") {
 		if v, ok := d.GetOk("hda_disk_image"); ok {
-			props["hda_disk_image"] = v.(string)
-			props["hda_disk_interface"] = "virtio"
+			props["hda_disk_image"] = normalizeImagePath(v)
+			props["hda_disk_interface"] = d.Get("hda_disk_interface").(string)
 		} else {
 			delete(props, "hda_disk_image")
 			delete(props, "hda_disk_interface")
@@ -417,6 +600,9 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 	if d.HasChange("name") {
 		putPayload["name"] = d.Get("name").(string)
 	}
+	if d.HasChange("console_auto_start") {
+		putPayload["console_auto_start"] = d.Get("console_auto_start").(bool)
+	}
 	if d.HasChange("x") {
 		if xv, ok := d.GetOkExists("x"); ok {
 			putPayload["x"] = xv.(int)
@@ -428,6 +614,14 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 		}
 	}
 
+	if offset := autoOffset(d); offset != nil && d.HasChanges("x", "y", "auto_offset") {
+		x, y, err := autoOffsetPosition(config, projectID, d.Get("name").(string), d.Get("x").(int), d.Get("y").(int), offset)
+		if err != nil {
+			return err
+		}
+		putPayload["x"], putPayload["y"] = x, y
+	}
+
 	// 5) PUT update
 	data, err := json.Marshal(putPayload)
 	if err != nil {
@@ -440,7 +634,7 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	putResp, err := http.DefaultClient.Do(req)
+	putResp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to update QEMU node: %s", err)
 	}
@@ -450,51 +644,122 @@ func resourceGns3QemuUpdate(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("update QEMU node failed, status: %d, response: %s", putResp.StatusCode, string(body))
 	}
 
-	// 6) Start again if it was running, or if desired state requests it
-	if wasRunning || d.Get("start_vm").(bool) {
-		startURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/start", config.Host, projectID, nodeID)
-		req, err := http.NewRequest("POST", startURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create start request: %s", err)
-		}
-		startResp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to start QEMU node: %s", err)
+	// 6) Grow the disk while the node is stopped, then start again if it was
+	// stopped above, or if start_vm requests it
+	if err := growQemuDisk(d, config, projectID, nodeID, status == "started" && !wasRunning); err != nil {
+		return err
+	}
+	if err := restartAfterUpdate(d, config, projectID, nodeID, wasRunning); err != nil {
+		return err
+	}
+	if !wasRunning && status != "started" && startRequested(d, config, "start_vm") {
+		if err := nodeAction(config, projectID, nodeID, "start"); err != nil {
+			return err
 		}
-		defer startResp.Body.Close()
-		if startResp.StatusCode != http.StatusOK {
-			body, _ := ioutil.ReadAll(startResp.Body)
-			return fmt.Errorf("failed to start node, status: %d, response: %s", startResp.StatusCode, string(body))
+	}
+
+	if err := syncUplink(d, config, projectID, "local", nodeID); err != nil {
+		return err
+	}
+	if err := attachManagementNetwork(d, config, projectID, nodeID); err != nil {
+		return err
+	}
+
+	// 7) Reload if requested; a node that was restarted above already runs the new settings
+	if !wasRunning {
+		if err := reloadNodeIfChanged(d, config, projectID, nodeID); err != nil {
+			return err
 		}
 	}
 
-	// 7) Re-read to sync state
+	// 8) Re-read to sync state
 	return resourceGns3QemuRead(d, meta)
 }
 
+// disconnectedAdapters returns the adapters of a node attached to suspended links.
+func disconnectedAdapters(config *ProviderConfig, projectID, nodeID string) ([]int, error) {
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return nil, err
+	}
+	var adapters []int
+	for _, link := range links {
+		for _, end := range link.Nodes {
+			if end.NodeID == nodeID && link.Suspend {
+				adapters = append(adapters, end.AdapterNumber)
+			}
+		}
+	}
+	return adapters, nil
+}
+
+// disconnectedAdaptersManaged reports whether disconnected_adapters is read back.
+// A link is shared by two nodes, so a node that leaves the attribute out of its
+// configuration must not claim the links another node suspended. Refreshes carry
+// no configuration; they read it back while the state lists any adapter.
+func disconnectedAdaptersManaged(d *schema.ResourceData) bool {
+	if raw := d.GetRawConfig(); !raw.IsNull() && raw.IsKnown() && raw.Type().IsObjectType() &&
+		raw.Type().HasAttribute("disconnected_adapters") {
+		return !raw.GetAttr("disconnected_adapters").IsNull()
+	}
+	return d.Get("disconnected_adapters").(*schema.Set).Len() > 0
+}
+
+// syncDisconnectedAdapters suspends the links attached to the adapters listed in
+// disconnected_adapters and resumes the links of adapters that were listed
+// before. Links of other adapters are left alone, since the node at their other
+// end may have suspended them. Links created after the node are handled on the
+// next apply.
+func syncDisconnectedAdapters(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string) error {
+	o, n := d.GetChange("disconnected_adapters")
+	listed, disconnected := o.(*schema.Set), n.(*schema.Set)
+	links, err := listProjectLinks(config, projectID)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		for _, end := range link.Nodes {
+			if end.NodeID != nodeID {
+				continue
+			}
+			suspend := disconnected.Contains(end.AdapterNumber)
+			if !suspend && !listed.Contains(end.AdapterNumber) {
+				continue
+			}
+			if suspend != link.Suspend {
+				if err := suspendLink(config, projectID, link.LinkID, suspend); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func resourceGns3QemuDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+
 	config := meta.(*ProviderConfig)
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
-	// Use the controller's project/node endpoint for delete as well
-	apiURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", config.Host, projectID, nodeID)
-	req, err := http.NewRequest("DELETE", apiURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create DELETE request: %s", err)
+	if blocks := d.Get("uplink").([]interface{}); len(blocks) > 0 && blocks[0] != nil {
+		if err := deleteUplink(config, projectID, blocks[0].(map[string]interface{})); err != nil {
+			return err
+		}
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to delete QEMU node: %s", err)
+	if natID := d.Get("management_nat_node_id").(string); natID != "" {
+		if err := deleteNode(config, projectID, natID); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("failed to delete QEMU node, status: %d, response: %s", resp.StatusCode, body)
+	if err := deleteNode(config, projectID, nodeID); err != nil {
+		return err
 	}
+
 	d.SetId("")
 	return nil
 }
@@ -0,0 +1,281 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Snapshot represents a GNS3 project snapshot API request/response.
+type Snapshot struct {
+	SnapshotID string `json:"snapshot_id,omitempty"`
+	ProjectID  string `json:"project_id,omitempty"`
+	Name       string `json:"name"`
+	CreatedAt  int64  `json:"created_at,omitempty"`
+}
+
+// snapshotMetadata matches the retention metadata appended to snapshot names,
+// e.g. "nightly [keep_last=7,created_by=ci]".
+var snapshotMetadata = regexp.MustCompile(`^(.*) \[((?:[a-z_]+=[^,\]]*,?)+)\]$`)
+
+// snapshotName returns the name a snapshot is stored under, with its retention
+// metadata appended when there is any.
+func snapshotName(name string, keepLast int, createdBy string) string {
+	var meta []string
+	if keepLast > 0 {
+		meta = append(meta, fmt.Sprintf("keep_last=%d", keepLast))
+	}
+	if createdBy != "" {
+		meta = append(meta, "created_by="+createdBy)
+	}
+	if len(meta) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s [%s]", name, strings.Join(meta, ","))
+}
+
+// parseSnapshotName splits a stored snapshot name into its name and retention
+// metadata. Names without metadata are returned unchanged.
+func parseSnapshotName(stored string) (name string, keepLast int, createdBy string) {
+	match := snapshotMetadata.FindStringSubmatch(stored)
+	if match == nil {
+		return stored, 0, ""
+	}
+	for _, pair := range strings.Split(match[2], ",") {
+		k, v, _ := strings.Cut(pair, "=")
+		switch k {
+		case "keep_last":
+			keepLast, _ = strconv.Atoi(v)
+		case "created_by":
+			createdBy = v
+		}
+	}
+	return match[1], keepLast, createdBy
+}
+
+// resourceGns3Snapshot manages a project snapshot. GNS3 snapshots the whole project,
+// including the disks of every node, since the controller has no per-node snapshot API.
+func resourceGns3Snapshot() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceGns3SnapshotCreate,
+		Read:   resourceGns3SnapshotRead,
+		Update: resourceGns3SnapshotUpdate,
+		Delete: resourceGns3SnapshotDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceGns3SnapshotImporter,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project to snapshot.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the snapshot.",
+			},
+			"keep_last": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Retention hint: only the newest keep_last snapshots with this name (and created_by) are kept. Stored in the snapshot name and reported by gns3_expired_snapshots.",
+			},
+			"created_by": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringDoesNotContainAny(",]"),
+				Description:  "Who or what took the snapshot, e.g. a pipeline name. Stored in the snapshot name.",
+			},
+			"snapshot_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The name the snapshot is stored under in GNS3, including retention metadata.",
+			},
+			"restore_trigger": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Changing this value restores the project (all node disks included) to this snapshot on the next apply.",
+			},
+			"snapshot_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The snapshot's ID assigned by GNS3.",
+			},
+			"created_at": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Creation time of the snapshot as a Unix timestamp.",
+			},
+		},
+	}
+}
+
+func resourceGns3SnapshotCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	name := snapshotName(d.Get("name").(string), d.Get("keep_last").(int), d.Get("created_by").(string))
+	data, err := json.Marshal(Snapshot{Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot data: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/snapshots", host, projectID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("error creating GNS3 snapshot: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to create snapshot, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var created Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return fmt.Errorf("failed to decode snapshot response: %s", err)
+	}
+	if created.SnapshotID == "" {
+		return fmt.Errorf("failed to retrieve snapshot_id from GNS3 API response")
+	}
+
+	d.SetId(created.SnapshotID)
+	return resourceGns3SnapshotRead(d, meta)
+}
+
+// listSnapshots returns all snapshots of a project.
+func listSnapshots(config *ProviderConfig, projectID string) ([]Snapshot, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s/snapshots", config.Host, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list snapshots, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var snapshots []Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshots); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshots: %s", err)
+	}
+	return snapshots, nil
+}
+
+func resourceGns3SnapshotRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	projectID := d.Get("project_id").(string)
+
+	snapshots, err := listSnapshots(config, projectID)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snapshots {
+		if s.SnapshotID == d.Id() {
+			name, keepLast, createdBy := parseSnapshotName(s.Name)
+			d.Set("snapshot_id", s.SnapshotID)
+			d.Set("name", name)
+			d.Set("keep_last", keepLast)
+			d.Set("created_by", createdBy)
+			d.Set("snapshot_name", s.Name)
+			d.Set("created_at", s.CreatedAt)
+			return nil
+		}
+	}
+
+	// Snapshot no longer exists
+	d.SetId("")
+	return nil
+}
+
+func resourceGns3SnapshotUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	if d.HasChange("restore_trigger") {
+		url := fmt.Sprintf("%s/v2/projects/%s/snapshots/%s/restore", host, projectID, d.Id())
+		resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer([]byte("{}")))
+		if err != nil {
+			return fmt.Errorf("failed to restore snapshot: %s", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return fmt.Errorf("failed to restore snapshot, status code: %d, response: %s", resp.StatusCode, string(body))
+		}
+	}
+
+	return resourceGns3SnapshotRead(d, meta)
+}
+
+func resourceGns3SnapshotDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	url := fmt.Sprintf("%s/v2/projects/%s/snapshots/%s", host, projectID, d.Id())
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete request for snapshot: %s", err)
+	}
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete snapshot, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceGns3SnapshotImporter(
+	ctx context.Context,
+	d *schema.ResourceData,
+	meta interface{},
+) ([]*schema.ResourceData, error) {
+	raw := d.Id()
+	var projectID, snapshotID string
+
+	if parts := strings.SplitN(raw, "/", 2); len(parts) == 2 {
+		projectID = parts[0]
+		snapshotID = parts[1]
+	} else {
+		return nil, fmt.Errorf("invalid import ID %q — expected format <project_id>/<snapshot_id>", raw)
+	}
+
+	if err := d.Set("project_id", projectID); err != nil {
+		return nil, err
+	}
+	d.SetId(snapshotID)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -36,11 +36,29 @@ func resourceGns3StartAllCreate(d *schema.ResourceData, meta interface{}) error
 	host := config.Host
 	projectID := d.Get("project_id").(string)
 
+	// With a stagger, start the nodes one by one instead of all at once.
+	if config.StartStagger > 0 {
+		nodes, err := listProjectNodes(config, projectID)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			if node["status"] == "started" {
+				continue
+			}
+			if err := nodeAction(config, projectID, node["node_id"].(string), "start"); err != nil {
+				return err
+			}
+		}
+		d.SetId(projectID + "-start")
+		return nil
+	}
+
 	// Build the URL for starting all nodes.
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes/start", host, projectID)
 
 	// The API may expect an empty JSON object; adjust as needed.
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer([]byte("{}")))
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer([]byte("{}")))
 	if err != nil {
 		return fmt.Errorf("failed to start all nodes: %s", err)
 	}
@@ -10,33 +10,124 @@ import (
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Switch represents a GNS3 switch node API request/response.
 type Switch struct {
-	Name      string `json:"name"`
-	NodeType  string `json:"node_type"`
-	ComputeID string `json:"compute_id,omitempty"`
-	NodeID    string `json:"node_id,omitempty"`
-	X         int    `json:"x,omitempty"`
-	Y         int    `json:"y,omitempty"`
+	Name        string `json:"name"`
+	NodeType    string `json:"node_type"`
+	ComputeID   string `json:"compute_id,omitempty"`
+	NodeID      string `json:"node_id,omitempty"`
+	ConsoleType string `json:"console_type,omitempty"`
+	Symbol      string `json:"symbol,omitempty"`
+	X           int    `json:"x,omitempty"`
+	Y           int    `json:"y,omitempty"`
+
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// switchPortTypes are the port modes of a GNS3 Ethernet switch.
+var switchPortTypes = []string{"access", "dot1q", "qinq"}
+
+// switchPortsMappingSchema returns the schema of the switch ports and their VLANs.
+func switchPortsMappingSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Computed:    true,
+		Description: "Switch ports with their VLAN configuration. Read back from the controller, so switches built in the GUI keep their VLANs when imported. GNS3 creates 8 access ports in VLAN 1 when unset.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:        schema.TypeString,
+					Required:    true,
+					Description: "Port name, e.g. Ethernet0.",
+				},
+				"port_number": {
+					Type:        schema.TypeInt,
+					Required:    true,
+					Description: "Port number.",
+				},
+				"type": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "access",
+					ValidateFunc: validation.StringInSlice(switchPortTypes, false),
+					Description:  "Port mode: access, dot1q (trunk) or qinq.",
+				},
+				"vlan": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Default:     1,
+					Description: "Access VLAN, or native VLAN of a dot1q or qinq port.",
+				},
+				"ethertype": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringInSlice([]string{"", "0x8100", "0x88A8", "0x9100", "0x9200"}, false),
+					Description:  "Outer tag ethertype of a qinq port.",
+				},
+			},
+		},
+	}
+}
+
+// expandSwitchPortsMapping converts the ports_mapping attribute into the controller format.
+func expandSwitchPortsMapping(raw []interface{}) []map[string]interface{} {
+	ports := make([]map[string]interface{}, 0, len(raw))
+	for _, r := range raw {
+		p := r.(map[string]interface{})
+		ports = append(ports, map[string]interface{}{
+			"name":        p["name"],
+			"port_number": p["port_number"],
+			"type":        p["type"],
+			"vlan":        p["vlan"],
+			"ethertype":   p["ethertype"],
+		})
+	}
+	return ports
+}
+
+// flattenSwitchPortsMapping converts the ports_mapping property of a switch node into state.
+func flattenSwitchPortsMapping(node map[string]interface{}) []map[string]interface{} {
+	props, _ := node["properties"].(map[string]interface{})
+	raw, _ := props["ports_mapping"].([]interface{})
+	ports := make([]map[string]interface{}, 0, len(raw))
+	for _, r := range raw {
+		p, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ethertype, _ := p["ethertype"].(string)
+		ports = append(ports, map[string]interface{}{
+			"name":        p["name"],
+			"port_number": jsonInt(p["port_number"]),
+			"type":        p["type"],
+			"vlan":        jsonInt(p["vlan"]),
+			"ethertype":   ethertype,
+		})
+	}
+	return ports
 }
 
 // resourceGns3Switch defines the Terraform resource schema for GNS3 switch nodes.
 func resourceGns3Switch() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceGns3SwitchCreate,
-		Read:   resourceGns3SwitchRead,
-		Update: resourceGns3SwitchUpdate,
-		Delete: resourceGns3SwitchDelete,
+		CreateContext: withCanvasCheck(resourceGns3SwitchCreate),
+		Read:          resourceGns3SwitchRead,
+		UpdateContext: withCanvasCheck(resourceGns3SwitchUpdate),
+		Delete:        resourceGns3SwitchDelete,
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceGns3SwitchImporter,
 		},
+		CustomizeDiff: computeCustomizeDiff,
 
 		Schema: map[string]*schema.Schema{
 			"project_id": {
 				Type:        schema.TypeString,
 				Required:    true,
+				ForceNew:    true,
 				Description: "The project ID where the switch is deployed.",
 			},
 			"name": {
@@ -48,7 +139,8 @@ func resourceGns3Switch() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "local",
-				Description: "Compute ID where the switch node is running.",
+				ForceNew:    true,
+				Description: "Compute ID where the switch node is running. The controller cannot move a node, so changing it recreates the node.",
 			},
 			"x": { // ✅ Added X coordinate support
 				Type:        schema.TypeInt,
@@ -65,6 +157,35 @@ func resourceGns3Switch() *schema.Resource {
 				Computed:    true,
 				Description: "The switch node's ID assigned by GNS3.",
 			},
+			"console_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"telnet", "none"}, false),
+				Description:  "Console type of the switch (telnet or none). The telnet console allows interactive VLAN changes.",
+			},
+			"console": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Console TCP port allocated by GNS3.",
+			},
+			"console_host": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Host to connect to for the console.",
+			},
+			"symbol": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Symbol shown for the node on the canvas, e.g. :/symbols/multilayer_switch.svg. Defaults to the GNS3 Ethernet switch symbol.",
+			},
+			"status":         nodeStatusSchema(),
+			"ports_mapping":  switchPortsMappingSchema(),
+			"ports":          nodePortsSchema(),
+			"gns3_url":       webURLSchema("Web UI link to the node's console."),
+			"adopt_existing": adoptExistingSchema(),
+			"protect":        protectSchema(),
 		},
 	}
 }
@@ -78,13 +199,27 @@ func resourceGns3SwitchCreate(d *schema.ResourceData, meta interface{}) error {
 	x := d.Get("x").(int) // ✅ Retrieve X coordinate
 	y := d.Get("y").(int) // ✅ Retrieve Y coordinate
 
+	adopted, err := adoptExistingNode(d, config, projectID, name, "ethernet_switch")
+	if err != nil {
+		return err
+	}
+	if adopted {
+		d.Set("switch_id", d.Id())
+		return resourceGns3SwitchUpdate(d, meta)
+	}
+
 	// Build the payload with X and Y coordinates
 	sw := Switch{
-		Name:      name,
-		NodeType:  "ethernet_switch",
-		ComputeID: computeID,
-		X:         x,
-		Y:         y,
+		Name:        name,
+		NodeType:    "ethernet_switch",
+		ComputeID:   computeID,
+		ConsoleType: d.Get("console_type").(string),
+		Symbol:      d.Get("symbol").(string),
+		X:           x,
+		Y:           y,
+	}
+	if v, ok := d.GetOk("ports_mapping"); ok {
+		sw.Properties = map[string]interface{}{"ports_mapping": expandSwitchPortsMapping(v.([]interface{}))}
 	}
 
 	data, err := json.Marshal(sw)
@@ -93,7 +228,7 @@ func resourceGns3SwitchCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes", host, projectID)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("error creating GNS3 switch: %s", err)
 	}
@@ -116,7 +251,7 @@ func resourceGns3SwitchCreate(d *schema.ResourceData, meta interface{}) error {
 
 	d.SetId(createdSwitch.NodeID)
 	d.Set("switch_id", createdSwitch.NodeID)
-	return nil
+	return resourceGns3SwitchRead(d, meta)
 }
 
 // Update function for modifying existing switch nodes
@@ -129,11 +264,23 @@ func resourceGns3SwitchUpdate(d *schema.ResourceData, meta interface{}) error {
 	updateData := map[string]interface{}{}
 
 	if d.HasChange("name") {
-		updateData["name"] = d.Get("name").(string)
+		name := d.Get("name").(string)
+		updateData["name"] = name
+		label, err := renamedNodeLabel(config, projectID, switchID, name)
+		if err != nil {
+			return err
+		}
+		if label != nil {
+			updateData["label"] = label
+		}
+	}
+
+	if d.HasChange("symbol") {
+		updateData["symbol"] = d.Get("symbol").(string)
 	}
 
-	if d.HasChange("compute_id") {
-		updateData["compute_id"] = d.Get("compute_id").(string)
+	if d.HasChange("console_type") {
+		updateData["console_type"] = d.Get("console_type").(string)
 	}
 
 	if d.HasChange("x") {
@@ -144,6 +291,12 @@ func resourceGns3SwitchUpdate(d *schema.ResourceData, meta interface{}) error {
 		updateData["y"] = d.Get("y").(int) // ✅ Update Y coordinate
 	}
 
+	if d.HasChange("ports_mapping") {
+		updateData["properties"] = map[string]interface{}{
+			"ports_mapping": expandSwitchPortsMapping(d.Get("ports_mapping").([]interface{})),
+		}
+	}
+
 	if len(updateData) == 0 {
 		return nil
 	}
@@ -160,8 +313,7 @@ func resourceGns3SwitchUpdate(d *schema.ResourceData, meta interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error updating GNS3 switch node: %s", err)
 	}
@@ -182,7 +334,7 @@ func resourceGns3SwitchRead(d *schema.ResourceData, meta interface{}) error {
 	nodeID := d.Id()
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to read switch node: %s", err)
 	}
@@ -199,30 +351,41 @@ func resourceGns3SwitchRead(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("failed to read switch node, status code: %d, body: %s", resp.StatusCode, body)
 	}
 
-	// Optional: parse attributes and update d.Set(...)
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("failed to decode switch node: %s", err)
+	}
+	d.Set("name", node["name"])
+	d.Set("switch_id", node["node_id"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("symbol", node["symbol"])
+	d.Set("status", node["status"])
+	d.Set("console", jsonInt(node["console"]))
+	d.Set("console_type", node["console_type"])
+	d.Set("console_host", consoleHost(config, node))
+	if err := d.Set("ports_mapping", flattenSwitchPortsMapping(node)); err != nil {
+		return fmt.Errorf("failed to set ports_mapping: %s", err)
+	}
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+
 	return nil
 }
 
 func resourceGns3SwitchDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "switch"); err != nil {
+		return err
+	}
+
 	config := meta.(*ProviderConfig)
-	host := config.Host
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
-	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request for switch: %s", err)
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to delete switch: %s", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to delete switch, status code: %d", resp.StatusCode)
+	if err := deleteNode(config, projectID, nodeID); err != nil {
+		return err
 	}
 
 	d.SetId("")
@@ -240,13 +403,31 @@ func resourceGns3SwitchImporter(
 		projectID = parts[0]
 		nodeID = parts[1]
 	} else {
-		return nil, fmt.Errorf("invalid import ID %q — expected format <project_id>/<node_id>", raw)
+		return nil, fmt.Errorf("invalid import ID %q — expected format <project_id>/<node_id> or <project_id>/<name>", raw)
+	}
+
+	// The second part is a node ID, or else the name of the switch
+	config := meta.(*ProviderConfig)
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		if node, err = findNodeByName(config, projectID, nodeID); err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nil, fmt.Errorf("no switch with ID or name %q in project %s", nodeID, projectID)
+		}
+	}
+	if node["node_type"] != "ethernet_switch" {
+		return nil, fmt.Errorf("node %q is a %v, not an ethernet_switch", nodeID, node["node_type"])
 	}
 
 	if err := d.Set("project_id", projectID); err != nil {
 		return nil, err
 	}
-	d.SetId(nodeID)
+	d.SetId(node["node_id"].(string))
 
 	return []*schema.ResourceData{d}, nil
 }
@@ -9,24 +9,138 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
+// nodeAttributeOverrides are the property_overrides that are node attributes
+// rather than entries of the node's properties.
+var nodeAttributeOverrides = []string{"console", "console_type"}
+
+// immutableOverrides are the properties GNS3 only reads when it creates a node,
+// e.g. whether the disks are linked clones of the template's.
+var immutableOverrides = []string{"linked_clone", "platform"}
+
+// immutableOverridesCustomizeDiff plans a replacement when an override of a
+// property the controller cannot change in place is added, changed or removed.
+func immutableOverridesCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("property_overrides") || !d.NewValueKnown("property_overrides") {
+		return nil
+	}
+	oldRaw, newRaw := d.GetChange("property_overrides")
+	old, overrides := oldRaw.(map[string]interface{}), newRaw.(map[string]interface{})
+	for _, key := range immutableOverrides {
+		if old[key] != overrides[key] {
+			return d.ForceNew("property_overrides")
+		}
+	}
+	return nil
+}
+
+// propertyOverrideValue converts an override to the JSON type GNS3 expects:
+// numbers and booleans are sent as such, anything else as a string.
+func propertyOverrideValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		switch v.(type) {
+		case float64, bool:
+			return v
+		}
+	}
+	return s
+}
+
+// addPropertyOverrides adds the changed property_overrides to a node update
+// payload. Overrides removed from the configuration get the template's value back.
+func addPropertyOverrides(d *schema.ResourceData, config *ProviderConfig, payload map[string]interface{}) error {
+	props, _ := payload["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	set := func(key string, value interface{}) {
+		if containsString(nodeAttributeOverrides, key) {
+			payload[key] = value
+		} else {
+			props[key] = value
+		}
+	}
+
+	oldRaw, newRaw := d.GetChange("property_overrides")
+	old, overrides := oldRaw.(map[string]interface{}), newRaw.(map[string]interface{})
+	for key, value := range overrides {
+		if d.IsNewResource() || old[key] != value {
+			set(key, propertyOverrideValue(value.(string)))
+		}
+	}
+
+	var template map[string]interface{}
+	for key := range old {
+		if _, kept := overrides[key]; kept {
+			continue
+		}
+		if template == nil {
+			templates, err := listTemplates(config)
+			if err != nil {
+				return err
+			}
+			template = map[string]interface{}{}
+			for _, t := range templates {
+				if t["template_id"] == d.Get("template_id") {
+					template = t
+				}
+			}
+		}
+		if value, ok := template[key]; ok {
+			set(key, value)
+		}
+	}
+
+	if len(props) > 0 {
+		payload["properties"] = props
+	}
+	return nil
+}
+
+// flattenPropertyOverrides reads the overridden settings back from a node, so
+// changes made outside Terraform show up as drift.
+func flattenPropertyOverrides(d *schema.ResourceData, node map[string]interface{}) map[string]interface{} {
+	props, _ := node["properties"].(map[string]interface{})
+	overrides := d.Get("property_overrides").(map[string]interface{})
+	for key := range overrides {
+		value := props[key]
+		if containsString(nodeAttributeOverrides, key) {
+			value = node[key]
+		}
+		switch v := value.(type) {
+		case nil:
+		case string:
+			overrides[key] = v
+		default:
+			if data, err := json.Marshal(v); err == nil {
+				overrides[key] = string(data)
+			}
+		}
+	}
+	return overrides
+}
+
 // resourceGns3Template defines the Terraform resource schema for GNS3 templates.
 func resourceGns3Template() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceGns3TemplateCreate,
-		Read:   resourceGns3TemplateRead,
-		Update: resourceGns3TemplateUpdate,
-		Delete: resourceGns3TemplateDelete,
+		CreateContext: withCanvasCheck(resourceGns3TemplateCreate),
+		Read:          resourceGns3TemplateRead,
+		UpdateContext: withCanvasCheck(resourceGns3TemplateUpdate),
+		Delete:        resourceGns3TemplateDelete,
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceGns3TemplateImporter,
 		},
+		CustomizeDiff: customdiff.All(desiredStateCustomizeDiff, computeCustomizeDiff, immutableOverridesCustomizeDiff, reloadOnChangeCustomizeDiff(resourceGns3Template)),
 
 		Schema: map[string]*schema.Schema{
 			"project_id": {
 				Type:     schema.TypeString,
 				Required: true,
+				ForceNew: true,
 			},
 			"template_id": {
 				Type:     schema.TypeString,
@@ -38,14 +152,25 @@ func resourceGns3Template() *schema.Resource {
 				Required: true,
 			},
 			"compute_id": {
-				Type:     schema.TypeString,
-				Optional: true,
-				Default:  "local",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "local",
+				ForceNew:    true,
+				Description: "The compute to create the node on (default: 'local'). Other computes must be registered and connected. The controller cannot move a node, so changing it recreates the node.",
 			},
 			"start": {
-				Type:     schema.TypeBool,
-				Optional: true,
-				Default:  false,
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the node runs. Changing it starts or stops the node. Use desired_state to also keep it in that state.",
+			},
+			"desired_state":  desiredStateSchema("start"),
+			"reload_trigger": reloadTriggerSchema(),
+			"symbol": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Symbol shown for the node on the canvas, e.g. :/symbols/router.svg. Defaults to the template's symbol.",
 			},
 			"x": {
 				Type:     schema.TypeInt,
@@ -57,6 +182,28 @@ func resourceGns3Template() *schema.Resource {
 				Optional: true,
 				Default:  0,
 			},
+			"node_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the node created from the template.",
+			},
+			"property_overrides": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Settings of this node that differ from the template, e.g. ram, adapters or console_type. Numbers and booleans are sent as such. Removing an override restores the template's value.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"stop_before_update":   stopBeforeUpdateSchema(),
+			"restart_after_update": restartAfterUpdateSchema(),
+			"ports":                nodePortsSchema(),
+			"gns3_url":             webURLSchema("Web UI link to the node's console."),
+			"status":               nodeStatusSchema(),
+			"adopt_existing":       adoptExistingSchema(),
+			"protect":              protectSchema(),
+			"reload_on_change":     reloadOnChangeSchema(),
+			"console_auto_start":   consoleAutoStartSchema(),
 		},
 	}
 }
@@ -71,6 +218,15 @@ func resourceGns3TemplateCreate(d *schema.ResourceData, meta interface{}) error
 	x := d.Get("x").(int)
 	y := d.Get("y").(int)
 
+	adopted, err := adoptExistingNode(d, config, projectID, templateName, "")
+	if err != nil {
+		return err
+	}
+	if adopted {
+		d.Set("node_id", d.Id())
+		return resourceGns3TemplateUpdate(d, meta)
+	}
+
 	// Create template request payload
 	templateData := map[string]interface{}{
 		"name":       templateName,
@@ -85,7 +241,7 @@ func resourceGns3TemplateCreate(d *schema.ResourceData, meta interface{}) error
 	}
 
 	// Send the request to create the template
-	resp, err := http.Post(fmt.Sprintf("%s/v2/projects/%s/templates/%s", host, projectID, templateID), "application/json", bytes.NewBuffer(nodeBody))
+	resp, err := config.Client.Post(fmt.Sprintf("%s/v2/projects/%s/templates/%s", host, projectID, templateID), "application/json", bytes.NewBuffer(nodeBody))
 	if err != nil {
 		return fmt.Errorf("error creating GNS3 template: %s", err)
 	}
@@ -107,21 +263,43 @@ func resourceGns3TemplateCreate(d *schema.ResourceData, meta interface{}) error
 
 	// Set the resource ID in Terraform
 	d.SetId(templateNodeID)
+	d.Set("node_id", templateNodeID)
 
-	// Check if the "start" attribute is true and start the node if so.
-	if d.Get("start").(bool) {
-		startURL := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/start", host, projectID, templateNodeID)
-		startResp, err := http.Post(startURL, "application/json", nil)
-		if err != nil {
-			return fmt.Errorf("error starting node: %s", err)
+	// Instantiating a template only takes a name and position; apply the symbol
+	// and default labels afterwards
+	post := map[string]interface{}{}
+	if symbol, ok := d.GetOk("symbol"); ok {
+		post["symbol"] = symbol
+	}
+	if v, ok := d.GetOkExists("console_auto_start"); ok {
+		post["console_auto_start"] = v.(bool)
+	}
+	if len(config.DefaultLabels) > 0 {
+		props, _ := createdTemplate["properties"].(map[string]interface{})
+		templateUsage, _ := props["usage"].(string)
+		post["properties"] = map[string]interface{}{"usage": usageWithLabels(config, templateUsage)}
+	}
+	if err := addPropertyOverrides(d, config, post); err != nil {
+		return err
+	}
+	if len(post) > 0 {
+		if err := updateNode(config, projectID, templateNodeID, post); err != nil {
+			return err
 		}
-		defer startResp.Body.Close()
-		if startResp.StatusCode != http.StatusOK {
-			return fmt.Errorf("failed to start node, status code: %d", startResp.StatusCode)
+	}
+
+	if err := applyDesiredState(d, config, projectID, templateNodeID); err != nil {
+		return err
+	}
+
+	// Check if the "start" attribute is true and start the node if so.
+	if startRequested(d, config, "start") {
+		if err := nodeAction(config, projectID, templateNodeID, "start"); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return resourceGns3TemplateRead(d, meta)
 }
 
 func resourceGns3TemplateRead(d *schema.ResourceData, meta interface{}) error {
@@ -131,7 +309,7 @@ func resourceGns3TemplateRead(d *schema.ResourceData, meta interface{}) error {
 	nodeID := d.Id()
 
 	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	resp, err := http.Get(url)
+	resp, err := config.Client.Get(url)
 	if err != nil {
 		return fmt.Errorf("error reading GNS3 node (template): %s", err)
 	}
@@ -147,6 +325,28 @@ func resourceGns3TemplateRead(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("failed to read template node, status code: %d, response: %s", resp.StatusCode, string(body))
 	}
 
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("error decoding template node: %s", err)
+	}
+
+	d.Set("node_id", node["node_id"])
+	d.Set("template_id", node["template_id"])
+	d.Set("compute_id", node["compute_id"])
+	d.Set("name", node["name"])
+	d.Set("x", jsonInt(node["x"]))
+	d.Set("y", jsonInt(node["y"]))
+	d.Set("symbol", node["symbol"])
+	d.Set("console_auto_start", node["console_auto_start"] == true)
+	if err := d.Set("property_overrides", flattenPropertyOverrides(d, node)); err != nil {
+		return fmt.Errorf("failed to set property_overrides: %s", err)
+	}
+	if err := d.Set("ports", flattenNodePorts(node)); err != nil {
+		return fmt.Errorf("failed to set ports: %s", err)
+	}
+	d.Set("gns3_url", nodeWebURL(config, projectID, d.Id()))
+	d.Set("status", node["status"])
+
 	return nil
 }
 
@@ -156,6 +356,23 @@ func resourceGns3TemplateUpdate(d *schema.ResourceData, meta interface{}) error
 	projectID := d.Get("project_id").(string)
 	templateID := d.Id()
 
+	// Overridden settings may only be read by the node when it boots
+	stopped := false
+	if d.HasChange("property_overrides") {
+		node, err := getNode(config, projectID, templateID)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			d.SetId("")
+			return nil
+		}
+		status, _ := node["status"].(string)
+		if stopped, err = stopForUpdate(d, config, projectID, templateID, status, "property_overrides"); err != nil {
+			return err
+		}
+	}
+
 	// Build the update payload with the updated attributes.
 	updateData := map[string]interface{}{
 		"name":       d.Get("name").(string),
@@ -163,6 +380,17 @@ func resourceGns3TemplateUpdate(d *schema.ResourceData, meta interface{}) error
 		"x":          d.Get("x").(int),
 		"y":          d.Get("y").(int),
 	}
+	if d.HasChange("console_auto_start") {
+		updateData["console_auto_start"] = d.Get("console_auto_start").(bool)
+	}
+	if symbol, ok := d.GetOk("symbol"); ok {
+		updateData["symbol"] = symbol
+	}
+	if d.HasChange("property_overrides") {
+		if err := addPropertyOverrides(d, config, updateData); err != nil {
+			return err
+		}
+	}
 
 	data, err := json.Marshal(updateData)
 	if err != nil {
@@ -177,8 +405,7 @@ func resourceGns3TemplateUpdate(d *schema.ResourceData, meta interface{}) error
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := config.Client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to update template: %s", err)
 	}
@@ -188,37 +415,45 @@ func resourceGns3TemplateUpdate(d *schema.ResourceData, meta interface{}) error
 		return fmt.Errorf("failed to update template, status code: %d", resp.StatusCode)
 	}
 
+	if err := reloadNodeIfChanged(d, config, projectID, templateID); err != nil {
+		return err
+	}
+	if err := restartAfterUpdate(d, config, projectID, templateID, stopped); err != nil {
+		return err
+	}
+	if err := applyDesiredState(d, config, projectID, templateID); err != nil {
+		return err
+	}
+
+	if d.HasChange("start") {
+		action := ""
+		if startRequested(d, config, "start") {
+			action = "start"
+		} else if !d.Get("start").(bool) {
+			action = "stop"
+		}
+		if action != "" {
+			if err := nodeAction(config, projectID, templateID, action); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Optionally, re-read the resource to update state.
 	return resourceGns3TemplateRead(d, meta)
 }
 
 func resourceGns3TemplateDelete(d *schema.ResourceData, meta interface{}) error {
+	if err := checkProtected(d, "node"); err != nil {
+		return err
+	}
+
 	config := meta.(*ProviderConfig)
-	host := config.Host
 	projectID := d.Get("project_id").(string)
 	nodeID := d.Id()
 
-	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", host, projectID, nodeID)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request for template node: %s", err)
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to delete template node: %s", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		d.SetId("")
-		return nil
-	}
-
-	if resp.StatusCode != http.StatusNoContent {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("failed to delete template node, status code: %d, body: %s", resp.StatusCode, body)
+	if err := deleteNode(config, projectID, nodeID); err != nil {
+		return err
 	}
 
 	d.SetId("")
@@ -243,6 +478,9 @@ func resourceGns3TemplateImporter(
 	if err := d.Set("project_id", projectID); err != nil {
 		return nil, err
 	}
+	if err := setImportDefaults(d, resourceGns3Template().Schema, "start", "protect", "adopt_existing", "stop_before_update", "restart_after_update"); err != nil {
+		return nil, err
+	}
 	d.SetId(nodeID)
 	return []*schema.ResourceData{d}, nil
 }
@@ -0,0 +1,3049 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// resourceTestCase describes one resource lifecycle run against the mock controller.
+type resourceTestCase struct {
+	name     string
+	resource *schema.Resource
+	// setup seeds the mock and returns the create and update configurations.
+	setup func(t *testing.T, m *mockController) (create, update map[string]interface{})
+	// check inspects the mock after create and after update.
+	checkCreate func(t *testing.T, m *mockController, s *terraform.InstanceState)
+	checkUpdate func(t *testing.T, m *mockController, s *terraform.InstanceState)
+	// importID builds the import ID from the created state; nil skips the import step.
+	importID func(s *terraform.InstanceState) string
+}
+
+// applyConfig diffs raw against state and applies the result, as terraform apply would.
+func applyConfig(t *testing.T, r *schema.Resource, state *terraform.InstanceState, raw map[string]interface{}, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+	ctx := context.Background()
+
+	diff, err := r.Diff(ctx, state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	if diff == nil {
+		return state
+	}
+	newState, diags := r.Apply(ctx, state, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("apply failed: %v", diags)
+	}
+	return newState
+}
+
+// destroy applies a destroy diff to state.
+func destroy(r *schema.Resource, state *terraform.InstanceState, meta interface{}) error {
+	newState, diags := r.Apply(context.Background(), state, &terraform.InstanceDiff{Destroy: true}, meta)
+	if diags.HasError() {
+		return fmt.Errorf("%v", diags)
+	}
+	if newState != nil && newState.ID != "" {
+		return fmt.Errorf("resource still has ID %q after destroy", newState.ID)
+	}
+	return nil
+}
+
+func nodePath(s *terraform.InstanceState) string {
+	return fmt.Sprintf("/v2/projects/%s/nodes/%s", s.Attributes["project_id"], s.ID)
+}
+
+func nodeImportID(s *terraform.InstanceState) string {
+	return s.Attributes["project_id"] + "/" + s.ID
+}
+
+func TestResourceLifecycle(t *testing.T) {
+	cases := []resourceTestCase{
+		{
+			name:     "project",
+			resource: resourceGns3Project(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				return map[string]interface{}{"name": "lab", "scene_width": 4000, "snap_to_grid": true},
+					map[string]interface{}{"name": "lab-renamed", "state": "closed", "scene_width": 4000, "snap_to_grid": true, "grid_size": 50, "show_grid": true}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if s.Attributes["state"] != "opened" {
+					t.Errorf("expected project to be opened, got %q", s.Attributes["state"])
+				}
+				if s.Attributes["scene_width"] != "4000" || s.Attributes["scene_height"] != "1000" || s.Attributes["snap_to_grid"] != "true" {
+					t.Errorf("canvas settings not read back: %v", s.Attributes)
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				project := m.object("/v2/projects/" + s.ID)
+				if project["name"] != "lab-renamed" || project["status"] != "closed" {
+					t.Errorf("project not updated: %v", project)
+				}
+				if jsonInt(project["grid_size"]) != 50 || project["show_grid"] != true {
+					t.Errorf("canvas settings not updated: %v", project)
+				}
+			},
+			importID: func(s *terraform.InstanceState) string { return s.ID },
+		},
+		{
+			name:     "cloud",
+			resource: resourceGns3Cloud(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "name": "wan", "x": 10, "y": 20},
+					map[string]interface{}{"project_id": pid, "name": "wan", "x": 30, "y": 40}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if node := m.object(nodePath(s)); node["node_type"] != "cloud" {
+					t.Errorf("expected cloud node, got %v", node)
+				}
+				if s.Attributes["ports.#"] != "1" {
+					t.Errorf("expected 1 port, got %s", s.Attributes["ports.#"])
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if node := m.object(nodePath(s)); jsonInt(node["x"]) != 30 {
+					t.Errorf("cloud x not updated: %v", node["x"])
+				}
+			},
+			importID: nodeImportID,
+		},
+		{
+			name:     "switch",
+			resource: resourceGns3Switch(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "name": "sw1"},
+					map[string]interface{}{"project_id": pid, "name": "sw1", "x": 5}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if node := m.object(nodePath(s)); node["node_type"] != "ethernet_switch" {
+					t.Errorf("expected ethernet_switch node, got %v", node)
+				}
+				if s.Attributes["console_host"] == "0.0.0.0" || s.Attributes["console_host"] == "" {
+					t.Errorf("console_host should be resolved to the controller host, got %q", s.Attributes["console_host"])
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if node := m.object(nodePath(s)); jsonInt(node["x"]) != 5 {
+					t.Errorf("switch x not updated: %v", node["x"])
+				}
+			},
+			importID: nodeImportID,
+		},
+		{
+			name:     "template",
+			resource: resourceGns3Template(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "template_id": "tmpl-router", "name": "r1", "x": 1, "y": 2},
+					map[string]interface{}{"project_id": pid, "template_id": "tmpl-router", "name": "r1-core", "x": 1, "y": 2, "symbol": ":/symbols/firewall.svg", "start": true}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if s.Attributes["node_id"] != s.ID {
+					t.Errorf("node_id %q does not match ID %q", s.Attributes["node_id"], s.ID)
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if node := m.object(nodePath(s)); node["name"] != "r1-core" || node["symbol"] != ":/symbols/firewall.svg" {
+					t.Errorf("template node not updated: %v", node)
+				}
+				if m.lastRequest("POST", nodePath(s)+"/start") == nil {
+					t.Errorf("expected template node to be started when start changed")
+				}
+				if s.Attributes["status"] != "started" {
+					t.Errorf("expected status to be read back as started, got %q", s.Attributes["status"])
+				}
+			},
+			importID: nodeImportID,
+		},
+		{
+			name:     "docker",
+			resource: resourceGns3Docker(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "name": "dhcp", "image": "networkboot/dhcpd", "environment": map[string]interface{}{"A": "1"}},
+					map[string]interface{}{"project_id": pid, "name": "dhcp", "image": "networkboot/dhcpd", "environment": map[string]interface{}{"A": "2"}, "usage": "admin/admin"}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				req := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", s.Attributes["project_id"]))
+				props, _ := req.Body["properties"].(map[string]interface{})
+				if props["image"] != "networkboot/dhcpd" || props["environment"] != "A=1" {
+					t.Errorf("unexpected docker create payload: %v", req.Body)
+				}
+				ports, _ := m.object(nodePath(s))["ports"].([]interface{})
+				if mac := ports[0].(map[string]interface{})["mac_address"]; s.Attributes["mac_addresses.#"] != "1" || s.Attributes["mac_addresses.0"] != mac {
+					t.Errorf("expected adapter MAC %v in state, got %v", mac, s.Attributes)
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if req := m.lastRequest("PUT", nodePath(s)); req == nil {
+					t.Errorf("expected docker node to be updated")
+				}
+				if props, _ := m.object(nodePath(s))["properties"].(map[string]interface{}); props["usage"] != "admin/admin" {
+					t.Errorf("docker usage not updated: %v", props["usage"])
+				}
+			},
+			importID: nodeImportID,
+		},
+		{
+			name:     "qemu_node",
+			resource: resourceGns3Qemu(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "name": "vm1", "ram": 512, "options": "-nographic", "hda_disk_image": "vm.qcow2", "hda_disk_interface": "sata"},
+					map[string]interface{}{"project_id": pid, "name": "vm1", "ram": 1024, "options": "-nographic", "hda_disk_image": "vm.qcow2", "hda_disk_interface": "sata"}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				req := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", s.Attributes["project_id"]))
+				props, _ := req.Body["properties"].(map[string]interface{})
+				if req.Body["node_type"] != "qemu" || jsonInt(props["ram"]) != 512 || props["options"] != "-nographic" || props["hda_disk_interface"] != "sata" {
+					t.Errorf("unexpected qemu create payload: %v", req.Body)
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				props, _ := m.object(nodePath(s))["properties"].(map[string]interface{})
+				if jsonInt(props["ram"]) != 1024 {
+					t.Errorf("qemu ram not updated: %v", props["ram"])
+				}
+			},
+			importID: nodeImportID,
+		},
+		{
+			name:     "link",
+			resource: resourceGns3Link(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				a := m.addNode(pid, "a", "vpcs")
+				b := m.addNode(pid, "b", "ethernet_switch")
+				c := map[string]interface{}{
+					"project_id": pid, "node_a_id": a, "node_a_adapter": 0, "node_a_port": 0,
+					"node_b_id": b, "node_b_adapter": 0, "node_b_port": 0,
+				}
+				u := map[string]interface{}{}
+				for k, v := range c {
+					u[k] = v
+				}
+				u["node_b_port"] = 1
+				return c, u
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				link := m.object(fmt.Sprintf("/v2/projects/%s/links/%s", s.Attributes["project_id"], s.ID))
+				if nodes, _ := link["nodes"].([]interface{}); len(nodes) != 2 {
+					t.Errorf("expected link between 2 nodes, got %v", link)
+				}
+				if s.Attributes["node_a_name"] != "a" || s.Attributes["node_b_name"] != "b" {
+					t.Errorf("expected endpoint names a and b, got %q and %q", s.Attributes["node_a_name"], s.Attributes["node_b_name"])
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if req := m.lastRequest("PUT", fmt.Sprintf("/v2/projects/%s/links/%s", s.Attributes["project_id"], s.ID)); req == nil {
+					t.Errorf("expected link to be updated")
+				}
+			},
+			importID: func(s *terraform.InstanceState) string { return s.Attributes["project_id"] + "/" + s.ID },
+		},
+		{
+			name:     "start_all",
+			resource: resourceGns3StartAll(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				m.addNode(pid, "a", "vpcs")
+				return map[string]interface{}{"project_id": pid}, nil
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes/start", s.Attributes["project_id"])) == nil {
+					t.Errorf("expected nodes to be started")
+				}
+			},
+		},
+		{
+			name:     "text_annotation",
+			resource: resourceGns3TextAnnotation(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "text": "Core <DC1>"},
+					map[string]interface{}{"project_id": pid, "text": "Core <DC2>", "bold": true}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if s.Attributes["svg"] == "" {
+					t.Errorf("expected svg to be set")
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				drawing := m.object(fmt.Sprintf("/v2/projects/%s/drawings/%s", s.Attributes["project_id"], s.ID))
+				if svg, _ := drawing["svg"].(string); !strings.Contains(svg, "&lt;DC2&gt;") || !strings.Contains(svg, `font-weight="bold"`) {
+					t.Errorf("drawing svg not updated: %s", svg)
+				}
+			},
+			importID: func(s *terraform.InstanceState) string { return s.Attributes["project_id"] + "/" + s.ID },
+		},
+		{
+			name:     "snapshot",
+			resource: resourceGns3Snapshot(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				return map[string]interface{}{"project_id": pid, "name": "baseline"},
+					map[string]interface{}{"project_id": pid, "name": "baseline", "restore_trigger": "1"}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if s.Attributes["created_at"] == "" || s.Attributes["created_at"] == "0" {
+					t.Errorf("expected created_at to be set")
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/snapshots/%s/restore", s.Attributes["project_id"], s.ID)) == nil {
+					t.Errorf("expected snapshot to be restored")
+				}
+			},
+			importID: func(s *terraform.InstanceState) string { return s.Attributes["project_id"] + "/" + s.ID },
+		},
+		{
+			name:     "node_duplicate",
+			resource: resourceGns3NodeDuplicate(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				pid := m.addProject("lab")
+				src := m.addNode(pid, "golden", "qemu")
+				return map[string]interface{}{"project_id": pid, "source_node_id": src, "name": "clone", "x": 100, "y": 100},
+					map[string]interface{}{"project_id": pid, "source_node_id": src, "name": "clone", "x": 200, "y": 100}
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if s.ID == s.Attributes["source_node_id"] || s.Attributes["node_type"] != "qemu" {
+					t.Errorf("unexpected clone state: %v", s.Attributes)
+				}
+			},
+			checkUpdate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if node := m.object(nodePath(s)); jsonInt(node["x"]) != 200 {
+					t.Errorf("clone x not updated: %v", node["x"])
+				}
+			},
+			importID: nodeImportID,
+		},
+		{
+			name:     "udp_tunnel",
+			resource: resourceGns3UDPTunnel(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				a := m.addProject("site-a")
+				b := m.addProject("site-b")
+				return map[string]interface{}{"name": "wan", "project_a_id": a, "project_b_id": b}, nil
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				cloud := m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", s.Attributes["project_b_id"], s.Attributes["cloud_b_id"]))
+				props, _ := cloud["properties"].(map[string]interface{})
+				mapping, _ := props["ports_mapping"].([]interface{})
+				if len(mapping) != 1 {
+					t.Fatalf("expected one UDP mapping, got %v", props)
+				}
+				port := mapping[0].(map[string]interface{})
+				if port["type"] != "udp" || fmt.Sprint(jsonInt(port["rport"])) != s.Attributes["port_a"] {
+					t.Errorf("cloud b does not point at end a: %v", port)
+				}
+			},
+		},
+		{
+			name:     "image",
+			resource: resourceGns3Image(),
+			setup: func(t *testing.T, m *mockController) (map[string]interface{}, map[string]interface{}) {
+				source := filepath.Join(t.TempDir(), "disk.qcow2")
+				if err := ioutil.WriteFile(source, []byte("qcow2 image"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return map[string]interface{}{"source": source, "discovery_timeout": 5}, nil
+			},
+			checkCreate: func(t *testing.T, m *mockController, s *terraform.InstanceState) {
+				if s.Attributes["filename"] != "disk.qcow2" || s.Attributes["md5sum"] == "" {
+					t.Errorf("unexpected image state: %v", s.Attributes)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMockController(t)
+			meta := m.config()
+			r := tc.resource
+			create, update := tc.setup(t, m)
+
+			// Create
+			state := applyConfig(t, r, nil, create, meta)
+			if state == nil || state.ID == "" {
+				t.Fatalf("no ID set after create")
+			}
+			if tc.checkCreate != nil {
+				tc.checkCreate(t, m, state)
+			}
+
+			// Read
+			refreshed, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+			if diags.HasError() {
+				t.Fatalf("read failed: %v", diags)
+			}
+			if refreshed == nil || refreshed.ID != state.ID {
+				t.Fatalf("read lost the resource")
+			}
+
+			// Import
+			if tc.importID != nil && r.Importer != nil {
+				d := r.Data(&terraform.InstanceState{ID: tc.importID(state)})
+				imported, err := r.Importer.StateContext(context.Background(), d, meta)
+				if err != nil {
+					t.Fatalf("import failed: %s", err)
+				}
+				if len(imported) != 1 || imported[0].Id() != state.ID {
+					t.Fatalf("import returned wrong ID, expected %q", state.ID)
+				}
+			}
+
+			// Update
+			if update != nil {
+				state = applyConfig(t, r, refreshed, update, meta)
+				if tc.checkUpdate != nil {
+					tc.checkUpdate(t, m, state)
+				}
+			}
+
+			// Delete
+			if err := destroy(r, state, meta); err != nil {
+				t.Fatalf("delete failed: %s", err)
+			}
+		})
+	}
+}
+
+func TestResourceReadRemovesMissing(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Qemu()
+	state := &terraform.InstanceState{ID: "missing", Attributes: map[string]string{"project_id": pid}}
+	refreshed, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("read failed: %v", diags)
+	}
+	if refreshed != nil {
+		t.Errorf("expected missing node to be removed from state, got %v", refreshed)
+	}
+}
+
+func TestProtectRefusesDelete(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Switch()
+	state := applyConfig(t, r, nil, map[string]interface{}{"project_id": pid, "name": "core", "protect": true}, meta)
+	if err := destroy(r, state, meta); err == nil {
+		t.Fatalf("expected protected switch to refuse deletion")
+	}
+	if m.object(nodePath(state)) == nil {
+		t.Errorf("protected switch was deleted")
+	}
+}
+
+func TestUpdateWarnsOnIgnoredAttributes(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Docker()
+	cfg := map[string]interface{}{"project_id": pid, "name": "web", "image": "nginx"}
+	state := applyConfig(t, r, nil, cfg, meta)
+
+	cfg["extra_volumes"] = []interface{}{"/data"}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	_, diags := r.Apply(context.Background(), state, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("apply failed: %v", diags)
+	}
+	if len(diags) != 1 || diags[0].Summary != `Change to "extra_volumes" has no effect` {
+		t.Errorf("expected a warning about extra_volumes, got %v", diags)
+	}
+}
+
+func TestLinkBandwidthRequiresFilter(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	a := m.addNode(pid, "a", "vpcs")
+	b := m.addNode(pid, "b", "vpcs")
+
+	r := resourceGns3Link()
+	cfg := map[string]interface{}{
+		"project_id": pid, "node_a_id": a, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": b, "node_b_adapter": 0, "node_b_port": 0, "bandwidth_kbps": 512,
+	}
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	_, diags := r.Apply(context.Background(), nil, diff, meta)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "bandwidth") {
+		t.Errorf("expected an unsupported bandwidth filter error, got %v", diags)
+	}
+}
+
+func TestAutoOffsetFansOutNodes(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Qemu()
+	var placed []string
+	for _, name := range []string{"vm0", "vm1", "vm2"} {
+		state := applyConfig(t, r, nil, map[string]interface{}{
+			"project_id":  pid,
+			"name":        name,
+			"x":           100,
+			"y":           100,
+			"auto_offset": []interface{}{map[string]interface{}{"dx": 50, "per_row": 2}},
+		}, meta)
+		placed = append(placed, state.Attributes["auto_offset.0.x"]+","+state.Attributes["auto_offset.0.y"])
+	}
+
+	want := []string{"100,100", "150,100", "100,200"}
+	for i := range want {
+		if placed[i] != want[i] {
+			t.Errorf("node %d placed at %s, want %s", i, placed[i], want[i])
+		}
+	}
+}
+
+func TestNodeDataSourceFreePorts(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	a := m.addNode(pid, "a", "vpcs")
+	b := m.addNode(pid, "b", "vpcs")
+	m.addNode(pid, "c", "vpcs")
+
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": a, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": b, "node_b_adapter": 0, "node_b_port": 0,
+	}, meta)
+
+	for name, want := range map[string]int{"a": -1, "c": 0} {
+		d := schema.TestResourceDataRaw(t, dataSourceGns3Node().Schema, map[string]interface{}{"project_id": pid, "name": name})
+		if err := dataSourceGns3NodeRead(d, meta); err != nil {
+			t.Fatalf("read %s failed: %s", name, err)
+		}
+		if got := d.Get("next_free_port").(int); got != want {
+			t.Errorf("node %s: next_free_port = %d, want %d", name, got, want)
+		}
+		if got := len(d.Get("free_ports").([]interface{})); got != want+1 {
+			t.Errorf("node %s: %d free ports, want %d", name, got, want+1)
+		}
+	}
+}
+
+func TestClientWaitsForControllerRestart(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	meta.Client.restartTimeout = 10 * time.Second
+	pid := m.addProject("lab")
+	nid := m.addNode(pid, "r1", "vpcs")
+
+	m.restart(t, 300*time.Millisecond)
+	node, err := getNode(meta, pid, nid)
+	if err != nil {
+		t.Fatalf("request during restart failed: %s", err)
+	}
+	if node == nil {
+		t.Fatalf("node %s not found after restart", nid)
+	}
+	if m.lastRequest("POST", "/v2/projects/"+pid+"/open") == nil {
+		t.Errorf("expected project %s to be re-opened after the restart", pid)
+	}
+}
+
+func TestClientRequestCorrelation(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	if _, err := getNode(meta, pid, "missing"); err != nil {
+		t.Fatalf("lookup failed: %s", err)
+	}
+	if req := m.lastRequest("GET", fmt.Sprintf("/v2/projects/%s/nodes/missing", pid)); req == nil || req.RequestID == "" {
+		t.Errorf("expected the request to carry a correlation ID, got %+v", req)
+	}
+
+	m.server.Close()
+	_, err := meta.Client.Get(fmt.Sprintf("%s/v2/projects/%s", meta.Host, pid))
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected a RequestError, got %v", err)
+	}
+	if reqErr.ID == "" || reqErr.Method != "GET" || !strings.Contains(err.Error(), reqErr.ID) {
+		t.Errorf("error does not identify the request: %s", err)
+	}
+}
+
+func TestClientFailsOverToStandbyController(t *testing.T) {
+	m := newMockController(t)
+	pid := m.addProject("lab")
+	nid := m.addNode(pid, "r1", "vpcs")
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	primary := down.URL
+	down.Close()
+
+	meta := newProviderConfig(primary, newClient("", nil))
+	meta.Client.failover = newHostFailover([]string{primary, m.server.URL + "/"})
+	node, err := getNode(meta, pid, nid)
+	if err != nil {
+		t.Fatalf("request was not failed over: %s", err)
+	}
+	if node == nil {
+		t.Fatalf("node %s not found on the standby controller", nid)
+	}
+	if meta.Client.failover.active != 1 {
+		t.Errorf("expected the standby controller to stay active")
+	}
+
+	// Writes fail over too, with their body intact
+	meta.Client.failover.active = 0
+	if err := updateNode(meta, pid, nid, map[string]interface{}{"name": "r1-renamed"}); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+	if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, nid))["name"] != "r1-renamed" {
+		t.Errorf("update did not reach the standby controller")
+	}
+
+	// A body that cannot be replayed is reported, with the controller it was for
+	meta.Client.failover.active = 0
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/v2/projects/%s/nodes/%s", primary, pid, nid), ioutil.NopCloser(strings.NewReader(`{"name":"r1"}`)))
+	if _, err := meta.Client.Do(req); err == nil || !strings.Contains(err.Error(), "cannot be rewound") || !strings.Contains(err.Error(), m.server.URL) {
+		t.Errorf("expected the rewind failure for %s, got %v", m.server.URL, err)
+	}
+}
+
+func TestDockerEnvironmentReadBack(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Docker()
+	state := applyConfig(t, r, nil, map[string]interface{}{
+		"project_id":            pid,
+		"name":                  "app",
+		"image":                 "alpine",
+		"environment":           map[string]interface{}{"B": "2", "A": "a=b,c"},
+		"sensitive_environment": map[string]interface{}{"TOKEN": "s3cret"},
+	}, meta)
+
+	node := m.object(nodePath(state))
+	props := node["properties"].(map[string]interface{})
+	if props["environment"] != "A=a=b,c\nB=2\nTOKEN=s3cret" {
+		t.Fatalf("unexpected environment sent: %q", props["environment"])
+	}
+
+	// The controller reorders the variables and someone adds one by hand
+	props["environment"] = "TOKEN=s3cret\nC=3\nB=2\nA=a=b,c"
+	d := r.Data(state)
+	if err := resourceGns3DockerRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	env := d.Get("environment").(map[string]interface{})
+	if len(env) != 3 || env["A"] != "a=b,c" || env["C"] != "3" {
+		t.Errorf("unexpected environment read back: %v", env)
+	}
+	if secrets := d.Get("sensitive_environment").(map[string]interface{}); len(secrets) != 1 || secrets["TOKEN"] != "s3cret" {
+		t.Errorf("unexpected sensitive_environment read back: %v", secrets)
+	}
+}
+
+func TestDockerRenameAndMove(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Docker()
+	cfg := map[string]interface{}{"project_id": pid, "name": "app", "image": "alpine", "x": 10, "y": 20}
+	state := applyConfig(t, r, nil, cfg, meta)
+
+	cfg = map[string]interface{}{"project_id": pid, "name": "web", "image": "alpine", "x": 300, "y": 400}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	if diff.RequiresNew() {
+		t.Fatal("renaming or moving a Docker node must not replace it")
+	}
+	newState, diags := r.Apply(context.Background(), state, diff, meta)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	node := m.object(nodePath(newState))
+	if node["name"] != "web" || jsonInt(node["x"]) != 300 || jsonInt(node["y"]) != 400 {
+		t.Errorf("name and position not sent: %v %v %v", node["name"], node["x"], node["y"])
+	}
+}
+
+func TestTopologyExport(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	a := m.addNode(pid, "b-node", "vpcs")
+	b := m.addNode(pid, "a-node", "vpcs")
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": a, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": b, "node_b_adapter": 0, "node_b_port": 0,
+	}, meta)
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3TopologyExport().Schema, map[string]interface{}{"project_id": pid})
+	if err := dataSourceGns3TopologyExportRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+
+	var export topologyExport
+	if err := json.Unmarshal([]byte(d.Get("json").(string)), &export); err != nil {
+		t.Fatalf("export is not valid JSON: %s", err)
+	}
+	if len(export.Nodes) != 2 || export.Nodes[0].Name != "a-node" {
+		t.Errorf("expected nodes sorted by name, got %+v", export.Nodes)
+	}
+	if len(export.Links) != 1 || export.Links[0].Nodes[0].Name != "b-node" {
+		t.Errorf("expected one link with named endpoints, got %+v", export.Links)
+	}
+}
+
+func TestProjectFile(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	a := m.addNode(pid, "r1", "vpcs")
+	b := m.addNode(pid, "sw1", "ethernet_switch")
+	c := m.addNode(pid, "manual", "vpcs")
+	m.object("/v2/projects/" + pid + "/nodes/" + a)["status"] = "started"
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": a, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": b, "node_b_adapter": 0, "node_b_port": 0,
+	}, meta)
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": c, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": b, "node_b_adapter": 0, "node_b_port": 1,
+	}, meta)
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3ProjectFile().Schema, map[string]interface{}{
+		"project_id": pid, "node_ids": []interface{}{a, b},
+	})
+	if err := dataSourceGns3ProjectFileRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	if d.Get("filename") != "lab.gns3" {
+		t.Errorf("unexpected filename %q", d.Get("filename"))
+	}
+
+	var file struct {
+		Type     string `json:"type"`
+		Revision int    `json:"revision"`
+		Name     string `json:"name"`
+		Topology struct {
+			Nodes    []map[string]interface{} `json:"nodes"`
+			Links    []map[string]interface{} `json:"links"`
+			Drawings []interface{}            `json:"drawings"`
+		} `json:"topology"`
+	}
+	if err := json.Unmarshal([]byte(d.Get("content").(string)), &file); err != nil {
+		t.Fatalf("project file is not valid JSON: %s", err)
+	}
+	if file.Type != "topology" || file.Revision != projectFileRevision || file.Name != "lab" {
+		t.Errorf("unexpected project file header: %+v", file)
+	}
+	if len(file.Topology.Nodes) != 2 || len(file.Topology.Links) != 1 {
+		t.Fatalf("expected the two selected nodes and the link between them, got %d nodes and %d links",
+			len(file.Topology.Nodes), len(file.Topology.Links))
+	}
+	for _, node := range file.Topology.Nodes {
+		if _, ok := node["status"]; ok {
+			t.Errorf("runtime status was written to the project file: %v", node)
+		}
+	}
+	if file.Topology.Drawings == nil {
+		t.Errorf("expected a drawings list")
+	}
+}
+
+func TestProjectHCL(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("My Lab")
+	r1 := m.addNode(pid, "R1", "qemu")
+	m.object("/v2/projects/" + pid + "/nodes/" + r1)["properties"] = map[string]interface{}{"ram": 2048, "platform": "x86_64"}
+	sw := m.addNode(pid, "sw-1", "ethernet_switch")
+	m.addNode(pid, "pc1", "vpcs")
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": r1, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": sw, "node_b_adapter": 0, "node_b_port": 3,
+	}, meta)
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3ProjectHCL().Schema, map[string]interface{}{"project_id": pid})
+	if err := dataSourceGns3ProjectHCLRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+
+	resources := d.Get("resources").(string)
+	for _, want := range []string{
+		`resource "gns3_project" "my_lab" {`,
+		`resource "gns3_qemu_node" "r1" {`,
+		`  ram        = 2048`,
+		`resource "gns3_switch" "sw_1" {`,
+		`resource "gns3_link" "r1_to_sw_1" {`,
+		`  node_b_id      = gns3_switch.sw_1.id`,
+		`  node_b_port    = 3`,
+	} {
+		if !strings.Contains(resources, want) {
+			t.Errorf("expected %q in the generated resources:\n%s", want, resources)
+		}
+	}
+	imports := d.Get("imports").(string)
+	if !strings.Contains(imports, fmt.Sprintf("  id = %q", pid+"/"+r1)) || strings.Count(imports, "import {") != 4 {
+		t.Errorf("unexpected import blocks:\n%s", imports)
+	}
+	if unsupported := d.Get("unsupported_nodes").([]interface{}); len(unsupported) != 1 || unsupported[0] != "pc1" {
+		t.Errorf("expected pc1 to be reported as unsupported, got %v", unsupported)
+	}
+}
+
+func TestProjectHCLImportPlansNoChanges(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	app := m.addNode(pid, "app", "docker")
+	node := m.object("/v2/projects/" + pid + "/nodes/" + app)
+	node["properties"] = map[string]interface{}{"image": "alpine:latest", "console_http_port": 80, "console_http_path": "/"}
+	node["x"], node["y"], node["console_type"] = 120, -40, "telnet"
+	r1 := m.addNode(pid, "r1", "dynamips")
+	m.object("/v2/projects/" + pid + "/nodes/" + r1)["template_id"] = "tmpl-router"
+
+	for id, r := range map[string]*schema.Resource{app: resourceGns3Docker(), r1: resourceGns3Template()} {
+		_, attrs := hclNodeAttributes(m.object("/v2/projects/"+pid+"/nodes/"+id), "")
+		cfg := map[string]interface{}{"project_id": pid}
+		for _, attr := range attrs[1:] {
+			if v, err := strconv.Unquote(attr.Value); err == nil {
+				cfg[attr.Key] = v
+			} else if v, err := strconv.Atoi(attr.Value); err == nil {
+				cfg[attr.Key] = v
+			}
+		}
+
+		d := r.Data(&terraform.InstanceState{ID: pid + "/" + id})
+		imported, err := r.Importer.StateContext(context.Background(), d, meta)
+		if err != nil {
+			t.Fatalf("import of %s failed: %s", id, err)
+		}
+		refreshed, diags := r.RefreshWithoutUpgrade(context.Background(), imported[0].State(), meta)
+		if diags.HasError() {
+			t.Fatalf("refresh of %s failed: %v", id, diags)
+		}
+		diff, err := r.Diff(context.Background(), refreshed, terraform.NewResourceConfigRaw(cfg), meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff != nil && len(diff.Attributes) > 0 {
+			for k, a := range diff.Attributes {
+				t.Errorf("%s: unexpected change of %s after import: %q => %q (replace: %v)", id, k, a.Old, a.New, a.RequiresNew)
+			}
+		}
+	}
+
+	// GNS3 reports an untagged image with its latest tag
+	r := resourceGns3Docker()
+	cfg := map[string]interface{}{"project_id": pid, "name": "web", "image": "nginx"}
+	state := applyConfig(t, r, nil, cfg, meta)
+	m.object(nodePath(state))["properties"].(map[string]interface{})["image"] = "nginx:latest"
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != nil && diff.RequiresNew() {
+		t.Errorf("expected the latest tag not to replace the node, got %v", diff.Attributes)
+	}
+}
+
+func TestSkipStartSuppressesStart(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	meta.SkipStart = true
+	pid := m.addProject("lab")
+
+	state := applyConfig(t, resourceGns3Template(), nil, map[string]interface{}{
+		"project_id": pid, "template_id": "tmpl-router", "name": "r1", "start": true,
+	}, meta)
+	if m.lastRequest("POST", nodePath(state)+"/start") != nil {
+		t.Errorf("node was started although skip_start is set")
+	}
+}
+
+func TestAutoStartNodes(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	on, off := true, false
+
+	// create applies a node with start as written in the configuration
+	create := func(r *schema.Resource, raw map[string]interface{}, start cty.Value) *terraform.InstanceState {
+		raw["project_id"] = pid
+		if !start.IsNull() {
+			raw["start"] = start.True()
+		}
+		diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+		if err != nil {
+			t.Fatalf("diff failed: %s", err)
+		}
+		diff.RawConfig = cty.ObjectVal(map[string]cty.Value{"start": start})
+		state, diags := r.Apply(context.Background(), nil, diff, meta)
+		if diags.HasError() {
+			t.Fatalf("apply failed: %v", diags)
+		}
+		return state
+	}
+	template := func(name string) map[string]interface{} {
+		return map[string]interface{}{"template_id": "tmpl-router", "name": name}
+	}
+	docker := func(name string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "image": "alpine"}
+	}
+	cases := []struct {
+		name      string
+		autoStart *bool
+		r         *schema.Resource
+		raw       map[string]interface{}
+		start     cty.Value
+		started   bool
+	}{
+		{"template without flag", &on, resourceGns3Template(), template("r1"), cty.NullVal(cty.Bool), true},
+		{"template with false flag", &on, resourceGns3Template(), template("r2"), cty.False, false},
+		{"template keeps its default", nil, resourceGns3Template(), template("r3"), cty.NullVal(cty.Bool), false},
+		{"docker keeps its default", nil, resourceGns3Docker(), docker("c1"), cty.NullVal(cty.Bool), true},
+		{"docker without flag", &off, resourceGns3Docker(), docker("c2"), cty.NullVal(cty.Bool), false},
+		{"docker with true flag", &off, resourceGns3Docker(), docker("c3"), cty.True, true},
+	}
+	for _, tc := range cases {
+		meta.AutoStartNodes = tc.autoStart
+		state := create(tc.r, tc.raw, tc.start)
+		if started := m.lastRequest("POST", nodePath(state)+"/start") != nil; started != tc.started {
+			t.Errorf("%s: expected started %v, got %v", tc.name, tc.started, started)
+		}
+	}
+}
+
+func TestStartStagger(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	meta.StartStagger = 100 * time.Millisecond
+	pid := m.addProject("lab")
+	var nodes []string
+	for _, name := range []string{"a", "b", "c"} {
+		nodes = append(nodes, m.addNode(pid, name, "vpcs"))
+	}
+
+	begin := time.Now()
+	applyConfig(t, resourceGns3StartAll(), nil, map[string]interface{}{"project_id": pid}, meta)
+	if elapsed := time.Since(begin); elapsed < 200*time.Millisecond {
+		t.Errorf("expected three starts to take at least two stagger intervals, took %s", elapsed)
+	}
+	if m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes/start", pid)) != nil {
+		t.Errorf("expected the nodes to be started one by one")
+	}
+	for _, id := range nodes {
+		if m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes/%s/start", pid, id)) == nil {
+			t.Errorf("node %s was not started", id)
+		}
+	}
+}
+
+func TestStartCrashDetection(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	meta.StartCheck = 50 * time.Millisecond
+	startCheckInterval = 10 * time.Millisecond
+	defer func() { startCheckInterval = time.Second }()
+	pid := m.addProject("lab")
+	nid := m.addNode(pid, "r1", "qemu")
+	path := fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, nid)
+	m.files[path+"/files/qemu.log"] = []byte("booting\nqemu-system-x86_64: -drive: Could not open 'hda.qcow2'\n")
+
+	m.crashes[nid] = 1
+	err := nodeAction(meta, pid, nid, "start")
+	if err == nil || !strings.Contains(err.Error(), "crashing on boot") || !strings.Contains(err.Error(), "Could not open 'hda.qcow2'") {
+		t.Fatalf("expected the start to fail with the end of the node log, got %v", err)
+	}
+
+	// A node crashing once is started again with start_retries
+	meta.StartRetries = 1
+	m.crashes[nid] = 1
+	if err := nodeAction(meta, pid, nid, "start"); err != nil {
+		t.Fatalf("expected the retried start to succeed: %s", err)
+	}
+	starts := 0
+	for _, req := range m.requests {
+		if req.Method == "POST" && req.Path == path+"/start" {
+			starts++
+		}
+	}
+	if starts != 3 {
+		t.Errorf("expected 3 start requests, got %d", starts)
+	}
+	if m.object(path)["status"] != "started" {
+		t.Errorf("expected the node to be started")
+	}
+}
+
+func TestNodeLogDataSource(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	qemu := m.addNode(pid, "r1", "qemu")
+	docker := m.addNode(pid, "c1", "docker")
+	m.files[fmt.Sprintf("/v2/projects/%s/nodes/%s/files/qemu.log", pid, qemu)] = []byte("line 1\nline 2\nline 3\n")
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3NodeLog().Schema, map[string]interface{}{
+		"project_id": pid, "node_id": qemu, "lines": 2,
+	})
+	if err := dataSourceGns3NodeLogRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	if d.Get("file") != "qemu.log" || !d.Get("available").(bool) || d.Get("content") != "line 2\nline 3" {
+		t.Errorf("unexpected log %q in %v (available %v)", d.Get("content"), d.Get("file"), d.Get("available"))
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceGns3NodeLog().Schema, map[string]interface{}{
+		"project_id": pid, "node_id": docker,
+	})
+	if err := dataSourceGns3NodeLogRead(d, meta); err != nil {
+		t.Fatalf("read of a node without log failed: %s", err)
+	}
+	if d.Get("available").(bool) || d.Get("content") != "" {
+		t.Errorf("expected no log for a Docker node")
+	}
+}
+
+func TestPortsUsageDataSource(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	sw := m.addNode(pid, "sw", "ethernet_switch")
+	r1 := m.addNode(pid, "r1", "vpcs")
+	m.addNode(pid, "r2", "vpcs")
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": r1, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": sw, "node_b_adapter": 0, "node_b_port": 3,
+	}, meta)
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3PortsUsage().Schema, map[string]interface{}{"project_id": pid})
+	if err := dataSourceGns3PortsUsageRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	want := []struct {
+		name                 string
+		total, used, percent int
+	}{{"r1", 1, 1, 100}, {"r2", 1, 0, 0}, {"sw", 8, 1, 12}}
+	nodes := d.Get("nodes").([]interface{})
+	if len(nodes) != len(want) {
+		t.Fatalf("expected %d nodes, got %v", len(want), nodes)
+	}
+	for i, w := range want {
+		node := nodes[i].(map[string]interface{})
+		if node["name"] != w.name || node["total"] != w.total || node["used"] != w.used || node["free"] != w.total-w.used || node["usage_percent"] != w.percent {
+			t.Errorf("expected %s to use %d of %d ports (%d%%), got %v", w.name, w.used, w.total, w.percent, node)
+		}
+	}
+	if free := d.Get("free").(map[string]interface{}); free["sw"] != 7 || free["r1"] != 0 {
+		t.Errorf("unexpected free port counts %v", free)
+	}
+}
+
+func TestTemplatePropertyOverrides(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	m.templates[0]["ram"] = 1024
+	m.templates[0]["adapters"] = 2
+
+	r := resourceGns3Template()
+	cfg := map[string]interface{}{
+		"project_id": pid, "template_id": "tmpl-router", "name": "r1",
+		"property_overrides": map[string]interface{}{"ram": "2048", "adapters": "4", "console_type": "vnc"},
+	}
+	state := applyConfig(t, r, nil, cfg, meta)
+	node := m.object(nodePath(state))
+	props := node["properties"].(map[string]interface{})
+	if jsonInt(props["ram"]) != 2048 || jsonInt(props["adapters"]) != 4 || node["console_type"] != "vnc" {
+		t.Errorf("expected the overrides to be applied: %v", node)
+	}
+	if state.Attributes["property_overrides.ram"] != "2048" {
+		t.Errorf("expected overrides read back, got %v", state.Attributes)
+	}
+
+	// Drift made in the GUI is planned back
+	props["ram"] = 512
+	state, err := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Attributes["property_overrides.ram"] != "512" {
+		t.Errorf("expected the changed RAM to show up as drift, got %q", state.Attributes["property_overrides.ram"])
+	}
+
+	m.object(nodePath(state))["status"] = "started"
+	cfg["property_overrides"] = map[string]interface{}{"adapters": "4", "console_type": "vnc"}
+	state = applyConfig(t, r, state, cfg, meta)
+	if jsonInt(props["ram"]) != 1024 {
+		t.Errorf("expected the template's RAM back after removing the override, got %v", props["ram"])
+	}
+	if m.lastRequest("POST", nodePath(state)+"/stop") == nil || m.lastRequest("POST", nodePath(state)+"/start") == nil {
+		t.Errorf("expected the running node to be restarted for the new settings")
+	}
+}
+
+func TestClientOpensClosedProject(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	m.object("/v2/projects/" + pid)["status"] = "closed"
+
+	state := applyConfig(t, resourceGns3Switch(), nil, map[string]interface{}{"project_id": pid, "name": "sw1"}, meta)
+	if state.ID == "" {
+		t.Fatalf("switch was not created in the closed project")
+	}
+	if m.object("/v2/projects/" + pid)["status"] != "opened" {
+		t.Errorf("expected project %s to be opened", pid)
+	}
+
+	// Refreshing must not open a project that was closed on purpose
+	m.object("/v2/projects/" + pid)["status"] = "closed"
+	if err := resourceGns3SwitchRead(resourceGns3Switch().Data(state), meta); err == nil {
+		t.Errorf("expected reading a node of the closed project to fail")
+	}
+	if m.object("/v2/projects/" + pid)["status"] != "closed" {
+		t.Errorf("refresh opened project %s", pid)
+	}
+}
+
+func TestComputeInterfaces(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3ComputeInterfaces().Schema, map[string]interface{}{})
+	if err := dataSourceGns3ComputeInterfacesRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	names := d.Get("names").([]interface{})
+	if len(names) != 2 || names[0] != "eth0" || names[1] != "eth1" {
+		t.Errorf("expected sorted non-special interfaces, got %v", names)
+	}
+	if ip := d.Get("interfaces.1.ip_address"); ip != "10.0.0.2" {
+		t.Errorf("unexpected ip_address for eth1: %v", ip)
+	}
+}
+
+func TestCanvasWarnsOutsideScene(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Switch()
+	for x, want := range map[int]int{900: 0, 1500: 1} {
+		cfg := map[string]interface{}{"project_id": pid, "name": fmt.Sprintf("sw%d", x), "x": x}
+		diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(cfg), meta)
+		if err != nil {
+			t.Fatalf("diff failed: %s", err)
+		}
+		_, diags := r.Apply(context.Background(), nil, diff, meta)
+		if diags.HasError() {
+			t.Fatalf("apply failed: %v", diags)
+		}
+		if len(diags) != want {
+			t.Errorf("x=%d: expected %d warnings, got %v", x, want, diags)
+		}
+	}
+}
+
+func TestDefaultLabelsInUsage(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	meta.DefaultLabels = map[string]string{"owner": "netops", "tf-managed": "true"}
+	pid := m.addProject("lab")
+
+	r := resourceGns3Docker()
+	state := applyConfig(t, r, nil, map[string]interface{}{
+		"project_id": pid, "name": "web", "image": "nginx", "usage": "port 80",
+	}, meta)
+
+	props := m.object(nodePath(state))["properties"].(map[string]interface{})
+	if props["usage"] != "port 80\n\n[managed by terraform]\nowner: netops\ntf-managed: true" {
+		t.Errorf("unexpected usage sent: %q", props["usage"])
+	}
+	if state.Attributes["usage"] != "port 80" {
+		t.Errorf("labels should not be read back into usage, got %q", state.Attributes["usage"])
+	}
+}
+
+func TestLinkEndpointValidation(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	r1 := m.addNode(pid, "r1", "iou")
+	m.object("/v2/projects/" + pid + "/nodes/" + r1)["ports"] = []interface{}{
+		map[string]interface{}{"name": "Ethernet0/0", "adapter_number": 0, "port_number": 0, "link_type": "ethernet"},
+		map[string]interface{}{"name": "Serial1/0", "adapter_number": 1, "port_number": 0, "link_type": "serial"},
+	}
+	sw := m.addNode(pid, "sw1", "ethernet_switch")
+	applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": r1, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": sw, "node_b_adapter": 0, "node_b_port": 0,
+	}, meta)
+
+	r := resourceGns3Link()
+	for _, tc := range []struct {
+		name    string
+		a, b    []int
+		wantErr string
+	}{
+		{"serial to ethernet", []int{1, 0}, []int{0, 1}, `cannot link node "r1" port Serial1/0 (serial) to node "sw1" port Ethernet1 (ethernet)`},
+		{"occupied port", []int{0, 0}, []int{0, 1}, `node "r1" port Ethernet0/0 is already used by link`},
+		{"missing port", []int{2, 0}, []int{0, 1}, `node "r1" has no port 0 on adapter 2`},
+	} {
+		_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"project_id": pid, "node_a_id": r1, "node_a_adapter": tc.a[0], "node_a_port": tc.a[1],
+			"node_b_id": sw, "node_b_adapter": tc.b[0], "node_b_port": tc.b[1],
+		}), meta)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: expected a plan error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+	if links := m.children("/v2/projects/" + pid + "/links"); len(links) != 1 {
+		t.Errorf("expected no link to be created, got %d links", len(links))
+	}
+}
+
+func TestLinkImportReadsEndpoints(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	a := m.addNode(pid, "r1", "vpcs")
+	b := m.addNode(pid, "sw1", "ethernet_switch")
+
+	r := resourceGns3Link()
+	created := applyConfig(t, r, nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": a, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": b, "node_b_adapter": 0, "node_b_port": 3,
+	}, meta)
+
+	d := r.Data(&terraform.InstanceState{ID: pid + "/" + created.ID})
+	imported, err := r.Importer.StateContext(context.Background(), d, meta)
+	if err != nil {
+		t.Fatalf("import failed: %s", err)
+	}
+	if err := resourceGns3LinkRead(imported[0], meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	got := imported[0]
+	if got.Get("node_a_id") != a || got.Get("node_b_id") != b || got.Get("node_b_port") != 3 || got.Get("node_b_name") != "sw1" {
+		t.Errorf("imported link endpoints not read back: %v", got.State().Attributes)
+	}
+}
+
+func TestLinkAutoPort(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	hub := m.addNode(pid, "sw1", "ethernet_switch")
+	var ports []interface{}
+	for i := 0; i < 3; i++ {
+		ports = append(ports, map[string]interface{}{"name": fmt.Sprintf("Ethernet%d", i), "short_name": fmt.Sprintf("e%d", i), "adapter_number": 0, "port_number": i, "link_type": "ethernet"})
+	}
+	m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, hub))["ports"] = ports
+
+	r := resourceGns3Link()
+	for i, spoke := range []string{"pc1", "pc2"} {
+		state := applyConfig(t, r, nil, map[string]interface{}{
+			"project_id": pid, "node_a_id": hub, "node_a_auto_port": true,
+			"node_b_id": m.addNode(pid, spoke, "vpcs"), "node_b_auto_port": true,
+		}, meta)
+		if got := state.Attributes["node_a_port"]; got != fmt.Sprint(i) {
+			t.Errorf("link %d: expected hub port %d, got %s", i, i, got)
+		}
+	}
+}
+
+func TestLinkSetStarReconciles(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	hub := m.addNode(pid, "sw1", "ethernet_switch")
+	var ports []interface{}
+	for i := 0; i < 3; i++ {
+		ports = append(ports, map[string]interface{}{"name": fmt.Sprintf("Ethernet%d", i), "short_name": fmt.Sprintf("e%d", i), "adapter_number": 0, "port_number": i, "link_type": "ethernet"})
+	}
+	m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, hub))["ports"] = ports
+	pc1 := m.addNode(pid, "pc1", "vpcs")
+	pc2 := m.addNode(pid, "pc2", "vpcs")
+	pc3 := m.addNode(pid, "pc3", "vpcs")
+
+	r := resourceGns3LinkSet()
+	state := applyConfig(t, r, nil, map[string]interface{}{
+		"project_id": pid, "topology": "star", "hub_node_id": hub, "node_ids": []interface{}{pc1, pc2},
+	}, meta)
+	if state.Attributes["links.#"] != "2" {
+		t.Fatalf("expected 2 links, got %v", state.Attributes)
+	}
+	if got := state.Attributes["links.1.node_a_port"]; got != "1" {
+		t.Errorf("expected the second link on hub port 1, got %s", got)
+	}
+	kept := state.Attributes["links.0.link_id"]
+	dropped := state.Attributes["links.1.link_id"]
+
+	state = applyConfig(t, r, state, map[string]interface{}{
+		"project_id": pid, "topology": "star", "hub_node_id": hub, "node_ids": []interface{}{pc1, pc3},
+	}, meta)
+	if state.Attributes["links.#"] != "2" || state.Attributes["links.0.link_id"] != kept {
+		t.Fatalf("expected the pc1 link to be kept, got %v", state.Attributes)
+	}
+	if m.object(fmt.Sprintf("/v2/projects/%s/links/%s", pid, dropped)) != nil {
+		t.Errorf("link to pc2 was not deleted")
+	}
+	if got := state.Attributes["links.1.node_b_id"]; got != pc3 {
+		t.Errorf("expected a link to pc3, got %s", got)
+	}
+
+	if err := destroy(r, state, meta); err != nil {
+		t.Fatal(err)
+	}
+	links, _ := listProjectLinks(meta, pid)
+	if len(links) != 0 {
+		t.Errorf("expected every link deleted, got %v", links)
+	}
+}
+
+func TestTracingExportsSpans(t *testing.T) {
+	var mu sync.Mutex
+	var spans []map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []map[string]interface{} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range payload.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+
+	m := newMockController(t)
+	meta := m.config()
+	meta.Client.tracer = newTracerFromEnv()
+	pid := m.addProject("lab")
+
+	applyConfig(t, Provider().ResourcesMap["gns3_switch"], nil, map[string]interface{}{"project_id": pid, "name": "sw1"}, meta)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var root map[string]interface{}
+	for _, s := range spans {
+		if s["name"] == "gns3_switch create" {
+			root = s
+		}
+	}
+	if root == nil {
+		t.Fatalf("no span for the create operation, got %v", spans)
+	}
+	children := 0
+	for _, s := range spans {
+		if s["parentSpanId"] == root["spanId"] && s["traceId"] == root["traceId"] && int(s["kind"].(float64)) == spanKindClient {
+			children++
+		}
+	}
+	if children == 0 {
+		t.Errorf("expected controller calls under the create span, got %v", spans)
+	}
+}
+
+func TestQemuDisconnectedAdapters(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	pc := m.addNode(pid, "pc1", "vpcs")
+
+	r := resourceGns3Qemu()
+	cfg := map[string]interface{}{"project_id": pid, "name": "r1", "adapters": 2}
+	state := applyConfig(t, r, nil, cfg, meta)
+	linkID, err := createLink(meta, pid, LinkNode{NodeID: state.ID, AdapterNumber: 1}, LinkNode{NodeID: pc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkPath := fmt.Sprintf("/v2/projects/%s/links/%s", pid, linkID)
+
+	cfg["disconnected_adapters"] = []interface{}{1}
+	state = applyConfig(t, r, state, cfg, meta)
+	if m.object(linkPath)["suspend"] != true {
+		t.Errorf("expected the link on adapter 1 to be suspended: %v", m.object(linkPath))
+	}
+	if state.Attributes["disconnected_adapters.#"] != "1" {
+		t.Errorf("expected disconnected adapters read back, got %v", state.Attributes)
+	}
+	if m.lastRequest("POST", nodePath(state)+"/stop") != nil {
+		t.Errorf("disconnecting a cable should not stop the VM")
+	}
+
+	delete(cfg, "disconnected_adapters")
+	applyConfig(t, r, state, cfg, meta)
+	if m.object(linkPath)["suspend"] != false {
+		t.Errorf("expected the link to be reconnected: %v", m.object(linkPath))
+	}
+}
+
+func TestQemuDisconnectedAdaptersSharedLink(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Qemu()
+	cfg1 := map[string]interface{}{"project_id": pid, "name": "r1", "adapters": 2}
+	cfg2 := map[string]interface{}{"project_id": pid, "name": "r2", "adapters": 2}
+	r1 := applyConfig(t, r, nil, cfg1, meta)
+	r2 := applyConfig(t, r, nil, cfg2, meta)
+	linkID, err := createLink(meta, pid, LinkNode{NodeID: r1.ID, AdapterNumber: 1}, LinkNode{NodeID: r2.ID, AdapterNumber: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkPath := fmt.Sprintf("/v2/projects/%s/links/%s", pid, linkID)
+
+	cfg1["disconnected_adapters"] = []interface{}{1}
+	r1 = applyConfig(t, r, r1, cfg1, meta)
+	if m.object(linkPath)["suspend"] != true {
+		t.Fatalf("expected the link to be suspended: %v", m.object(linkPath))
+	}
+
+	// r2 leaves the attribute out, so it neither reports nor resumes the link
+	r2, diags := r.RefreshWithoutUpgrade(context.Background(), r2, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if n := r2.Attributes["disconnected_adapters.#"]; n != "" && n != "0" {
+		t.Errorf("r2 reported the link r1 suspended: %v", r2.Attributes)
+	}
+	diff, err := r.Diff(context.Background(), r2, terraform.NewResourceConfigRaw(cfg2), meta)
+	if err != nil {
+		t.Fatalf("diff failed: %s", err)
+	}
+	if diff != nil && len(diff.Attributes) > 0 {
+		t.Errorf("expected no changes for r2, got %v", diff.Attributes)
+	}
+	cfg2["name"] = "r2-renamed"
+	applyConfig(t, r, r2, cfg2, meta)
+	if m.object(linkPath)["suspend"] != true {
+		t.Errorf("updating r2 resumed the link r1 suspended: %v", m.object(linkPath))
+	}
+
+	// r1 still notices the link being resumed behind its back
+	m.object(linkPath)["suspend"] = false
+	r1, diags = r.RefreshWithoutUpgrade(context.Background(), r1, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if r1.Attributes["disconnected_adapters.#"] != "0" {
+		t.Errorf("expected r1 to read back the resumed link, got %v", r1.Attributes)
+	}
+}
+
+func TestQemuManagementNetwork(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Qemu()
+	cfg := map[string]interface{}{"project_id": pid, "name": "r1", "adapters": 2, "management_network": true}
+	state := applyConfig(t, r, nil, cfg, meta)
+
+	props := m.object(nodePath(state))["properties"].(map[string]interface{})
+	if jsonInt(props["adapters"]) != 3 {
+		t.Errorf("expected a third adapter for management, got %v", props["adapters"])
+	}
+	natID := state.Attributes["management_nat_node_id"]
+	nat := m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, natID))
+	if nat == nil || nat["node_type"] != "nat" {
+		t.Fatalf("expected a NAT node, got %v", nat)
+	}
+	link := m.object(fmt.Sprintf("/v2/projects/%s/links/%s", pid, state.Attributes["management_link_id"]))
+	if link == nil {
+		t.Fatalf("expected a link to the NAT node")
+	}
+	if ends := link["nodes"].([]interface{}); jsonInt(ends[0].(map[string]interface{})["adapter_number"]) != 2 {
+		t.Errorf("expected the NAT node linked to adapter 2: %v", ends)
+	}
+	if mac := state.Attributes["management_mac_address"]; mac == "" || mac != state.Attributes["ports.2.mac_address"] {
+		t.Errorf("expected the MAC address of adapter 2, got %q", mac)
+	}
+
+	cfg["management_network"] = false
+	state = applyConfig(t, r, state, cfg, meta)
+	if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, natID)) != nil {
+		t.Errorf("expected the NAT node to be removed")
+	}
+	props = m.object(nodePath(state))["properties"].(map[string]interface{})
+	if jsonInt(props["adapters"]) != 2 || state.Attributes["management_nat_node_id"] != "" {
+		t.Errorf("expected the management adapter to be removed: %v, %v", props["adapters"], state.Attributes)
+	}
+}
+
+func TestQemuDiskSizeIncrease(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Qemu()
+	cfg := map[string]interface{}{
+		"project_id": pid, "name": "r1", "hda_disk_image": "vyos.qcow2", "hda_disk_size_increase_mb": 1024, "start_vm": true,
+	}
+	state := applyConfig(t, r, nil, cfg, meta)
+
+	resizePath := fmt.Sprintf("/v2/compute/projects/%s/qemu/nodes/%s/resize_disk", pid, state.ID)
+	resize := m.lastRequest("POST", resizePath)
+	if resize == nil || resize.Body["drive_name"] != "hda" || jsonInt(resize.Body["extend"]) != 1024 {
+		t.Fatalf("expected the disk to grow by 1024 MB before the start, got %v", resize)
+	}
+	if m.object(nodePath(state))["status"] != "started" {
+		t.Errorf("expected the node to be started after the resize")
+	}
+
+	// Growing it further stops the running node and extends by the difference
+	cfg["hda_disk_size_increase_mb"] = 3072
+	state = applyConfig(t, r, state, cfg, meta)
+	if resize = m.lastRequest("POST", resizePath); jsonInt(resize.Body["extend"]) != 2048 {
+		t.Errorf("expected the disk to grow by 2048 MB, got %v", resize.Body)
+	}
+	if m.lastRequest("POST", nodePath(state)+"/stop") == nil || m.object(nodePath(state))["status"] != "started" {
+		t.Errorf("expected the node to be stopped for the resize and started again")
+	}
+
+	cfg["hda_disk_size_increase_mb"] = 1024
+	if _, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta); err == nil || !strings.Contains(err.Error(), "disks can only grow") {
+		t.Errorf("expected shrinking the disk to fail at plan time, got %v", err)
+	}
+	delete(cfg, "hda_disk_image")
+	if _, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(cfg), meta); err == nil || !strings.Contains(err.Error(), "needs an hda_disk_image") {
+		t.Errorf("expected an increase without a disk to fail at plan time, got %v", err)
+	}
+}
+
+func TestQemuNodeIDPreallocation(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Qemu()
+	nodeID := "0b6e7d4c-3f1a-4c2e-9d8b-5a7f6e4d3c21"
+	cfg := map[string]interface{}{"project_id": pid, "name": "r1", "node_id": nodeID}
+	state := applyConfig(t, r, nil, cfg, meta)
+	if state.ID != nodeID || state.Attributes["node_id"] != nodeID {
+		t.Fatalf("expected the node to be created as %s, got ID %s and node_id %s", nodeID, state.ID, state.Attributes["node_id"])
+	}
+	if m.object(nodePath(state)) == nil {
+		t.Errorf("expected the controller to keep the pre-allocated node_id")
+	}
+
+	// Without node_id, the one the controller assigns is read back
+	other := applyConfig(t, r, nil, map[string]interface{}{"project_id": pid, "name": "r2"}, meta)
+	if other.Attributes["node_id"] != other.ID {
+		t.Errorf("expected node_id to be read back as %s, got %s", other.ID, other.Attributes["node_id"])
+	}
+
+	cfg["node_id"] = "not-a-uuid"
+	if diags := r.Validate(terraform.NewResourceConfigRaw(cfg)); !diags.HasError() {
+		t.Errorf("expected an invalid node_id to fail validation")
+	}
+	cfg["node_id"] = "5c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f"
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == nil || !diff.RequiresNew() {
+		t.Errorf("expected a new node_id to replace the node")
+	}
+}
+
+func TestReplaceImmutableAttributes(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	m.computes = append(m.computes, map[string]interface{}{"compute_id": "vm", "name": "GNS3 VM", "connected": true})
+	pid := m.addProject("lab")
+	other := m.addProject("other")
+
+	cases := []struct {
+		name    string
+		r       *schema.Resource
+		cfg     map[string]interface{}
+		changes map[string]interface{}
+	}{
+		{"docker compute", resourceGns3Docker(), map[string]interface{}{"project_id": pid, "name": "c1", "image": "alpine"}, map[string]interface{}{"compute_id": "vm"}},
+		{"cloud compute", resourceGns3Cloud(), map[string]interface{}{"project_id": pid, "name": "cloud1"}, map[string]interface{}{"compute_id": "vm"}},
+		{"switch compute", resourceGns3Switch(), map[string]interface{}{"project_id": pid, "name": "sw1"}, map[string]interface{}{"compute_id": "vm"}},
+		{"template compute", resourceGns3Template(), map[string]interface{}{"project_id": pid, "template_id": "tmpl-router", "name": "r1"}, map[string]interface{}{"compute_id": "vm"}},
+		{"qemu platform", resourceGns3Qemu(), map[string]interface{}{"project_id": pid, "name": "q1", "platform": "x86_64"}, map[string]interface{}{"platform": "aarch64"}},
+		{"qemu project", resourceGns3Qemu(), map[string]interface{}{"project_id": pid, "name": "q2"}, map[string]interface{}{"project_id": other}},
+		{"template linked_clone", resourceGns3Template(), map[string]interface{}{"project_id": pid, "template_id": "tmpl-router", "name": "r2"},
+			map[string]interface{}{"property_overrides": map[string]interface{}{"linked_clone": "false"}}},
+	}
+	for _, tc := range cases {
+		state := applyConfig(t, tc.r, nil, tc.cfg, meta)
+		changed := map[string]interface{}{}
+		for k, v := range tc.cfg {
+			changed[k] = v
+		}
+		for k, v := range tc.changes {
+			changed[k] = v
+		}
+		diff, err := tc.r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(changed), meta)
+		if err != nil {
+			t.Fatalf("%s: diff failed: %s", tc.name, err)
+		}
+		if diff == nil || !diff.RequiresNew() {
+			t.Errorf("%s: expected the node to be replaced", tc.name)
+		}
+	}
+
+	// Overrides the controller applies in place do not replace the node
+	r := resourceGns3Template()
+	cfg := map[string]interface{}{"project_id": pid, "template_id": "tmpl-router", "name": "r3"}
+	state := applyConfig(t, r, nil, cfg, meta)
+	cfg["property_overrides"] = map[string]interface{}{"ram": "2048"}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil || diff == nil || diff.RequiresNew() {
+		t.Errorf("expected a ram override to be updated in place, got %v (%v)", diff, err)
+	}
+}
+
+func TestBuiltinNodeSymbolAndStatus(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	for _, r := range []*schema.Resource{resourceGns3Switch(), resourceGns3Cloud()} {
+		cfg := map[string]interface{}{"project_id": pid, "name": "edge", "x": 100}
+		state := applyConfig(t, r, nil, cfg, meta)
+		if state.Attributes["status"] != "started" || !strings.HasPrefix(state.Attributes["symbol"], ":/symbols/") {
+			t.Errorf("expected the status and default symbol to be read back, got %v", state.Attributes)
+		}
+
+		cfg["name"] = "edge-renamed"
+		cfg["symbol"] = ":/symbols/firewall.svg"
+		state = applyConfig(t, r, state, cfg, meta)
+		node := m.object(nodePath(state))
+		label := node["label"].(map[string]interface{})
+		if node["symbol"] != ":/symbols/firewall.svg" || label["text"] != "edge-renamed" || label["x"] != nil || label["style"] != "font-size: 10.0;" {
+			t.Errorf("expected the symbol and a recentered label with the new name, got %v, %v", node["symbol"], label)
+		}
+
+		// A node moved in the GUI shows up as drift
+		node["x"] = 250
+		state, err := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+		if err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+		if state.Attributes["x"] != "250" || state.Attributes["name"] != "edge-renamed" {
+			t.Errorf("expected the position and name to be read back, got %v", state.Attributes)
+		}
+	}
+}
+
+func TestProjectLock(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	meta.Client.locks = newProjectLocks("error")
+	pid := m.addProject("lab")
+	gui := m.addNode(pid, "gui-node", "vpcs")
+	r := resourceGns3Switch()
+
+	apply := func(meta *ProviderConfig, state *terraform.InstanceState, cfg map[string]interface{}) (*terraform.InstanceState, error) {
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(cfg), meta)
+		if err != nil {
+			return nil, err
+		}
+		newState, diags := r.Apply(context.Background(), state, diff, meta)
+		if diags.HasError() {
+			return nil, fmt.Errorf("%v", diags)
+		}
+		return newState, nil
+	}
+
+	cfg := map[string]interface{}{"project_id": pid, "name": "sw1"}
+	state, err := apply(meta, nil, cfg)
+	if err != nil {
+		t.Fatalf("apply failed: %s", err)
+	}
+	var holder projectLockInfo
+	if err := json.Unmarshal(m.files[fmt.Sprintf("/v2/projects/%s/files/%s", pid, projectLockFile)], &holder); err != nil || holder.Owner != meta.Client.locks.owner {
+		t.Fatalf("expected the project to be locked by this run, got %+v (%v)", holder, err)
+	}
+
+	// Changes of this run are not mistaken for another writer's
+	cfg["name"] = "sw1-renamed"
+	if state, err = apply(meta, state, cfg); err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+
+	// Another run is told the project is locked
+	other := m.config()
+	other.Client.locks = newProjectLocks("error")
+	if _, err := apply(other, nil, map[string]interface{}{"project_id": pid, "name": "sw2"}); err == nil || !strings.Contains(err.Error(), "is being changed by "+holder.Owner) {
+		t.Errorf("expected the other run to be refused, got %v", err)
+	}
+
+	// A node moved in the GUI meanwhile fails the next change
+	m.mu.Lock()
+	m.objects[fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, gui)]["x"] = 300
+	m.mu.Unlock()
+	cfg["name"] = "sw1"
+	if _, err := apply(meta, state, cfg); err == nil || !strings.Contains(err.Error(), "node gui-node was changed") {
+		t.Errorf("expected the change of another writer to be reported, got %v", err)
+	}
+}
+
+func TestComputeCapabilities(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	m.computes[0]["capabilities"] = map[string]interface{}{
+		"version": "2.2.44", "platform": "linux", "cpus": 8, "memory": 16 * 1024 * 1024 * 1024,
+		"node_types": []interface{}{"qemu", "docker", "vpcs", "ethernet_switch"},
+	}
+	m.computeData["/v2/computes/local/qemu/capabilities"] = map[string]interface{}{"kvm": []interface{}{"x86_64"}}
+	m.computeData["/v2/computes/local/qemu/binaries"] = []interface{}{
+		map[string]interface{}{"path": "/usr/bin/qemu-system-x86_64", "version": "6.2.0"},
+	}
+	m.computes = append(m.computes, map[string]interface{}{
+		"compute_id": "vm", "name": "GNS3 VM", "connected": false,
+	})
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3Compute().Schema, map[string]interface{}{})
+	if err := dataSourceGns3ComputeRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	if !d.Get("docker_available").(bool) || d.Get("dynamips_available").(bool) || !d.Get("kvm_available").(bool) {
+		t.Errorf("unexpected capabilities: docker %v, dynamips %v, kvm %v",
+			d.Get("docker_available"), d.Get("dynamips_available"), d.Get("kvm_available"))
+	}
+	if d.Get("memory_mb").(int) != 16384 || d.Get("qemu_binaries.0.version") != "6.2.0" {
+		t.Errorf("unexpected memory %v or QEMU binaries %v", d.Get("memory_mb"), d.Get("qemu_binaries"))
+	}
+	if types := d.Get("node_types").([]interface{}); len(types) != 4 || types[0] != "docker" {
+		t.Errorf("expected sorted node types, got %v", types)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceGns3Compute().Schema, map[string]interface{}{"compute_id": "vm"})
+	if err := dataSourceGns3ComputeRead(d, meta); err != nil {
+		t.Fatalf("read of a disconnected compute failed: %s", err)
+	}
+	if d.Get("connected").(bool) || d.Get("qemu_available").(bool) {
+		t.Errorf("expected a disconnected compute without capabilities")
+	}
+}
+
+func TestProjectsDataSourceFilters(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	m.addProject("lab-b")
+	labA := m.addProject("lab-a")
+	closed := m.addProject("lab-old")
+	m.addProject("prod")
+	m.object("/v2/projects/" + closed)["status"] = "closed"
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3Projects().Schema, map[string]interface{}{"name_regex": "^lab-", "status": "opened"})
+	if err := dataSourceGns3ProjectsRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	names := d.Get("names").([]interface{})
+	if len(names) != 2 || names[0] != "lab-a" || names[1] != "lab-b" {
+		t.Errorf("expected opened lab projects sorted by name, got %v", names)
+	}
+	if id := d.Get("projects.0.project_id"); id != labA {
+		t.Errorf("expected lab-a first, got %v", id)
+	}
+}
+
+func TestProjectPruneDeletesUnmanaged(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	kept := m.addNode(pid, "r1", "vpcs")
+	stray := m.addNode(pid, "scratch", "vpcs")
+	locked := m.addNode(pid, "pinned", "vpcs")
+	m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, locked))["locked"] = true
+	linkID, err := createLink(meta, pid, LinkNode{NodeID: kept}, LinkNode{NodeID: locked})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := resourceGns3ProjectPrune()
+	cfg := map[string]interface{}{"project_id": pid, "managed_node_ids": []interface{}{kept}}
+	state := applyConfig(t, r, nil, cfg, meta)
+	if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, stray)) != nil {
+		t.Errorf("unmanaged node was not pruned")
+	}
+	if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, locked)) == nil {
+		t.Errorf("locked node was pruned")
+	}
+	if m.object(fmt.Sprintf("/v2/projects/%s/links/%s", pid, linkID)) == nil {
+		t.Errorf("link was pruned although managed_link_ids is unset")
+	}
+
+	// A node added outside Terraform is reported at refresh and pruned on apply
+	late := m.addNode(pid, "late", "vpcs")
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if state.Attributes["unmanaged_node_ids.0"] != late {
+		t.Errorf("expected refresh to report the new node, got %v", state.Attributes)
+	}
+	cfg["prune_links"] = true
+	state = applyConfig(t, r, state, cfg, meta)
+	if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, late)) != nil {
+		t.Errorf("node added later was not pruned")
+	}
+	if m.object(fmt.Sprintf("/v2/projects/%s/links/%s", pid, linkID)) != nil {
+		t.Errorf("unmanaged link was not pruned")
+	}
+	if state.Attributes["unmanaged_node_ids.#"] != "0" {
+		t.Errorf("expected nothing left to prune, got %v", state.Attributes)
+	}
+}
+
+func TestProjectPruneKeepsHelperNodes(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	vm := applyConfig(t, resourceGns3Qemu(), nil, map[string]interface{}{
+		"project_id": pid, "name": "r1", "adapters": 2, "management_network": true,
+		"uplink": []interface{}{map[string]interface{}{"host_interface": "eth1", "adapter": 1}},
+	}, meta)
+	stray := m.addNode(pid, "scratch", "vpcs")
+	strayCloud := m.addNode(pid, "cloud", "cloud")
+	if _, err := createLink(meta, pid, LinkNode{NodeID: stray}, LinkNode{NodeID: strayCloud}); err != nil {
+		t.Fatal(err)
+	}
+
+	applyConfig(t, resourceGns3ProjectPrune(), nil, map[string]interface{}{
+		"project_id": pid, "managed_node_ids": []interface{}{vm.ID}, "prune_links": true,
+	}, meta)
+	for _, id := range []string{vm.Attributes["uplink.0.cloud_node_id"], vm.Attributes["management_nat_node_id"]} {
+		if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, id)) == nil {
+			t.Errorf("helper node %s was pruned", id)
+		}
+	}
+	for _, id := range []string{vm.Attributes["uplink.0.link_id"], vm.Attributes["management_link_id"]} {
+		if m.object(fmt.Sprintf("/v2/projects/%s/links/%s", pid, id)) == nil {
+			t.Errorf("helper link %s was pruned", id)
+		}
+	}
+	if m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, strayCloud)) != nil {
+		t.Errorf("cloud linked to an unmanaged node was not pruned")
+	}
+}
+
+func TestQemuInstallMode(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer console.Close()
+	go func() {
+		conn, err := console.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte{0xff, 0xfb, 0x01})
+		conn.Write([]byte("Installing packages...\r\nInstallation complete. Remove the installation media and reboot.\r\n"))
+		time.Sleep(time.Second)
+	}()
+
+	state := applyConfig(t, resourceGns3Qemu(), nil, map[string]interface{}{
+		"project_id": pid, "name": "srv1", "hda_disk_image": "srv.qcow2", "start_vm": true,
+		"console": console.Addr().(*net.TCPAddr).Port,
+		"install_mode": []interface{}{map[string]interface{}{
+			"cdrom_image": "debian.iso", "console_regex": "Installation complete", "timeout": 10,
+		}},
+	}, meta)
+
+	req := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid))
+	if props, _ := req.Body["properties"].(map[string]interface{}); props["cdrom_image"] != "debian.iso" || props["boot_priority"] != "d" {
+		t.Errorf("expected the VM to boot from the ISO first: %v", props)
+	}
+	props, _ := m.object(nodePath(state))["properties"].(map[string]interface{})
+	if props["cdrom_image"] != "" || props["boot_priority"] != "c" {
+		t.Errorf("expected the ISO to be detached after installation: %v", props)
+	}
+	if m.lastRequest("POST", nodePath(state)+"/reload") == nil {
+		t.Errorf("expected the VM to be rebooted from disk")
+	}
+}
+
+func TestSwitchImportByName(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	sw := m.addNode(pid, "core-sw", "ethernet_switch")
+	m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, sw))["properties"] = map[string]interface{}{
+		"ports_mapping": []interface{}{
+			map[string]interface{}{"name": "Ethernet0", "port_number": 0, "type": "access", "vlan": 10, "ethertype": ""},
+			map[string]interface{}{"name": "Ethernet1", "port_number": 1, "type": "dot1q", "vlan": 1, "ethertype": "0x8100"},
+		},
+	}
+
+	r := resourceGns3Switch()
+	imported, err := r.Importer.StateContext(context.Background(), r.Data(&terraform.InstanceState{ID: pid + "/core-sw"}), meta)
+	if err != nil {
+		t.Fatalf("import failed: %s", err)
+	}
+	if len(imported) != 1 || imported[0].Id() != sw {
+		t.Fatalf("expected switch %s to be imported", sw)
+	}
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), imported[0].State(), meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if state.Attributes["name"] != "core-sw" || state.Attributes["ports_mapping.#"] != "2" ||
+		state.Attributes["ports_mapping.0.vlan"] != "10" || state.Attributes["ports_mapping.1.type"] != "dot1q" {
+		t.Errorf("switch VLANs not read back: %v", state.Attributes)
+	}
+
+	if _, err := r.Importer.StateContext(context.Background(), r.Data(&terraform.InstanceState{ID: pid + "/missing"}), meta); err == nil {
+		t.Errorf("expected importing an unknown switch to fail")
+	}
+}
+
+func TestTemplateDesiredState(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	r := resourceGns3Template()
+
+	raw := map[string]interface{}{
+		"project_id": pid, "template_id": "tmpl-router", "name": "r1", "desired_state": "started",
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	if m.object(nodePath(state))["status"] != "started" {
+		t.Fatalf("expected the node to be started")
+	}
+
+	// Stopped outside Terraform: the next apply starts it again
+	m.object(nodePath(state))["status"] = "stopped"
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	state = applyConfig(t, r, state, raw, meta)
+	if m.object(nodePath(state))["status"] != "started" {
+		t.Errorf("expected the drifted node to be started again")
+	}
+
+	raw["reload_trigger"] = "1"
+	state = applyConfig(t, r, state, raw, meta)
+	if m.lastRequest("POST", nodePath(state)+"/reload") == nil {
+		t.Errorf("expected changing reload_trigger to reload the node")
+	}
+
+	raw["desired_state"] = "suspended"
+	state = applyConfig(t, r, state, raw, meta)
+	if m.object(nodePath(state))["status"] != "suspended" {
+		t.Errorf("expected the node to be suspended")
+	}
+}
+
+func TestComputeIDValidatedAtPlan(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	m.computes = append(m.computes, map[string]interface{}{"compute_id": "remote-1", "name": "gns3-vm", "connected": false})
+
+	r := resourceGns3Template()
+	plan := func(computeID string) error {
+		_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"project_id": pid, "template_id": "tmpl-router", "name": "r1", "compute_id": computeID,
+		}), meta)
+		return err
+	}
+
+	if err := plan("local"); err != nil {
+		t.Errorf("expected the local compute to be accepted: %s", err)
+	}
+	if err := plan("remote-1"); err == nil || !strings.Contains(err.Error(), "not connected") {
+		t.Errorf("expected a disconnected compute to be rejected, got %v", err)
+	}
+	if err := plan("missing"); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("expected an unknown compute to be rejected, got %v", err)
+	}
+
+	m.computes[1]["connected"] = true
+	if err := plan("remote-1"); err != nil {
+		t.Errorf("expected a connected compute to be accepted: %s", err)
+	}
+}
+
+func TestCloudModeGeneratesPortsMapping(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	r := resourceGns3Cloud()
+
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "wan", "mode": "bridge_to", "interfaces": []interface{}{"eth0", "eth1"},
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	req := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid))
+	props, _ := req.Body["properties"].(map[string]interface{})
+	mapping, _ := props["ports_mapping"].([]interface{})
+	if len(mapping) != 2 {
+		t.Fatalf("expected two bridged ports, got %v", props)
+	}
+	if port := mapping[1].(map[string]interface{}); port["interface"] != "eth1" || port["type"] != "ethernet" || port["port_number"] != float64(1) {
+		t.Errorf("unexpected port mapping: %v", port)
+	}
+
+	raw["mode"] = "tap"
+	raw["interfaces"] = []interface{}{"tap0"}
+	state = applyConfig(t, r, state, raw, meta)
+	props, _ = m.object(nodePath(state))["properties"].(map[string]interface{})
+	mapping, _ = props["ports_mapping"].([]interface{})
+	if len(mapping) != 1 || mapping[0].(map[string]interface{})["type"] != "tap" {
+		t.Errorf("expected the mapping to be replaced by a tap port, got %v", mapping)
+	}
+
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if state.Attributes["interfaces.#"] != "1" || state.Attributes["interfaces.0"] != "tap0" {
+		t.Errorf("interfaces not read back: %v", state.Attributes)
+	}
+
+	_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"project_id": pid, "name": "wan2", "mode": "bridge_to",
+	}), meta)
+	if err == nil {
+		t.Errorf("expected bridge_to without interfaces to be rejected")
+	}
+}
+
+func TestNodeEventWaitsForStatus(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	nid := m.addNode(pid, "r1", "qemu")
+	m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, nid))["status"] = "stopped"
+
+	read := func(raw map[string]interface{}) (*schema.ResourceData, error) {
+		d := schema.TestResourceDataRaw(t, dataSourceGns3NodeEvent().Schema, raw)
+		return d, dataSourceGns3NodeEventRead(d, meta)
+	}
+
+	// Already stopped: no waiting
+	if d, err := read(map[string]interface{}{"project_id": pid, "node_id": nid, "event": "stopped"}); err != nil || d.Get("status") != "stopped" {
+		t.Fatalf("expected the current status to satisfy the event: %v", err)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if err := nodeAction(meta, pid, nid, "start"); err != nil {
+			t.Errorf("start failed: %s", err)
+		}
+	}()
+	d, err := read(map[string]interface{}{"project_id": pid, "node_id": nid, "event": "started", "timeout": 10})
+	if err != nil {
+		t.Fatalf("waiting for the start failed: %s", err)
+	}
+	if d.Get("status") != "started" || d.Get("observed_at") == "" {
+		t.Errorf("unexpected event: %v", d.State().Attributes)
+	}
+
+	if _, err := read(map[string]interface{}{"project_id": pid, "node_id": nid, "event": "suspended", "timeout": 1}); err == nil {
+		t.Errorf("expected waiting for an event that never comes to time out")
+	}
+}
+
+func TestConsoleScriptExpectations(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	nid := m.addNode(pid, "r1", "qemu")
+
+	// A router console answering show commands at its prompt
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer console.Close()
+	go func() {
+		for {
+			conn, err := console.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("\r\nR1>"))
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if strings.TrimSpace(scanner.Text()) == "show ip ospf neighbor" {
+						conn.Write([]byte("Neighbor ID  State\r\n10.0.0.2     FULL/DR\r\n"))
+					}
+					conn.Write([]byte("R1>"))
+				}
+			}()
+		}
+	}()
+	node := m.object(fmt.Sprintf("/v2/projects/%s/nodes/%s", pid, nid))
+	node["console"] = console.Addr().(*net.TCPAddr).Port
+	node["console_type"] = "telnet"
+
+	r := resourceGns3ConsoleScript()
+	step := func(send, expect string) map[string]interface{} {
+		return map[string]interface{}{"send": send, "expect": expect, "timeout": 2}
+	}
+	state := applyConfig(t, r, nil, map[string]interface{}{
+		"project_id": pid, "node_id": nid,
+		"step": []interface{}{step("", "R1>"), step("show ip ospf neighbor", `FULL/\w+`)},
+	}, meta)
+	if state.Attributes["outputs.#"] != "2" || !strings.Contains(state.Attributes["outputs.1"], "10.0.0.2") {
+		t.Errorf("expected the neighbor table to be captured: %v", state.Attributes)
+	}
+	if !strings.Contains(state.Attributes["output"], "R1>") {
+		t.Errorf("expected the full transcript in output: %q", state.Attributes["output"])
+	}
+
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"project_id": pid, "node_id": nid,
+		"step": []interface{}{step("show ip ospf neighbor", `FULL/\w+`), step("show ip bgp summary", "Established")},
+	}), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := r.Apply(context.Background(), nil, diff, meta); !diags.HasError() {
+		t.Errorf("expected an unmet expectation to fail the apply")
+	}
+}
+
+func TestDockerPostStartCommands(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	// A container shell that echoes its input, like a console tty
+	var mu sync.Mutex
+	var ran []string
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer console.Close()
+	go func() {
+		for {
+			conn, err := console.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					line := strings.TrimSpace(scanner.Text())
+					conn.Write([]byte(line + "\r\n"))
+					command := strings.TrimSuffix(line, "; echo __gns3_rc_$?")
+					mu.Lock()
+					ran = append(ran, command)
+					mu.Unlock()
+					rc := "0"
+					if strings.HasPrefix(command, "false") {
+						rc = "1"
+					}
+					conn.Write([]byte("__gns3_rc_" + rc + "\r\n/ # "))
+				}
+			}()
+		}
+	}()
+
+	r := resourceGns3Docker()
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "web", "image": "alpine", "console_type": "telnet",
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	m.object(nodePath(state))["console"] = console.Addr().(*net.TCPAddr).Port
+
+	raw["post_start_commands"] = []interface{}{"ip addr add 10.0.0.10/24 dev eth0", "ip link set eth0 up"}
+	state = applyConfig(t, r, state, raw, meta)
+	mu.Lock()
+	if len(ran) != 2 || ran[0] != "ip addr add 10.0.0.10/24 dev eth0" {
+		t.Errorf("expected both commands to run in order, got %v", ran)
+	}
+	mu.Unlock()
+
+	raw["post_start_commands"] = []interface{}{"false"}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := r.Apply(context.Background(), state, diff, meta); !diags.HasError() {
+		t.Errorf("expected a failing command to fail the apply")
+	}
+
+	raw["console_type"] = "vnc"
+	if _, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta); err == nil {
+		t.Errorf("expected post_start_commands without a telnet console to be rejected")
+	}
+}
+
+func TestDockerDiscoverIP(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	// A container shell whose eth0 gets its DHCP lease after the first query
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer console.Close()
+	go func() {
+		queries := 0
+		for {
+			conn, err := console.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					line := strings.TrimSpace(scanner.Text())
+					conn.Write([]byte(line + "\r\n"))
+					queries++
+					out := "2: eth0: <BROADCAST,MULTICAST,UP> mtu 1500\r\n"
+					if queries > 1 {
+						out += "    inet 192.168.122.57/24 brd 192.168.122.255 scope global eth0\r\n"
+					}
+					conn.Write([]byte(out + "__gns3_rc_0\r\n/ # "))
+				}
+			}()
+		}
+	}()
+
+	r := resourceGns3Docker()
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "web", "image": "alpine", "console_type": "telnet",
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	m.object(nodePath(state))["console"] = console.Addr().(*net.TCPAddr).Port
+
+	raw["discover_ip"] = true
+	raw["discover_ip_timeout"] = 10
+	state = applyConfig(t, r, state, raw, meta)
+	if ip := state.Attributes["first_interface_ip"]; ip != "192.168.122.57" {
+		t.Errorf("expected the leased address to be discovered, got %q", ip)
+	}
+
+	raw["console_type"] = "vnc"
+	if _, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta); err == nil {
+		t.Errorf("expected discover_ip without a telnet console to be rejected")
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	r := resourceGns3Snapshot()
+
+	var states []*terraform.InstanceState
+	for i := 0; i < 3; i++ {
+		state := applyConfig(t, r, nil, map[string]interface{}{
+			"project_id": pid, "name": "nightly", "keep_last": 2, "created_by": "ci",
+		}, meta)
+		m.object(fmt.Sprintf("/v2/projects/%s/snapshots/%s", pid, state.ID))["created_at"] = 1700000000 + i
+		states = append(states, state)
+	}
+	applyConfig(t, r, nil, map[string]interface{}{"project_id": pid, "name": "manual"}, meta)
+
+	if name := states[0].Attributes["snapshot_name"]; name != "nightly [keep_last=2,created_by=ci]" {
+		t.Errorf("unexpected stored name %q", name)
+	}
+	if states[0].Attributes["name"] != "nightly" || states[0].Attributes["keep_last"] != "2" {
+		t.Errorf("retention metadata not read back: %v", states[0].Attributes)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceGns3ExpiredSnapshots().Schema, map[string]interface{}{"project_id": pid})
+	if err := dataSourceGns3ExpiredSnapshotsRead(d, meta); err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	ids := d.Get("snapshot_ids").([]interface{})
+	if len(ids) != 1 || ids[0] != states[0].ID {
+		t.Errorf("expected only the oldest nightly snapshot to be expired, got %v", ids)
+	}
+}
+
+func TestWindowsImagePaths(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"vyos.qcow2", "vyos.qcow2"},
+		{"/opt/gns3/images/QEMU/vyos.qcow2", "/opt/gns3/images/QEMU/vyos.qcow2"},
+		{`c:\Users\lab\GNS3\images\QEMU\vyos.qcow2`, "C:/Users/lab/GNS3/images/QEMU/vyos.qcow2"},
+		{` QEMU\vyos.qcow2 `, "QEMU/vyos.qcow2"},
+	}
+	for _, tc := range cases {
+		if got := normalizeImagePath(tc.in); got != tc.want {
+			t.Errorf("normalizeImagePath(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if _, errs := validateImagePath(`C:images\vyos.qcow2`, "hda_disk_image"); len(errs) == 0 {
+		t.Errorf("expected a drive-relative path to be rejected")
+	}
+	if got := imageBaseName(`C:\images\vyos 1.4.qcow2`); got != "vyos 1.4.qcow2" {
+		t.Errorf("imageBaseName = %q", got)
+	}
+
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	state := applyConfig(t, resourceGns3Qemu(), nil, map[string]interface{}{
+		"project_id": pid, "name": "vm", "hda_disk_image": `C:\GNS3\images\QEMU\vyos.qcow2`,
+	}, meta)
+	req := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid))
+	if props, _ := req.Body["properties"].(map[string]interface{}); props["hda_disk_image"] != "C:/GNS3/images/QEMU/vyos.qcow2" {
+		t.Errorf("expected the normalized path to be sent, got %v", props["hda_disk_image"])
+	}
+	if state.Attributes["hda_disk_image"] != "C:/GNS3/images/QEMU/vyos.qcow2" {
+		t.Errorf("unexpected hda_disk_image in state: %q", state.Attributes["hda_disk_image"])
+	}
+}
+
+func TestClientIPProtocol(t *testing.T) {
+	m := newMockController(t)
+
+	ipv4 := newClient("", nil)
+	ipv4.setIPProtocol("ipv4")
+	resp, err := ipv4.Get(m.server.URL + "/v2/version")
+	if err != nil {
+		t.Fatalf("expected the IPv4 mock to be reachable over IPv4: %s", err)
+	}
+	resp.Body.Close()
+
+	// The mock only listens on 127.0.0.1
+	ipv6 := newClient("", nil)
+	ipv6.setIPProtocol("ipv6")
+	if resp, err := ipv6.Get(m.server.URL + "/v2/version"); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected an IPv4 address not to be dialed with ip_protocol = ipv6")
+	}
+}
+
+func TestProjectReadme(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	r := resourceGns3Project()
+
+	raw := map[string]interface{}{"name": "lab", "readme": "Log in with admin/admin.\n"}
+	state := applyConfig(t, r, nil, raw, meta)
+	readmePath := fmt.Sprintf("/v2/projects/%s/files/README.txt", state.ID)
+	if got := string(m.files[readmePath]); got != "Log in with admin/admin.\n" {
+		t.Fatalf("unexpected README.txt: %q", got)
+	}
+	if state.Attributes["readme_sha256"] == "" {
+		t.Errorf("expected readme_sha256 to be set")
+	}
+
+	// Edited in the GUI: refresh reports the drift and apply restores the file
+	m.files[readmePath] = []byte("scribbles")
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if state.Attributes["readme"] != "scribbles" {
+		t.Errorf("expected the edited README to show up as drift, got %q", state.Attributes["readme"])
+	}
+	applyConfig(t, r, state, raw, meta)
+	if got := string(m.files[readmePath]); got != "Log in with admin/admin.\n" {
+		t.Errorf("expected README.txt to be restored, got %q", got)
+	}
+}
+
+func TestClientRateLimitsMutationsSeparately(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	meta.Client.limiter = newRateLimiter(0, 10)
+
+	// Reads are unlimited
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		resp, err := meta.Client.Get(m.server.URL + "/v2/projects/" + pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("reads were held back for %s", elapsed)
+	}
+
+	// A burst of 10 mutations goes through at once, the next 5 take half a second
+	start = time.Now()
+	for i := 0; i < 15; i++ {
+		if err := updateNode(meta, pid, "missing", map[string]interface{}{"name": "x"}); err == nil {
+			t.Fatal("expected updating a missing node to fail")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected mutations to be limited to 10 per second, 15 took %s", elapsed)
+	}
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{"host": m.server.URL, "max_mutations_per_second": 2.5})
+	if v := d.Get("max_reads_per_second").(float64); v != 0 {
+		t.Errorf("expected reads to be unlimited by default, got %v", v)
+	}
+}
+
+func TestStopBeforeUpdate(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Docker()
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "web", "image": "alpine",
+		"environment": map[string]interface{}{"MODE": "a"},
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	path := nodePath(state)
+	m.object(path)["status"] = "started"
+
+	actions := func() []string {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		var seen []string
+		for _, req := range m.requests {
+			switch req.Path {
+			case path + "/stop", path + "/start":
+				seen = append(seen, req.Path[len(path)+1:])
+			case path:
+				if req.Method == "PUT" {
+					seen = append(seen, "update")
+				}
+			}
+		}
+		m.requests = nil
+		return seen
+	}
+	actions()
+
+	raw["usage"] = "ssh admin@web"
+	state = applyConfig(t, r, state, raw, meta)
+	if got := strings.Join(actions(), ","); got != "update" {
+		t.Errorf("expected a live update for usage, got %s", got)
+	}
+
+	raw["environment"] = map[string]interface{}{"MODE": "b"}
+	state = applyConfig(t, r, state, raw, meta)
+	if got := strings.Join(actions(), ","); got != "stop,update,start" {
+		t.Errorf("expected stop, update, start for environment, got %s", got)
+	}
+
+	raw["restart_after_update"] = false
+	raw["environment"] = map[string]interface{}{"MODE": "c"}
+	state = applyConfig(t, r, state, raw, meta)
+	if got := strings.Join(actions(), ","); got != "stop,update" {
+		t.Errorf("expected the node to stay stopped, got %s", got)
+	}
+
+	m.object(path)["status"] = "started"
+	raw["stop_before_update"] = false
+	raw["environment"] = map[string]interface{}{"MODE": "d"}
+	applyConfig(t, r, state, raw, meta)
+	if got := strings.Join(actions(), ","); got != "update" {
+		t.Errorf("expected no stop with stop_before_update = false, got %s", got)
+	}
+}
+
+func TestApplianceNode(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	appliance := map[string]interface{}{
+		"name":             "VyOS",
+		"port_name_format": "eth{0}",
+		"qemu": map[string]interface{}{
+			"adapter_type": "virtio-net-pci", "adapters": 3, "ram": 512,
+			"hda_disk_interface": "virtio", "arch": "x86_64", "console_type": "telnet",
+		},
+		"images": []interface{}{
+			map[string]interface{}{"filename": "vyos-1.4.qcow2", "version": "1.4", "md5sum": "aaaa", "download_url": "https://vyos.io/"},
+		},
+		"versions": []interface{}{
+			map[string]interface{}{"name": "1.4", "images": map[string]interface{}{"hda_disk_image": "vyos-1.4.qcow2"}},
+		},
+	}
+	raw, _ := json.Marshal(appliance)
+	file := filepath.Join(t.TempDir(), "vyos.gns3a")
+	if err := ioutil.WriteFile(file, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := resourceGns3ApplianceNode()
+	config := map[string]interface{}{
+		"project_id": pid, "name": "r1", "appliance_file": file, "version": "1.4", "cpus": 2,
+	}
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := r.Apply(context.Background(), nil, diff, meta); !diags.HasError() || !strings.Contains(fmt.Sprint(diags), "https://vyos.io/") {
+		t.Errorf("expected missing images to fail with their download URL, got %v", diags)
+	}
+
+	m.images["local/qemu"] = []map[string]interface{}{{"filename": "vyos-1.4.qcow2", "path": "vyos-1.4.qcow2", "md5sum": "aaaa"}}
+	state := applyConfig(t, r, nil, config, meta)
+	req := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid))
+	props, _ := req.Body["properties"].(map[string]interface{})
+	if props["hda_disk_image"] != "vyos-1.4.qcow2" || props["platform"] != "x86_64" || props["ram"] != float64(512) || props["cpus"] != float64(2) {
+		t.Errorf("unexpected node properties: %v", props)
+	}
+	if req.Body["port_name_format"] != "eth{0}" {
+		t.Errorf("expected the appliance port naming, got %v", req.Body["port_name_format"])
+	}
+	if state.Attributes["images.hda_disk_image"] != "vyos-1.4.qcow2" {
+		t.Errorf("unexpected images: %v", state.Attributes)
+	}
+
+	m.appliances = []map[string]interface{}{appliance}
+	config = map[string]interface{}{
+		"project_id": pid, "name": "r2", "appliance_name": "VyOS", "version": "1.3",
+	}
+	diff, err = r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := r.Apply(context.Background(), nil, diff, meta); !diags.HasError() || !strings.Contains(fmt.Sprint(diags), "available versions: 1.4") {
+		t.Errorf("expected an unknown version to list the available ones, got %v", diags)
+	}
+}
+
+func TestConsoleAutoStart(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3Docker()
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "web", "image": "alpine", "start": false,
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	if _, ok := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid)).Body["console_auto_start"]; ok {
+		t.Errorf("expected console_auto_start to be left to the controller when unset")
+	}
+
+	raw["console_auto_start"] = true
+	state = applyConfig(t, r, state, raw, meta)
+	if m.object(nodePath(state))["console_auto_start"] != true {
+		t.Errorf("expected console_auto_start to be set on the node")
+	}
+
+	// Turned off in the GUI
+	m.object(nodePath(state))["console_auto_start"] = false
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	if state.Attributes["console_auto_start"] != "false" {
+		t.Errorf("expected drift to be read back, got %q", state.Attributes["console_auto_start"])
+	}
+}
+
+func TestNodeGroup(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	r1 := m.addNode(pid, "r1", "qemu")
+	r2 := m.addNode(pid, "r2", "qemu")
+	nodes := fmt.Sprintf("/v2/projects/%s/nodes/", pid)
+	m.object(nodes + r1)["x"], m.object(nodes + r1)["y"] = 0, 0
+	m.object(nodes + r2)["x"], m.object(nodes + r2)["y"] = 200, 100
+
+	r := resourceGns3NodeGroup()
+	raw := map[string]interface{}{
+		"project_id": pid, "node_ids": []interface{}{r1, r2}, "label": "Core",
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	for key, want := range map[string]string{"x": "-20", "y": "-20", "width": "300", "height": "200"} {
+		if state.Attributes[key] != want {
+			t.Errorf("expected %s = %s, got %s", key, want, state.Attributes[key])
+		}
+	}
+	label := m.object(fmt.Sprintf("/v2/projects/%s/drawings/%s", pid, state.Attributes["label_drawing_id"]))
+	if label == nil || !strings.Contains(label["svg"].(string), ">Core</text>") {
+		t.Errorf("expected a label drawing, got %v", label)
+	}
+
+	// Moved in the GUI: the next plan redraws the rectangle around it
+	m.object(nodes + r2)["x"] = 400
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == nil || diff.Attributes["width"] == nil {
+		t.Fatalf("expected the moved node to plan a resize, got %v", diff)
+	}
+	state = applyConfig(t, r, state, raw, meta)
+	if state.Attributes["width"] != "500" {
+		t.Errorf("expected the rectangle to follow the node, got width %s", state.Attributes["width"])
+	}
+
+	if err := destroy(r, state, meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.children(fmt.Sprintf("/v2/projects/%s/drawings", pid))) != 0 {
+		t.Errorf("expected both drawings to be deleted")
+	}
+}
+
+func TestDynamipsModules(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+	r := resourceGns3Dynamips()
+
+	invalid := []struct {
+		config map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"platform": "c3725", "slot3": "NM-4T"}, "slots 0-2 only"},
+		{map[string]interface{}{"platform": "c3745", "slot1": "PA-GE"}, "does not fit slot 1"},
+		{map[string]interface{}{"platform": "c7200", "wic0": "WIC-2T"}, "no WAN interface cards"},
+		{map[string]interface{}{"platform": "c3600", "slot2": "NM-4T", "chassis": "3620"}, "slots 0-1 only"},
+		{map[string]interface{}{"platform": "c2600", "chassis": "3640"}, "not a c2600 model"},
+	}
+	for _, tc := range invalid {
+		tc.config["project_id"], tc.config["name"], tc.config["image"] = pid, "r1", "ios.image"
+		_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.config), meta)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.config, tc.err, err)
+		}
+	}
+
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "r1", "image": "c3725.image", "platform": "c3725",
+		"slot1": "NM-16ESW", "wic0": "WIC-2T",
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	props := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid)).Body["properties"].(map[string]interface{})
+	if props["slot1"] != "NM-16ESW" || props["wic0"] != "WIC-2T" || props["platform"] != "c3725" {
+		t.Errorf("unexpected properties: %v", props)
+	}
+
+	// GNS3 fills in the onboard module
+	node := m.object(nodePath(state))
+	node["properties"].(map[string]interface{})["slot0"] = "GT96100-FE"
+	node["status"] = "started"
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != nil && len(diff.Attributes) > 0 {
+		t.Errorf("expected the onboard module not to show as a diff, got %v", diff.Attributes)
+	}
+
+	m.mu.Lock()
+	m.requests = nil
+	m.mu.Unlock()
+	raw["slot2"] = "NM-4T"
+	applyConfig(t, r, state, raw, meta)
+	if m.lastRequest("POST", nodePath(state)+"/stop") == nil || m.lastRequest("POST", nodePath(state)+"/start") == nil {
+		t.Errorf("expected the running router to be stopped and started around the module change")
+	}
+	if props := m.lastRequest("PUT", nodePath(state)).Body["properties"].(map[string]interface{}); props["slot2"] != "NM-4T" {
+		t.Errorf("expected slot2 to be updated, got %v", props)
+	}
+}
+
+func TestIOUNode(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3IOU()
+	raw := map[string]interface{}{
+		"project_id": pid, "name": "sw1", "path": "i86bi-linux-l2-adventerprisek9-15.1a.bin",
+		"ethernet_adapters": 4, "serial_adapters": 0, "l1_keepalives": true,
+	}
+	state := applyConfig(t, r, nil, raw, meta)
+	props := m.lastRequest("POST", fmt.Sprintf("/v2/projects/%s/nodes", pid)).Body["properties"].(map[string]interface{})
+	if props["ethernet_adapters"] != float64(4) || props["serial_adapters"] != float64(0) || props["l1_keepalives"] != true {
+		t.Errorf("unexpected properties: %v", props)
+	}
+	if state.Attributes["image_type"] != "l2" {
+		t.Errorf("expected an l2 image, got %q", state.Attributes["image_type"])
+	}
+	if got := iouImageType("i86bi-linux-l3-adventerprisek9-15.4.1T.bin"); got != "l3" {
+		t.Errorf("expected an l3 image, got %q", got)
+	}
+
+	m.object(nodePath(state))["status"] = "started"
+	raw["serial_adapters"] = 2
+	applyConfig(t, r, state, raw, meta)
+	if m.lastRequest("POST", nodePath(state)+"/stop") == nil || m.lastRequest("POST", nodePath(state)+"/start") == nil {
+		t.Errorf("expected the running node to be restarted around the adapter change")
+	}
+}
+
+func TestIOULicense(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+
+	file := filepath.Join(t.TempDir(), "iourc")
+	if err := ioutil.WriteFile(file, []byte("[license]\ngns3vm = 73635fd3b0a13ad0;\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := resourceGns3IOULicense()
+	raw := map[string]interface{}{"license_file": file}
+	state := applyConfig(t, r, nil, raw, meta)
+	if m.object("/v2/iou_license")["iourc_content"] != "[license]\ngns3vm = 73635fd3b0a13ad0;\n" {
+		t.Errorf("expected the license file to be uploaded, got %v", m.object("/v2/iou_license"))
+	}
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != nil && len(diff.Attributes) > 0 {
+		t.Errorf("expected no changes, got %v", diff.Attributes)
+	}
+
+	// A new license in the file is applied without changing the configuration
+	if err := ioutil.WriteFile(file, []byte("[license]\ngns3vm = 0000000000000000;\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state = applyConfig(t, r, state, raw, meta)
+	if m.object("/v2/iou_license")["iourc_content"] != "[license]\ngns3vm = 0000000000000000;\n" {
+		t.Errorf("expected the changed license file to be uploaded")
+	}
+
+	if err := destroy(r, state, meta); err != nil {
+		t.Fatal(err)
+	}
+	if m.object("/v2/iou_license")["iourc_content"] != "" {
+		t.Errorf("expected the license to be cleared on destroy")
+	}
+}
+
+func TestDeleteNodesBeforeLinks(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	nodes := resourceGns3Qemu()
+	a := applyConfig(t, nodes, nil, map[string]interface{}{"project_id": pid, "name": "a", "adapters": 2}, meta)
+	b := applyConfig(t, resourceGns3Switch(), nil, map[string]interface{}{"project_id": pid, "name": "sw"}, meta)
+	links := resourceGns3Link()
+	linkConfig := func(adapter int) map[string]interface{} {
+		return map[string]interface{}{
+			"project_id": pid, "node_a_id": a.ID, "node_a_adapter": adapter, "node_a_port": 0,
+			"node_b_id": b.ID, "node_b_adapter": 0, "node_b_port": adapter,
+		}
+	}
+	first := applyConfig(t, links, nil, linkConfig(0), meta)
+	second := applyConfig(t, links, nil, linkConfig(1), meta)
+	linkPath := func(s *terraform.InstanceState) string {
+		return fmt.Sprintf("/v2/projects/%s/links/%s", pid, s.ID)
+	}
+
+	// The node goes first and takes its links with it
+	if err := destroy(nodes, a, meta); err != nil {
+		t.Fatalf("failed to delete the node: %s", err)
+	}
+	if m.object(linkPath(first)) != nil {
+		t.Fatalf("expected deleting the node to delete its links")
+	}
+	if err := destroy(links, first, meta); err != nil {
+		t.Errorf("expected deleting a link of a deleted node to succeed, got %s", err)
+	}
+
+	// A delete the controller fails while the link goes away is not an error
+	third := applyConfig(t, links, nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": b.ID, "node_a_adapter": 0, "node_a_port": 2,
+		"node_b_id": b.ID, "node_b_adapter": 0, "node_b_port": 3,
+	}, meta)
+	m.deleteErrors[linkPath(third)] = http.StatusInternalServerError
+	if err := destroy(links, third, meta); err != nil {
+		t.Errorf("expected a failed delete of a link that is gone to succeed, got %s", err)
+	}
+
+	// A delete the controller refuses is still reported
+	m.deleteErrors[nodePath(b)] = http.StatusConflict
+	if err := destroy(resourceGns3Switch(), b, meta); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("expected a refused delete of an existing node to fail, got %v", err)
+	}
+	if err := destroy(resourceGns3Switch(), b, meta); err != nil {
+		t.Errorf("failed to delete the switch: %s", err)
+	}
+	if err := destroy(links, second, meta); err != nil {
+		t.Errorf("expected deleting a link of a deleted switch to succeed, got %s", err)
+	}
+}
+
+func TestTextAnnotationImportRoundTrip(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	r := resourceGns3TextAnnotation()
+	cfg := map[string]interface{}{"project_id": pid, "text": "Core <DC1>\nrack 4", "font_family": `Nimbus "Sans" & <Mono>`, "font_size": 14, "bold": true, "color": "#ff0000", "x": 10}
+	state := applyConfig(t, r, nil, cfg, meta)
+
+	d := r.Data(&terraform.InstanceState{ID: pid + "/" + state.ID})
+	imported, err := r.Importer.StateContext(context.Background(), d, meta)
+	if err != nil {
+		t.Fatalf("import failed: %s", err)
+	}
+	refreshed, diags := r.RefreshWithoutUpgrade(context.Background(), imported[0].State(), meta)
+	if diags.HasError() {
+		t.Fatalf("refresh after import failed: %v", diags)
+	}
+	for k, want := range map[string]string{"text": "Core <DC1>\nrack 4", "font_family": `Nimbus "Sans" & <Mono>`, "font_size": "14", "bold": "true", "color": "#ff0000"} {
+		if got := refreshed.Attributes[k]; got != want {
+			t.Errorf("expected imported %s to be %q, got %q", k, want, got)
+		}
+	}
+	diff, err := r.Diff(context.Background(), refreshed, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != nil && len(diff.Attributes) > 0 {
+		t.Errorf("expected no diff after import, got %v", diff.Attributes)
+	}
+
+	// A text edited in the GUI shows up as drift
+	path := fmt.Sprintf("/v2/projects/%s/drawings/%s", pid, state.ID)
+	m.object(path)["svg"] = `<svg height="24" width="80"><text fill="#000000" fill-opacity="1.0" font-family="Arial" font-size="14.0" font-weight="700">Edge</text></svg>`
+	refreshed, diags = r.RefreshWithoutUpgrade(context.Background(), refreshed, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh failed: %v", diags)
+	}
+	if refreshed.Attributes["text"] != "Edge" || refreshed.Attributes["color"] != "#000000" || refreshed.Attributes["bold"] != "true" {
+		t.Errorf("expected the GUI edit to be read back, got %v", refreshed.Attributes)
+	}
+}
+
+func TestReloadOnChangeUnknownAttribute(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	pid := m.addProject("lab")
+
+	cases := []struct {
+		r   *schema.Resource
+		cfg map[string]interface{}
+	}{
+		{resourceGns3Qemu(), map[string]interface{}{"project_id": pid, "name": "q1"}},
+		{resourceGns3Docker(), map[string]interface{}{"project_id": pid, "name": "c1", "image": "alpine"}},
+		{resourceGns3Template(), map[string]interface{}{"project_id": pid, "template_id": "tmpl-router", "name": "r1"}},
+	}
+	for _, tc := range cases {
+		tc.cfg["reload_on_change"] = []interface{}{"adapter"}
+		if _, err := tc.r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.cfg), meta); err == nil || !strings.Contains(err.Error(), `"adapter" is not an attribute`) {
+			t.Errorf("expected a typo in reload_on_change of %s to fail the plan, got %v", tc.cfg["name"], err)
+		}
+		tc.cfg["reload_on_change"] = []interface{}{"name", "x"}
+		if _, err := tc.r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.cfg), meta); err != nil {
+			t.Errorf("expected valid reload_on_change of %s to plan, got %s", tc.cfg["name"], err)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	m := newMockController(t)
+	pid := m.addProject("lab")
+	real := applyConfig(t, resourceGns3Qemu(), nil, map[string]interface{}{"project_id": pid, "name": "r1", "ram": 512}, m.config())
+
+	meta := m.config()
+	meta.Client.dryRun = newDryRunStore()
+	m.mu.Lock()
+	sent := len(m.requests)
+	m.mu.Unlock()
+
+	// Synthetic objects round-trip through Read
+	r := resourceGns3Qemu()
+	node := applyConfig(t, r, nil, map[string]interface{}{"project_id": pid, "name": "r2"}, meta)
+	if !strings.HasPrefix(node.ID, dryRunIDPrefix) {
+		t.Fatalf("expected a synthetic node ID, got %q", node.ID)
+	}
+	refreshed, diags := r.RefreshWithoutUpgrade(context.Background(), node, meta)
+	if diags.HasError() || refreshed == nil || refreshed.ID != node.ID || refreshed.Attributes["name"] != "r2" {
+		t.Fatalf("expected the synthetic node to be read back, got %v %v", refreshed, diags)
+	}
+	link := applyConfig(t, resourceGns3Link(), nil, map[string]interface{}{
+		"project_id": pid, "node_a_id": node.ID, "node_a_adapter": 0, "node_a_port": 0,
+		"node_b_id": real.ID, "node_b_adapter": 0, "node_b_port": 0,
+	}, meta)
+	if !strings.HasPrefix(link.ID, dryRunIDPrefix) {
+		t.Errorf("expected a synthetic link ID, got %q", link.ID)
+	}
+
+	// An update of a real node reads back whole and plans no further changes
+	cfg := map[string]interface{}{"project_id": pid, "name": "core1", "ram": 1024}
+	updated := applyConfig(t, r, real, cfg, meta)
+	refreshed, diags = r.RefreshWithoutUpgrade(context.Background(), updated, meta)
+	if diags.HasError() {
+		t.Fatalf("refresh after update failed: %v", diags)
+	}
+	if refreshed.Attributes["name"] != "core1" || refreshed.Attributes["ports.#"] != real.Attributes["ports.#"] {
+		t.Errorf("expected the updated node to be read back whole, got %v", refreshed.Attributes)
+	}
+	diff, err := r.Diff(context.Background(), refreshed, terraform.NewResourceConfigRaw(cfg), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != nil && len(diff.Attributes) > 0 {
+		t.Errorf("expected a stable plan after the update, got %v", diff.Attributes)
+	}
+
+	if err := destroy(resourceGns3Link(), link, meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := destroy(r, refreshed, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing but reads reached the controller
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, req := range m.requests[sent:] {
+		if req.Method != "GET" {
+			t.Errorf("dry run sent %s %s to the controller", req.Method, req.Path)
+		}
+	}
+	if obj := m.objects[nodePath(real)]; obj["name"] != "r1" || jsonInt(obj["properties"].(map[string]interface{})["ram"]) != 512 {
+		t.Errorf("dry run changed the node on the controller: %v", obj)
+	}
+}
+
+func TestProjectLockAfterFinishedRun(t *testing.T) {
+	m := newMockController(t)
+	pid := m.addProject("lab")
+	r := resourceGns3Switch()
+
+	// The first run's provider process has exited by the time the second starts
+	finished := exec.Command(os.Args[0], "-test.run=^$")
+	if err := finished.Run(); err != nil {
+		t.Fatalf("failed to run a process: %s", err)
+	}
+	first := m.config()
+	first.Client.locks = newProjectLocks("error")
+	first.Client.locks.pid = finished.Process.Pid
+	applyConfig(t, r, nil, map[string]interface{}{"project_id": pid, "name": "sw1"}, first)
+
+	second := m.config()
+	second.Client.locks = newProjectLocks("error")
+	applyConfig(t, r, nil, map[string]interface{}{"project_id": pid, "name": "sw2"}, second)
+
+	var holder projectLockInfo
+	if err := json.Unmarshal(m.files[fmt.Sprintf("/v2/projects/%s/files/%s", pid, projectLockFile)], &holder); err != nil || holder.Owner != second.Client.locks.owner {
+		t.Errorf("expected the second run to take over the lock, got %+v (%v)", holder, err)
+	}
+
+	// A run on another host is still refused while the lock holds
+	third := m.config()
+	third.Client.locks = newProjectLocks("error")
+	third.Client.locks.host = "elsewhere"
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{"project_id": pid, "name": "sw3"}), third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := r.Apply(context.Background(), nil, diff, third); !diags.HasError() || !strings.Contains(fmt.Sprint(diags), "is being changed by") {
+		t.Errorf("expected a run on another host to be refused, got %v", diags)
+	}
+}
+
+// unreadable is a request body that fails the test when read.
+type unreadable struct{ t *testing.T }
+
+func (u unreadable) Read(p []byte) (int, error) {
+	u.t.Errorf("the request body was read")
+	return 0, errors.New("unreadable")
+}
+
+func TestImageUploadDryRunAndTimeout(t *testing.T) {
+	m := newMockController(t)
+	meta := m.config()
+	if meta.Client.withoutTimeout().httpClient.Timeout != 0 || meta.Client.httpClient.Timeout == 0 {
+		t.Errorf("expected uploads alone to have no total timeout")
+	}
+
+	meta.Client.dryRun = newDryRunStore()
+	source := filepath.Join(t.TempDir(), "disk.qcow2")
+	if err := ioutil.WriteFile(source, []byte("qcow2 image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	state := applyConfig(t, resourceGns3Image(), nil, map[string]interface{}{"source": source, "discovery_timeout": 2}, meta)
+	if time.Since(start) > time.Second || state.Attributes["md5sum"] == "" || state.Attributes["filesize"] != "11" {
+		t.Errorf("expected the dry run upload to skip discovery, got %v after %s", state.Attributes, time.Since(start))
+	}
+	if m.lastRequest("POST", "/v2/computes/local/qemu/images/disk.qcow2") != nil {
+		t.Errorf("dry run uploaded the image")
+	}
+
+	req, _ := http.NewRequest("POST", m.server.URL+"/v2/computes/local/qemu/images/big.qcow2", ioutil.NopCloser(unreadable{t}))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if _, err := meta.Client.Do(req); err != nil {
+		t.Errorf("dry run upload failed: %s", err)
+	}
+}
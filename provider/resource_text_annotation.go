@@ -0,0 +1,358 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Drawing represents a GNS3 drawing API request/response.
+type Drawing struct {
+	DrawingID string `json:"drawing_id,omitempty"`
+	SVG       string `json:"svg"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Z         int    `json:"z"`
+	Rotation  int    `json:"rotation"`
+}
+
+// hexColor matches #rgb and #rrggbb color values.
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// resourceGns3TextAnnotation defines a canvas text label rendered as an SVG drawing.
+func resourceGns3TextAnnotation() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withCanvasCheck(resourceGns3TextAnnotationCreate),
+		Read:          resourceGns3TextAnnotationRead,
+		UpdateContext: withCanvasCheck(resourceGns3TextAnnotationUpdate),
+		Delete:        resourceGns3TextAnnotationDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceGns3TextAnnotationImporter,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"project_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The project ID where the annotation is drawn.",
+			},
+			"text": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The text to display. Newlines start a new line.",
+			},
+			"font_family": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "TypeWriter",
+				Description: "Font family used for the text.",
+			},
+			"font_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validation.IntBetween(1, 200),
+				Description:  "Font size in points.",
+			},
+			"bold": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Render the text in bold.",
+			},
+			"color": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "#000000",
+				ValidateFunc: validation.StringMatch(hexColor, "must be a hex color such as #ff0000"),
+				Description:  "Text color as a hex value.",
+			},
+			"x": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     0,
+				Description: "X position of the annotation in GNS3 GUI.",
+			},
+			"y": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     0,
+				Description: "Y position of the annotation in GNS3 GUI.",
+			},
+			"z": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     1,
+				Description: "Stacking order of the annotation.",
+			},
+			"rotation": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntBetween(-359, 359),
+				Description:  "Rotation in degrees.",
+			},
+			"svg": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The SVG generated for the annotation.",
+			},
+			"drawing_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The drawing's ID assigned by GNS3.",
+			},
+		},
+	}
+}
+
+// textAnnotationSVG renders the SVG document GNS3 uses for text drawings.
+func textAnnotationSVG(d *schema.ResourceData) string {
+	return textSVG(d.Get("text").(string), d.Get("font_family").(string), d.Get("font_size").(int), d.Get("bold").(bool), d.Get("color").(string))
+}
+
+// textSVG renders a text drawing, sized to fit its longest line.
+func textSVG(text, family string, size int, bold bool, color string) string {
+	lines := strings.Split(text, "\n")
+	longest := 0
+	for _, l := range lines {
+		if len(l) > longest {
+			longest = len(l)
+		}
+	}
+	width := longest*size*6/10 + size
+	height := len(lines)*size*3/2 + size/2
+
+	weight := "normal"
+	if bold {
+		weight = "bold"
+	}
+
+	var escaped, escapedFamily bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(text))
+	_ = xml.EscapeText(&escapedFamily, []byte(family))
+
+	return fmt.Sprintf(
+		`<svg height="%d" width="%d"><text fill="%s" fill-opacity="1.0" font-family="%s" font-size="%d" font-weight="%s">%s</text></svg>`,
+		height, width, color, escapedFamily.String(), size, weight, escaped.String(),
+	)
+}
+
+// textSVGDocument is the part of a text drawing's SVG that textSVG writes.
+type textSVGDocument struct {
+	Text *struct {
+		Fill       string `xml:"fill,attr"`
+		FontFamily string `xml:"font-family,attr"`
+		FontSize   string `xml:"font-size,attr"`
+		FontWeight string `xml:"font-weight,attr"`
+		Content    string `xml:",chardata"`
+	} `xml:"text"`
+}
+
+// parseTextSVG reads the text and its style back from the SVG of a text drawing,
+// as written by textSVG or the GNS3 GUI, which writes sizes such as "10.0" and
+// numeric weights. ok is false for drawings that hold no text, such as shapes.
+func parseTextSVG(svg string) (text, family string, size int, bold bool, color string, ok bool) {
+	var doc textSVGDocument
+	if err := xml.Unmarshal([]byte(svg), &doc); err != nil || doc.Text == nil {
+		return "", "", 0, false, "", false
+	}
+	t := doc.Text
+	if f, err := strconv.ParseFloat(t.FontSize, 64); err == nil {
+		size = int(f + 0.5)
+	}
+	weight, err := strconv.Atoi(t.FontWeight)
+	bold = t.FontWeight == "bold" || t.FontWeight == "bolder" || (err == nil && weight >= 600)
+	return t.Content, t.FontFamily, size, bold, t.Fill, true
+}
+
+func resourceGns3TextAnnotationCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	drawing := Drawing{
+		SVG:      textAnnotationSVG(d),
+		X:        d.Get("x").(int),
+		Y:        d.Get("y").(int),
+		Z:        d.Get("z").(int),
+		Rotation: d.Get("rotation").(int),
+	}
+
+	data, err := json.Marshal(drawing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal drawing data: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings", host, projectID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("error creating GNS3 drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to create drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var created Drawing
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return fmt.Errorf("failed to decode drawing response: %s", err)
+	}
+	if created.DrawingID == "" {
+		return fmt.Errorf("failed to retrieve drawing_id from GNS3 API response")
+	}
+
+	d.SetId(created.DrawingID)
+	d.Set("drawing_id", created.DrawingID)
+	return resourceGns3TextAnnotationRead(d, meta)
+}
+
+func resourceGns3TextAnnotationRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings/%s", host, projectID, d.Id())
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return fmt.Errorf("error reading drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		d.SetId("")
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to read drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var drawing Drawing
+	if err := json.NewDecoder(resp.Body).Decode(&drawing); err != nil {
+		return fmt.Errorf("failed to decode drawing: %s", err)
+	}
+
+	d.Set("drawing_id", drawing.DrawingID)
+	d.Set("svg", drawing.SVG)
+	if text, family, size, bold, color, ok := parseTextSVG(drawing.SVG); ok {
+		d.Set("text", text)
+		if family != "" {
+			d.Set("font_family", family)
+		}
+		if size > 0 {
+			d.Set("font_size", size)
+		}
+		d.Set("bold", bold)
+		if color != "" {
+			d.Set("color", color)
+		}
+	}
+	d.Set("x", drawing.X)
+	d.Set("y", drawing.Y)
+	d.Set("z", drawing.Z)
+	d.Set("rotation", drawing.Rotation)
+	return nil
+}
+
+func resourceGns3TextAnnotationUpdate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	updateData := map[string]interface{}{
+		"x":        d.Get("x").(int),
+		"y":        d.Get("y").(int),
+		"z":        d.Get("z").(int),
+		"rotation": d.Get("rotation").(int),
+	}
+	if d.HasChanges("text", "font_family", "font_size", "bold", "color") {
+		updateData["svg"] = textAnnotationSVG(d)
+	}
+
+	data, err := json.Marshal(updateData)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update data: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings/%s", host, projectID, d.Id())
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create update request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error updating drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	return resourceGns3TextAnnotationRead(d, meta)
+}
+
+func resourceGns3TextAnnotationDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	host := config.Host
+	projectID := d.Get("project_id").(string)
+
+	url := fmt.Sprintf("%s/v2/projects/%s/drawings/%s", host, projectID, d.Id())
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete request for drawing: %s", err)
+	}
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete drawing: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete drawing, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceGns3TextAnnotationImporter(
+	ctx context.Context,
+	d *schema.ResourceData,
+	meta interface{},
+) ([]*schema.ResourceData, error) {
+	raw := d.Id()
+	var projectID, drawingID string
+
+	if parts := strings.SplitN(raw, "/", 2); len(parts) == 2 {
+		projectID = parts[0]
+		drawingID = parts[1]
+	} else {
+		return nil, fmt.Errorf("invalid import ID %q — expected format <project_id>/<drawing_id>", raw)
+	}
+
+	if err := d.Set("project_id", projectID); err != nil {
+		return nil, err
+	}
+	d.SetId(drawingID)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// udpTunnelPortBase and udpTunnelPortSpan bound the ports generated for tunnels.
+const (
+	udpTunnelPortBase = 20000
+	udpTunnelPortSpan = 10000
+)
+
+// resourceGns3UDPTunnel wires two projects together: it creates a cloud node in each
+// project whose UDP port mapping points at the other one. Nodes are then linked to
+// the clouds with regular gns3_link resources.
+func resourceGns3UDPTunnel() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceGns3UDPTunnelCreate,
+		Read:          resourceGns3UDPTunnelRead,
+		Delete:        resourceGns3UDPTunnelDelete,
+		CustomizeDiff: computeCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the tunnel. The clouds are named <name>-a and <name>-b.",
+			},
+			"project_a_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Project of the first tunnel end.",
+			},
+			"project_b_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Project of the second tunnel end.",
+			},
+			"compute_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "local",
+				ForceNew:    true,
+				Description: "Compute running both clouds.",
+			},
+			"host_a": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "Address the first end listens on, as seen by the second. Defaults to the controller host.",
+			},
+			"host_b": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "Address the second end listens on, as seen by the first. Defaults to the controller host.",
+			},
+			"port_a": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsPortNumber,
+				Description:  "UDP port of the first end. Generated when not set.",
+			},
+			"port_b": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsPortNumber,
+				Description:  "UDP port of the second end. Generated when not set.",
+			},
+			"x": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "X position of both clouds in GNS3 GUI.",
+			},
+			"y": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Y position of both clouds in GNS3 GUI.",
+			},
+			"cloud_a_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the cloud node in the first project. Link nodes to its adapter 0, port 0.",
+			},
+			"cloud_b_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the cloud node in the second project. Link nodes to its adapter 0, port 0.",
+			},
+		},
+	}
+}
+
+// cloudUDPMapping returns a cloud ports_mapping with a single UDP tunnel port.
+func cloudUDPMapping(name string, lport int, rhost string, rport int) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"name":        name,
+			"port_number": 0,
+			"type":        "udp",
+			"lport":       lport,
+			"rhost":       rhost,
+			"rport":       rport,
+		},
+	}
+}
+
+func resourceGns3UDPTunnelCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	name := d.Get("name").(string)
+	computeID := d.Get("compute_id").(string)
+	projectA := d.Get("project_a_id").(string)
+	projectB := d.Get("project_b_id").(string)
+
+	defaultHost := "127.0.0.1"
+	if u, err := url.Parse(config.Host); err == nil && u.Hostname() != "" {
+		defaultHost = u.Hostname()
+	}
+	hostA, hostB := d.Get("host_a").(string), d.Get("host_b").(string)
+	if hostA == "" {
+		hostA = defaultHost
+	}
+	if hostB == "" {
+		hostB = defaultHost
+	}
+
+	portA, portB := d.Get("port_a").(int), d.Get("port_b").(int)
+	if portA == 0 {
+		portA = udpTunnelPortBase + rand.Intn(udpTunnelPortSpan/2)*2
+	}
+	if portB == 0 {
+		portB = portA + 1
+	}
+
+	cloudA, err := createNode(config, projectA, map[string]interface{}{
+		"name":       name + "-a",
+		"node_type":  "cloud",
+		"compute_id": computeID,
+		"x":          d.Get("x").(int),
+		"y":          d.Get("y").(int),
+		"properties": map[string]interface{}{
+			"ports_mapping": cloudUDPMapping(name, portA, hostB, portB),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create tunnel cloud in project %s: %s", projectA, err)
+	}
+
+	cloudB, err := createNode(config, projectB, map[string]interface{}{
+		"name":       name + "-b",
+		"node_type":  "cloud",
+		"compute_id": computeID,
+		"x":          d.Get("x").(int),
+		"y":          d.Get("y").(int),
+		"properties": map[string]interface{}{
+			"ports_mapping": cloudUDPMapping(name, portB, hostA, portA),
+		},
+	})
+	if err != nil {
+		_ = deleteNode(config, projectA, cloudA)
+		return fmt.Errorf("failed to create tunnel cloud in project %s: %s", projectB, err)
+	}
+
+	d.SetId(cloudA + "," + cloudB)
+	d.Set("cloud_a_id", cloudA)
+	d.Set("cloud_b_id", cloudB)
+	d.Set("host_a", hostA)
+	d.Set("host_b", hostB)
+	d.Set("port_a", portA)
+	d.Set("port_b", portB)
+	return resourceGns3UDPTunnelRead(d, meta)
+}
+
+func resourceGns3UDPTunnelRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+	ids := strings.SplitN(d.Id(), ",", 2)
+	if len(ids) != 2 {
+		return fmt.Errorf("invalid tunnel ID %q", d.Id())
+	}
+
+	// The tunnel is only usable with both ends; recreate it if either cloud is gone
+	for i, projectID := range []string{d.Get("project_a_id").(string), d.Get("project_b_id").(string)} {
+		node, err := getNode(config, projectID, ids[i])
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			d.SetId("")
+			return nil
+		}
+	}
+	return nil
+}
+
+func resourceGns3UDPTunnelDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*ProviderConfig)
+
+	if err := deleteNode(config, d.Get("project_a_id").(string), d.Get("cloud_a_id").(string)); err != nil {
+		return err
+	}
+	if err := deleteNode(config, d.Get("project_b_id").(string), d.Get("cloud_b_id").(string)); err != nil {
+		return err
+	}
+
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,291 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// OTLP span kinds and status codes used by the tracer.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanStatusError  = 2
+)
+
+// tracer records spans for resource operations and the controller calls they
+// make, and exports them to an OpenTelemetry collector over OTLP/HTTP (JSON).
+// It is only created when an OTLP endpoint is configured in the environment.
+type tracer struct {
+	endpoint string
+	service  string
+	headers  map[string]string
+	client   *http.Client
+
+	mu    sync.Mutex
+	spans []*span
+}
+
+// span is a single timed operation of a trace.
+type span struct {
+	tracer   *tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+	start    time.Time
+	attrs    map[string]interface{}
+	end      time.Time
+	err      error
+}
+
+// newTracerFromEnv returns a tracer configured by the standard OpenTelemetry
+// variables, or nil when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and
+// OTEL_EXPORTER_OTLP_ENDPOINT are both unset.
+func newTracerFromEnv() *tracer {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+	}
+
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "terraform-provider-gns3"
+	}
+
+	// OTEL_EXPORTER_OTLP_HEADERS is a comma-separated list of key=value pairs
+	headers := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	log.Printf("[INFO] Exporting GNS3 provider traces to %s", endpoint)
+	return &tracer{
+		endpoint: endpoint,
+		service:  service,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// randomHex returns n random bytes hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan begins a span. Without a parent the span starts a new trace.
+func (t *tracer) startSpan(name string, kind int, parent *span, attrs map[string]interface{}) *span {
+	s := &span{
+		tracer: t,
+		spanID: randomHex(8),
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	return s
+}
+
+// finish ends the span, recording err as its status. Ending a root span exports
+// every span recorded so far.
+func (s *span) finish(err error) {
+	s.end = time.Now()
+	s.err = err
+
+	t := s.tracer
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	var batch []*span
+	if s.parentID == "" {
+		batch, t.spans = t.spans, nil
+	}
+	t.mu.Unlock()
+
+	if len(batch) > 0 {
+		if err := t.export(batch); err != nil {
+			log.Printf("[WARN] Failed to export GNS3 provider traces: %s", err)
+		}
+	}
+}
+
+// export sends spans to the collector as an OTLP/HTTP JSON request.
+func (t *tracer) export(spans []*span) error {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": fmt.Sprint(s.start.UnixNano()),
+			"endTimeUnixNano":   fmt.Sprint(s.end.UnixNano()),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		if s.err != nil {
+			span["status"] = map[string]interface{}{"code": spanStatusError, "message": s.err.Error()}
+		}
+		encoded = append(encoded, span)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": t.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/NetOpsChic/terraform-provider-gns3"},
+				"spans": encoded,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("collector returned status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// otlpAttributes encodes span attributes as OTLP key/value pairs.
+func otlpAttributes(attrs map[string]interface{}) []interface{} {
+	encoded := make([]interface{}, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch v := v.(type) {
+		case int:
+			value = map[string]interface{}{"intValue": fmt.Sprint(v)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": k, "value": value})
+	}
+	return encoded
+}
+
+// traceResources wraps the operations of every resource and data source so each
+// runs in its own span, with the controller calls it makes as child spans.
+// Nothing is recorded unless the provider was configured with a tracer.
+func traceResources(p *schema.Provider) {
+	for name, r := range p.ResourcesMap {
+		traceResource(name, r)
+	}
+	for name, r := range p.DataSourcesMap {
+		traceResource("data."+name, r)
+	}
+}
+
+func traceResource(name string, r *schema.Resource) {
+	if r.Create != nil {
+		r.Create = traceOperation(name, "create", r.Create)
+	}
+	if r.Read != nil {
+		r.Read = traceOperation(name, "read", r.Read)
+	}
+	if r.Update != nil {
+		r.Update = traceOperation(name, "update", r.Update)
+	}
+	if r.Delete != nil {
+		r.Delete = traceOperation(name, "delete", r.Delete)
+	}
+	if r.CreateContext != nil {
+		r.CreateContext = traceContextOperation(name, "create", r.CreateContext)
+	}
+	if r.UpdateContext != nil {
+		r.UpdateContext = traceContextOperation(name, "update", r.UpdateContext)
+	}
+}
+
+// tracedMeta starts the span of a resource operation and returns a provider
+// configuration whose client records controller calls under it.
+func tracedMeta(name, op string, d *schema.ResourceData, meta interface{}) (*span, interface{}) {
+	config, ok := meta.(*ProviderConfig)
+	if !ok || config.Client.tracer == nil {
+		return nil, meta
+	}
+	s := config.Client.tracer.startSpan(name+" "+op, spanKindInternal, nil, map[string]interface{}{
+		"terraform.resource_type": name,
+		"terraform.operation":     op,
+		"terraform.resource_id":   d.Id(),
+	})
+	traced := *config
+	traced.Client = config.Client.withSpan(s)
+	return s, &traced
+}
+
+func traceOperation(name, op string, f func(*schema.ResourceData, interface{}) error) func(*schema.ResourceData, interface{}) error {
+	return func(d *schema.ResourceData, meta interface{}) error {
+		s, meta := tracedMeta(name, op, d, meta)
+		err := f(d, meta)
+		if s != nil {
+			s.attrs["terraform.resource_id"] = d.Id()
+			s.finish(err)
+		}
+		return err
+	}
+}
+
+func traceContextOperation(name, op string, f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		s, meta := tracedMeta(name, op, d, meta)
+		diags := f(ctx, d, meta)
+		if s != nil {
+			var err error
+			if diags.HasError() {
+				err = fmt.Errorf("%s", diags[0].Summary)
+			}
+			s.attrs["terraform.resource_id"] = d.Id()
+			s.finish(err)
+		}
+		return diags
+	}
+}
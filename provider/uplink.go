@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// uplinkSchema returns the schema of the uplink block shared by QEMU and Docker nodes.
+func uplinkSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Connect the node to a host interface through a cloud node and link managed by the provider.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"host_interface": {
+					Type:        schema.TypeString,
+					Required:    true,
+					Description: "Host interface the cloud node bridges to (e.g. eth1).",
+				},
+				"adapter": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Default:     0,
+					Description: "Adapter number of the node to connect.",
+				},
+				"port": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Default:     0,
+					Description: "Port number of the node to connect.",
+				},
+				"cloud_node_id": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "ID of the cloud node created for the uplink.",
+				},
+				"link_id": {
+					Type:        schema.TypeString,
+					Computed:    true,
+					Description: "ID of the link between the node and the cloud.",
+				},
+			},
+		},
+	}
+}
+
+// cloudEthernetMapping returns a cloud ports_mapping bridging port 0 to a host interface.
+func cloudEthernetMapping(iface string) []map[string]interface{} {
+	return cloudPortsMapping("ethernet", []string{iface})
+}
+
+// createUplink creates the cloud node and link described by an uplink block and
+// records their IDs in it.
+func createUplink(config *ProviderConfig, projectID, computeID, nodeID, nodeName string, x, y int, uplink map[string]interface{}) error {
+	iface := uplink["host_interface"].(string)
+
+	cloudID, err := createNode(config, projectID, map[string]interface{}{
+		"name":       nodeName + "-uplink",
+		"node_type":  "cloud",
+		"compute_id": computeID,
+		"x":          x,
+		"y":          y + 100,
+		"properties": map[string]interface{}{
+			"ports_mapping": cloudEthernetMapping(iface),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create uplink cloud for %s: %s", iface, err)
+	}
+
+	linkID, err := createLink(config, projectID,
+		LinkNode{NodeID: nodeID, AdapterNumber: uplink["adapter"].(int), PortNumber: uplink["port"].(int)},
+		LinkNode{NodeID: cloudID, AdapterNumber: 0, PortNumber: 0},
+	)
+	if err != nil {
+		_ = deleteNode(config, projectID, cloudID)
+		return fmt.Errorf("failed to link node to uplink cloud: %s", err)
+	}
+
+	uplink["cloud_node_id"] = cloudID
+	uplink["link_id"] = linkID
+	return nil
+}
+
+// deleteUplink removes the cloud node of an uplink block; GNS3 drops its link with it.
+func deleteUplink(config *ProviderConfig, projectID string, uplink map[string]interface{}) error {
+	cloudID, _ := uplink["cloud_node_id"].(string)
+	if cloudID == "" {
+		return nil
+	}
+	return deleteNode(config, projectID, cloudID)
+}
+
+// syncUplink creates, replaces or removes the managed uplink so it matches the configuration.
+func syncUplink(d *schema.ResourceData, config *ProviderConfig, projectID, computeID, nodeID string) error {
+	if !d.HasChange("uplink") {
+		return nil
+	}
+
+	oldRaw, newRaw := d.GetChange("uplink")
+	if old := oldRaw.([]interface{}); len(old) > 0 && old[0] != nil {
+		if err := deleteUplink(config, projectID, old[0].(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+
+	blocks := newRaw.([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return d.Set("uplink", nil)
+	}
+
+	uplink := blocks[0].(map[string]interface{})
+	if err := createUplink(config, projectID, computeID, nodeID, d.Get("name").(string), d.Get("x").(int), d.Get("y").(int), uplink); err != nil {
+		return err
+	}
+	return d.Set("uplink", []interface{}{uplink})
+}
@@ -1,14 +1,26 @@
 package provider
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Fetch the first available project ID (used by both nodes and links)
-func getProjectID(host string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/v2/projects", host))
+func getProjectID(config *ProviderConfig) (string, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects", config.Host))
 	if err != nil {
 		return "", err
 	}
@@ -26,8 +38,8 @@ func getProjectID(host string) (string, error) {
 }
 
 // Function to get template ID from template name
-func getTemplateID(host string, templateName string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/v2/templates", host))
+func getTemplateID(config *ProviderConfig, templateName string) (string, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/templates", config.Host))
 	if err != nil {
 		return "", err
 	}
@@ -49,3 +61,844 @@ func getTemplateID(host string, templateName string) (string, error) {
 	}
 	return "", fmt.Errorf("template %s not found", templateName)
 }
+
+// nodePortsSchema returns the computed schema used to expose a node's ports.
+func nodePortsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "Ports reported by the controller for this node.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"short_name": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"adapter_number": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"port_number": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"link_type": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"mac_address": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+// webUIServerID is the ID the web UI bundled with the controller gives to the
+// controller it is served from.
+const webUIServerID = "1"
+
+// webURLSchema returns the computed schema of a deep link into the GNS3 web UI.
+func webURLSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: description,
+	}
+}
+
+// projectWebURL returns the web UI URL of a project's canvas.
+func projectWebURL(config *ProviderConfig, projectID string) string {
+	return fmt.Sprintf("%s/static/web-ui/server/%s/project/%s", config.Host, webUIServerID, projectID)
+}
+
+// nodeWebURL returns the web UI URL of a node's console.
+func nodeWebURL(config *ProviderConfig, projectID, nodeID string) string {
+	return fmt.Sprintf("%s/nodes/%s", projectWebURL(config, projectID), nodeID)
+}
+
+// flattenNodePorts converts the "ports" array of a controller node into state values.
+func flattenNodePorts(node map[string]interface{}) []map[string]interface{} {
+	raw, _ := node["ports"].([]interface{})
+	ports := make([]map[string]interface{}, 0, len(raw))
+	for _, p := range raw {
+		port, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ports = append(ports, map[string]interface{}{
+			"name":           port["name"],
+			"short_name":     port["short_name"],
+			"adapter_number": jsonInt(port["adapter_number"]),
+			"port_number":    jsonInt(port["port_number"]),
+			"link_type":      port["link_type"],
+			"mac_address":    port["mac_address"],
+		})
+	}
+	return ports
+}
+
+// portMACAddresses returns the MAC address of each adapter of a node, in adapter
+// order. Adapters the controller reports no address for are left empty.
+func portMACAddresses(node map[string]interface{}) []string {
+	var macs []string
+	for _, port := range flattenNodePorts(node) {
+		adapter := port["adapter_number"].(int)
+		for len(macs) <= adapter {
+			macs = append(macs, "")
+		}
+		if mac, ok := port["mac_address"].(string); ok && macs[adapter] == "" {
+			macs[adapter] = mac
+		}
+	}
+	return macs
+}
+
+// jsonInt converts a decoded JSON number into an int.
+func jsonInt(v interface{}) int {
+	switch t := v.(type) {
+	case float64:
+		return int(t)
+	case int:
+		return t
+	}
+	return 0
+}
+
+// listProjectNodes returns every node of a project as reported by the controller.
+func listProjectNodes(config *ProviderConfig, projectID string) ([]map[string]interface{}, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/projects/%s/nodes", config.Host, projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list nodes, status code: %d", resp.StatusCode)
+	}
+
+	var nodes []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode nodes: %s", err)
+	}
+	return nodes, nil
+}
+
+// findNodeByName returns the node with the given name in a project, or nil if there is none.
+func findNodeByName(config *ProviderConfig, projectID, name string) (map[string]interface{}, error) {
+	nodes, err := listProjectNodes(config, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		if n, ok := node["name"].(string); ok && n == name {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+// adoptExistingNode points d at an existing node with the same name when adopt_existing
+// is enabled. An empty nodeType accepts any node type. It reports whether a node was adopted.
+func adoptExistingNode(d *schema.ResourceData, config *ProviderConfig, projectID, name, nodeType string) (bool, error) {
+	if !d.Get("adopt_existing").(bool) {
+		return false, nil
+	}
+
+	node, err := findNodeByName(config, projectID, name)
+	if err != nil {
+		return false, err
+	}
+	if node == nil {
+		return false, nil
+	}
+
+	if t, _ := node["node_type"].(string); nodeType != "" && t != nodeType {
+		return false, fmt.Errorf("cannot adopt node %q: existing node has type %q, expected %q", name, t, nodeType)
+	}
+	nodeID, ok := node["node_id"].(string)
+	if !ok || nodeID == "" {
+		return false, fmt.Errorf("cannot adopt node %q: controller returned no node_id", name)
+	}
+
+	log.Printf("[INFO] Adopting existing GNS3 node %q (%s)", name, nodeID)
+	d.SetId(nodeID)
+	return true, nil
+}
+
+// adoptExistingSchema returns the schema for the opt-in adopt_existing attribute.
+func adoptExistingSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     false,
+		Description: "If true and a node with the same name already exists in the project, adopt it into state and reconcile its properties instead of creating a duplicate.",
+	}
+}
+
+// consoleHost returns the address clients should use to reach a node console. Wildcard
+// bind addresses reported by the compute are replaced with the controller's hostname.
+func consoleHost(config *ProviderConfig, node map[string]interface{}) string {
+	host, _ := node["console_host"].(string)
+	switch host {
+	case "", "0.0.0.0", "::", "0:0:0:0:0:0:0:0":
+		if u, err := url.Parse(config.Host); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	return host
+}
+
+// updateNode sends a PUT with the given fields to a node.
+func updateNode(config *ProviderConfig, projectID, nodeID string, updateData map[string]interface{}) error {
+	data, err := json.Marshal(updateData)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node update: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", config.Host, projectID, nodeID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create update request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update node, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// getNode fetches a single node. It returns nil without error when the node does not exist.
+func getNode(config *ProviderConfig, projectID, nodeID string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s", config.Host, projectID, nodeID)
+	resp, err := config.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to read node, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return nil, fmt.Errorf("failed to decode node: %s", err)
+	}
+	return node, nil
+}
+
+// startCheckInterval is how often a node is polled during start_check_seconds.
+var startCheckInterval = time.Second
+
+// startLogLines is the number of log lines included when a node crashes on start.
+const startLogLines = 20
+
+// nodeAction posts to one of the node action endpoints (start, stop, suspend, reload).
+// A start is staggered and, with start_check_seconds, watched for an early crash.
+func nodeAction(config *ProviderConfig, projectID, nodeID, action string) error {
+	if action != "start" {
+		return postNodeAction(config, projectID, nodeID, action)
+	}
+	for attempt := 0; ; attempt++ {
+		waitForStartSlot(config)
+		if err := postNodeAction(config, projectID, nodeID, action); err != nil {
+			return err
+		}
+		err := checkNodeStarted(config, projectID, nodeID)
+		if err == nil || attempt >= config.StartRetries {
+			return err
+		}
+		log.Printf("[WARN] Node %s stopped right after its start, starting it again (%d/%d)", nodeID, attempt+1, config.StartRetries)
+	}
+}
+
+// checkNodeStarted watches a started node for start_check_seconds and fails with
+// the end of its log if it stops in the meantime, i.e. crashed on boot.
+func checkNodeStarted(config *ProviderConfig, projectID, nodeID string) error {
+	if config.StartCheck <= 0 || config.Client.dryRun != nil {
+		return nil
+	}
+	started := time.Now()
+	for deadline := started.Add(config.StartCheck); time.Now().Before(deadline); time.Sleep(startCheckInterval) {
+		node, err := getNode(config, projectID, nodeID)
+		if err != nil {
+			return err
+		}
+		if node == nil || node["status"] != "stopped" {
+			continue
+		}
+
+		name, _ := node["name"].(string)
+		msg := fmt.Sprintf("node %s stopped %s after it was started, it is likely crashing on boot", name, time.Since(started).Round(time.Second))
+		content, err := readNodeLog(config, projectID, node)
+		if err != nil {
+			log.Printf("[WARN] Cannot read the log of node %s: %s", name, err)
+		}
+		if strings.TrimSpace(content) != "" {
+			msg += fmt.Sprintf("; last lines of its log:\n%s", logTail(content, startLogLines))
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// postNodeAction posts to a node action endpoint.
+func postNodeAction(config *ProviderConfig, projectID, nodeID, action string) error {
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes/%s/%s", config.Host, projectID, nodeID, action)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer([]byte("{}")))
+	if err != nil {
+		return fmt.Errorf("failed to %s node: %s", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s node, status code: %d, response: %s", action, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// waitForStartSlot delays a node start until start_stagger_seconds have passed
+// since the previous start issued during this run.
+func waitForStartSlot(config *ProviderConfig) {
+	if config.StartStagger <= 0 {
+		return
+	}
+	config.startMu.Lock()
+	defer config.startMu.Unlock()
+	if wait := time.Until(config.lastStart.Add(config.StartStagger)); wait > 0 {
+		log.Printf("[INFO] Waiting %s before the next node start (start_stagger_seconds)", wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+	*config.lastStart = time.Now()
+}
+
+// renamedNodeLabel returns the label of a node with its text set to name and
+// centered over the node again, keeping its style, for an update renaming it.
+// It returns nil when the node has no label.
+func renamedNodeLabel(config *ProviderConfig, projectID, nodeID, name string) (map[string]interface{}, error) {
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	current, ok := node["label"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	label := map[string]interface{}{}
+	for k, v := range current {
+		label[k] = v
+	}
+	label["text"] = name
+	label["x"] = nil
+	return label, nil
+}
+
+// nodeStatusSchema returns the computed schema reporting a node's power state.
+// It is kept apart from the start flags, which only express what to do on apply,
+// so a refresh shows nodes stopped or started outside Terraform.
+func nodeStatusSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "Power state reported by the controller: started, stopped or suspended.",
+	}
+}
+
+// consoleAutoStartSchema returns the schema of console_auto_start.
+func consoleAutoStartSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Computed:    true,
+		Description: "Open the node's console in the GNS3 GUI whenever the node starts, e.g. for demos. Defaults to the template's setting. The console command itself is a GUI preference.",
+	}
+}
+
+// usageLabelsHeader starts the block of provider default_labels in a node's usage.
+const usageLabelsHeader = "[managed by terraform]"
+
+// usageWithLabels appends the provider's default_labels to a node's usage text, so
+// GUI users can tell which nodes Terraform manages and on whose behalf.
+func usageWithLabels(config *ProviderConfig, usage string) string {
+	if len(config.DefaultLabels) == 0 {
+		return usage
+	}
+	lines := []string{usageLabelsHeader}
+	for _, key := range sortedKeys(config.DefaultLabels) {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, config.DefaultLabels[key]))
+	}
+	block := strings.Join(lines, "\n")
+	if usage == "" {
+		return block
+	}
+	return usage + "\n\n" + block
+}
+
+// usageWithoutLabels strips the default_labels block from a node's usage text.
+func usageWithoutLabels(usage string) string {
+	if i := strings.Index(usage, usageLabelsHeader); i >= 0 {
+		return strings.TrimRight(usage[:i], "\n")
+	}
+	return usage
+}
+
+// setImportDefaults sets attributes of an imported resource to their schema
+// defaults. They only steer the provider, so there is nothing on the controller
+// to read them back from, and leaving them empty plans a change after import.
+func setImportDefaults(d *schema.ResourceData, s map[string]*schema.Schema, attrs ...string) error {
+	for _, attr := range attrs {
+		if err := d.Set(attr, s[attr].Default); err != nil {
+			return fmt.Errorf("failed to set %s: %s", attr, err)
+		}
+	}
+	return nil
+}
+
+// startRequested reports whether a resource's start flag asks for the node to be
+// started. A flag left out of the configuration takes provider-level
+// auto_start_nodes when that is set, else the resource default. Provider-level
+// skip_start overrides both so large labs can be applied on a constrained
+// controller and started later with gns3_start_all.
+func startRequested(d *schema.ResourceData, config *ProviderConfig, attr string) bool {
+	start := d.Get(attr).(bool)
+	if raw := d.GetRawConfig(); config.AutoStartNodes != nil && !raw.IsNull() && raw.IsKnown() && raw.Type().IsObjectType() &&
+		raw.Type().HasAttribute(attr) && raw.GetAttr(attr).IsNull() {
+		start = *config.AutoStartNodes
+	}
+	if !start {
+		return false
+	}
+	if config.SkipStart {
+		log.Printf("[INFO] skip_start is set, not starting node %q", d.Get("name"))
+		return false
+	}
+	return true
+}
+
+// nodeDesiredStates are the power states desired_state can ask for.
+var nodeDesiredStates = []string{"started", "stopped", "suspended"}
+
+// desiredStateSchema returns the schema of desired_state, the power state a node
+// is kept in across applies.
+func desiredStateSchema(conflictsWith ...string) *schema.Schema {
+	return &schema.Schema{
+		Type:          schema.TypeString,
+		Optional:      true,
+		ValidateFunc:  validation.StringInSlice(nodeDesiredStates, false),
+		ConflictsWith: conflictsWith,
+		Description:   "Power state to keep the node in: started, stopped or suspended. A node found in another state, e.g. stopped in the GUI, is brought back on the next apply.",
+	}
+}
+
+// reloadTriggerSchema returns the schema of reload_trigger.
+func reloadTriggerSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "Arbitrary value; changing it reloads (restarts) a running node, e.g. reload_trigger = timestamp() restarts it on every apply.",
+	}
+}
+
+// desiredStateCustomizeDiff plans an update when a node's status drifted from its desired_state.
+func desiredStateCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	desired := d.Get("desired_state").(string)
+	if d.Id() == "" || desired == "" {
+		return nil
+	}
+	if status := d.Get("status").(string); status != desired {
+		return d.SetNewComputed("status")
+	}
+	return nil
+}
+
+// applyDesiredState brings a node into the power state desired_state asks for,
+// and reloads it when reload_trigger changed. Starting is skipped under skip_start.
+func applyDesiredState(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string) error {
+	desired := d.Get("desired_state").(string)
+	reload := !d.IsNewResource() && d.HasChange("reload_trigger")
+	if desired == "" && !reload {
+		return nil
+	}
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+	status, _ := node["status"].(string)
+
+	if desired != "" && desired != status {
+		if desired != "stopped" && config.SkipStart {
+			log.Printf("[INFO] skip_start is set, not starting node %q", d.Get("name"))
+			return nil
+		}
+		var actions []string
+		switch desired {
+		case "started":
+			actions = []string{"start"}
+		case "stopped":
+			actions = []string{"stop"}
+		case "suspended":
+			if status != "started" {
+				actions = []string{"start"}
+			}
+			actions = append(actions, "suspend")
+		}
+		for _, action := range actions {
+			if err := nodeAction(config, projectID, nodeID, action); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if reload && status == "started" {
+		log.Printf("[INFO] Reloading GNS3 node %s, reload_trigger changed", nodeID)
+		return nodeAction(config, projectID, nodeID, "reload")
+	}
+	return nil
+}
+
+// reloadOnChangeSchema returns the schema for the reload_on_change attribute.
+func reloadOnChangeSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Description: "Attribute names that, when changed, cause the node to be reloaded after the update so the new settings take effect.",
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+	}
+}
+
+// reloadOnChangeCustomizeDiff fails plans whose reload_on_change names an
+// attribute the resource built by resource does not have, which would never
+// trigger a reload. Nested keys such as environment.FOO are checked by their
+// first part.
+func reloadOnChangeCustomizeDiff(resource func() *schema.Resource) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		attrs := resource().Schema
+		for _, raw := range d.Get("reload_on_change").([]interface{}) {
+			name, _ := raw.(string)
+			if name == "" {
+				continue
+			}
+			if _, ok := attrs[strings.SplitN(name, ".", 2)[0]]; !ok || name == "reload_on_change" {
+				return fmt.Errorf("reload_on_change: %q is not an attribute of this resource", name)
+			}
+		}
+		return nil
+	}
+}
+
+// reloadNodeIfChanged reloads a running node when any attribute listed in
+// reload_on_change has changed. Stopped nodes pick up settings on their next start.
+func reloadNodeIfChanged(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string) error {
+	changed := false
+	for _, attr := range d.Get("reload_on_change").([]interface{}) {
+		if name, ok := attr.(string); ok && name != "reload_on_change" && d.HasChange(name) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	node, err := getNode(config, projectID, nodeID)
+	if err != nil {
+		return err
+	}
+	if node == nil || node["status"] != "started" {
+		return nil
+	}
+
+	log.Printf("[INFO] Reloading GNS3 node %s after configuration change", nodeID)
+	return nodeAction(config, projectID, nodeID, "reload")
+}
+
+// stopBeforeUpdateSchema returns the schema of stop_before_update.
+func stopBeforeUpdateSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     true,
+		Description: "Stop a running node before changing settings that only apply when it boots, e.g. adapters or RAM. When false, such changes are sent to the running node and take effect on its next restart.",
+	}
+}
+
+// restartAfterUpdateSchema returns the schema of restart_after_update.
+func restartAfterUpdateSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     true,
+		Description: "Start the node again after it was stopped by stop_before_update.",
+	}
+}
+
+// stopForUpdate stops a running node before any of restartAttrs changes, unless
+// stop_before_update is off, and reports whether it did. Other changes are sent
+// to the node as it runs.
+func stopForUpdate(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID, status string, restartAttrs ...string) (bool, error) {
+	if status != "started" || !d.HasChanges(restartAttrs...) {
+		return false, nil
+	}
+	if !d.Get("stop_before_update").(bool) {
+		log.Printf("[WARN] GNS3 node %s is running, the changed settings take effect on its next restart", nodeID)
+		return false, nil
+	}
+
+	log.Printf("[INFO] Stopping GNS3 node %s to apply settings that need a restart", nodeID)
+	if err := nodeAction(config, projectID, nodeID, "stop"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restartAfterUpdate starts a node stopped by stopForUpdate again, unless
+// restart_after_update is off.
+func restartAfterUpdate(d *schema.ResourceData, config *ProviderConfig, projectID, nodeID string, stopped bool) error {
+	if !stopped || !d.Get("restart_after_update").(bool) {
+		return nil
+	}
+	log.Printf("[INFO] Starting GNS3 node %s again after the update", nodeID)
+	return nodeAction(config, projectID, nodeID, "start")
+}
+
+// listTemplates returns every template known to the controller.
+func listTemplates(config *ProviderConfig) ([]map[string]interface{}, error) {
+	resp, err := config.Client.Get(fmt.Sprintf("%s/v2/templates", config.Host))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching templates from GNS3 server: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 response from GNS3 server: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	var templates []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&templates); err != nil {
+		return nil, fmt.Errorf("error decoding response from GNS3 server: %s", err)
+	}
+	return templates, nil
+}
+
+// createNode posts a node payload to the controller and returns the new node ID.
+func createNode(config *ProviderConfig, projectID string, payload map[string]interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal node payload: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/nodes", config.Host, projectID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create node, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var node map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return "", fmt.Errorf("failed to decode node response: %s", err)
+	}
+	nodeID, ok := node["node_id"].(string)
+	if !ok || nodeID == "" {
+		return "", fmt.Errorf("node_id not returned by controller")
+	}
+	return nodeID, nil
+}
+
+// createLink connects two node ports and returns the new link ID.
+func createLink(config *ProviderConfig, projectID string, a, b LinkNode) (string, error) {
+	data, err := json.Marshal(Link{Nodes: []LinkNode{a, b}})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal link data: %s", err)
+	}
+
+	url := fmt.Sprintf("%s/v2/projects/%s/links", config.Host, projectID)
+	resp, err := config.Client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create link: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create link, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var created Link
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode link response: %s", err)
+	}
+	return created.LinkID, nil
+}
+
+// deleteLink removes a link; a link that is already gone is not an error.
+// Deleting a node deletes its links, so when a node and its links are destroyed
+// in parallel the controller may fail the link delete as the link disappears:
+// the failure is only reported when the link still exists.
+func deleteLink(config *ProviderConfig, projectID, linkID string) error {
+	path := fmt.Sprintf("/v2/projects/%s/links/%s", projectID, linkID)
+	req, err := http.NewRequest("DELETE", config.Host+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete request: %s", err)
+	}
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete link: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := ioutil.ReadAll(resp.Body)
+		if exists, err := objectExists(config, path); err == nil && !exists {
+			return nil
+		}
+		return fmt.Errorf("failed to delete link, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// suspendLink disconnects (suspend) or reconnects the cable of a link.
+func suspendLink(config *ProviderConfig, projectID, linkID string, suspend bool) error {
+	data, err := json.Marshal(map[string]interface{}{"suspend": suspend})
+	if err != nil {
+		return fmt.Errorf("failed to marshal link update: %s", err)
+	}
+	url := fmt.Sprintf("%s/v2/projects/%s/links/%s", config.Host, projectID, linkID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create link update request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update link: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update link %s, status code: %d, response: %s", linkID, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// deleteNode removes a node; a node that is already gone is not an error, nor
+// is a failure that left it deleted, e.g. a link of the node that another
+// resource deleted meanwhile.
+func deleteNode(config *ProviderConfig, projectID, nodeID string) error {
+	path := fmt.Sprintf("/v2/projects/%s/nodes/%s", projectID, nodeID)
+	req, err := http.NewRequest("DELETE", config.Host+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create delete request: %s", err)
+	}
+	resp, err := config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := ioutil.ReadAll(resp.Body)
+		if exists, err := objectExists(config, path); err == nil && !exists {
+			return nil
+		}
+		return fmt.Errorf("failed to delete node, status code: %d, response: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// objectExists reports whether an object of the controller, given by its API
+// path, still exists.
+func objectExists(config *ProviderConfig, path string) (bool, error) {
+	resp, err := config.Client.Get(config.Host + path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return false, fmt.Errorf("failed to read %s, status code: %d, response: %s", path, resp.StatusCode, string(body))
+}
+
+// protectSchema returns the schema of the protect flag shared by projects and nodes.
+func protectSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Default:     false,
+		Description: "If true, the provider refuses to delete this object, even when Terraform plans its destruction or replacement. Set to false and apply before destroying.",
+	}
+}
+
+// checkProtected returns an error when a protected object is about to be deleted.
+func checkProtected(d *schema.ResourceData, kind string) error {
+	if d.Get("protect").(bool) {
+		return fmt.Errorf("%s %q is protected: set protect = false and apply before destroying it", kind, d.Get("name").(string))
+	}
+	return nil
+}
+
+// updateWithWarnings adapts an update function to UpdateContext and adds a warning for
+// every changed attribute the controller silently ignores on update, keyed to the reason.
+// Positions that are applied are checked against the project canvas.
+func updateWithWarnings(update func(*schema.ResourceData, interface{}) error, ignored map[string]string) schema.UpdateContextFunc {
+	apply := withCanvasCheck(update)
+	if _, ok := ignored["x"]; ok {
+		apply = func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			return diag.FromErr(update(d, meta))
+		}
+	}
+	return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		var diags diag.Diagnostics
+		for _, attr := range sortedKeys(ignored) {
+			if d.HasChange(attr) {
+				diags = append(diags, diag.Diagnostic{
+					Severity:      diag.Warning,
+					Summary:       fmt.Sprintf("Change to %q has no effect", attr),
+					Detail:        ignored[attr],
+					AttributePath: cty.GetAttrPath(attr),
+				})
+			}
+		}
+		return append(diags, apply(ctx, d, meta)...)
+	}
+}